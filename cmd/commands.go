@@ -0,0 +1,417 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/briheet/spacetime-goclient/format"
+	"github.com/briheet/spacetime-goclient/spacetimedb"
+)
+
+func runPing(ctx context.Context, a *app, args []string) error {
+	start := time.Now()
+	if err := a.client.Ping(); err != nil {
+		return err
+	}
+	a.printf("%s is up (%s)\n", a.server, time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+func runProfiles(ctx context.Context, a *app, args []string) error {
+	if a.config == nil {
+		return fmt.Errorf("no config file, create %s or pass -config", spacetimedb.DefaultConfigPath())
+	}
+	out := format.Result{Columns: []string{"profile", "url", "database", "token", "active"}}
+	for _, p := range a.config.Profiles {
+		token := ""
+		if p.Token != "" || a.config.Token != "" {
+			token = "set"
+		}
+		active := ""
+		if a.profile != nil && a.profile.Name == p.Name {
+			active = "*"
+		}
+		out.Rows = append(out.Rows, []any{p.Name, p.ServerURL(), p.Database, token, active})
+	}
+	return a.print(out)
+}
+
+func runIdentity(ctx context.Context, a *app, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: spacetime %s", commands["identity"].usage)
+	}
+
+	switch args[0] {
+	case "new":
+		fs := a.flags("identity")
+		email := fs.String("email", "", "register the identity with this email")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+
+		var identity, token string
+		var err error
+		if *email != "" {
+			identity, token, err = a.client.RegisterIdentityWithEmail(*email)
+		} else {
+			identity, token, err = a.client.CreateIdentity()
+		}
+		if err != nil {
+			return err
+		}
+		a.printf("identity: %s\ntoken:    %s\n", identity, token)
+		return nil
+
+	case "verify":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: spacetime identity verify <identity>")
+		}
+		if err := a.requireToken(); err != nil {
+			return err
+		}
+		if err := a.client.VerifyIdentityToken(args[1], a.token); err != nil {
+			return err
+		}
+		a.printf("token is valid for %s\n", args[1])
+		return nil
+
+	case "databases":
+		if len(args) != 2 {
+			return fmt.Errorf("usage: spacetime identity databases <identity>")
+		}
+		dbs, err := a.client.GetDatabasesByIdentity(args[1])
+		if err != nil {
+			return err
+		}
+		return a.print(format.FromDatabases(dbs))
+
+	default:
+		return fmt.Errorf("unknown identity command %q", args[0])
+	}
+}
+
+func runPublish(ctx context.Context, a *app, args []string) error {
+	fs := a.flags("publish")
+	clear := fs.Bool("clear", false, "drop all existing data")
+	name := fs.String("name", a.database, "database name, empty for an anonymous database")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("publish needs exactly one module file")
+	}
+	if err := a.requireToken(); err != nil {
+		return err
+	}
+
+	wasm := fs.Arg(0)
+	if *name == "" {
+		identity, op, err := a.client.PublishDatabase(wasm, a.token)
+		if err != nil {
+			return err
+		}
+		a.printf("%s %s\n", op, identity)
+		return nil
+	}
+
+	identity, op, domain, err := a.client.PublishNamedDatabase(*name, wasm, a.token, *clear)
+	if err != nil {
+		return err
+	}
+	if domain != nil {
+		a.printf("%s %s (%s)\n", op, *domain, identity)
+	} else {
+		a.printf("%s %s\n", op, identity)
+	}
+	return nil
+}
+
+func runDelete(ctx context.Context, a *app, args []string) error {
+	db, err := a.databaseArg(args, 0)
+	if err != nil {
+		return err
+	}
+	if err := a.requireToken(); err != nil {
+		return err
+	}
+	if err := a.client.DeleteDatabase(db, a.token); err != nil {
+		return err
+	}
+	a.printf("deleted %s\n", db)
+	return nil
+}
+
+func runNames(ctx context.Context, a *app, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: spacetime %s", commands["names"].usage)
+	}
+
+	switch args[0] {
+	case "list":
+		db, err := a.databaseArg(args, 1)
+		if err != nil {
+			return err
+		}
+		names, err := a.client.GetDatabaseNames(db)
+		if err != nil {
+			return err
+		}
+		return a.print(format.FromNames(names))
+
+	case "add":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: spacetime names add <name> [database]")
+		}
+		db, err := a.databaseArg(args, 2)
+		if err != nil {
+			return err
+		}
+		if err := a.requireToken(); err != nil {
+			return err
+		}
+		if err := a.client.AddDatabaseName(db, args[1], a.token); err != nil {
+			return err
+		}
+		a.printf("%s is now also known as %s\n", db, args[1])
+		return nil
+
+	case "remove":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: spacetime names remove <name> [database]")
+		}
+		db, err := a.databaseArg(args, 2)
+		if err != nil {
+			return err
+		}
+		if err := a.requireToken(); err != nil {
+			return err
+		}
+		if err := a.client.RemoveDatabaseName(db, args[1], a.token); err != nil {
+			return err
+		}
+		a.printf("%s is no longer known as %s\n", db, args[1])
+		return nil
+
+	case "replace":
+		if len(args) < 3 {
+			return fmt.Errorf("usage: spacetime names replace <old> <new> [database]")
+		}
+		db, err := a.databaseArg(args, 3)
+		if err != nil {
+			return err
+		}
+		if err := a.requireToken(); err != nil {
+			return err
+		}
+		if err := a.client.ReplaceDatabaseName(db, args[1], args[2], a.token); err != nil {
+			return err
+		}
+		a.printf("%s is now known as %s instead of %s\n", db, args[2], args[1])
+		return nil
+
+	case "set":
+		// The database is required here, as every name it has is replaced.
+		if len(args) < 2 {
+			return fmt.Errorf("usage: spacetime names set <database> [name...]")
+		}
+		if err := a.requireToken(); err != nil {
+			return err
+		}
+		if err := a.client.SetDatabaseNames(args[1], args[2:], a.token); err != nil {
+			return err
+		}
+		a.printf("%s now has %d %s\n", args[1], len(args)-2, plural(len(args)-2, "name"))
+		return nil
+
+	case "lookup":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: spacetime names lookup <name>")
+		}
+		info, err := a.client.LookupDatabaseName(args[1])
+		if err != nil {
+			return err
+		}
+		return a.print(format.FromNameInfo(info))
+
+	case "owned":
+		if len(args) < 2 {
+			return fmt.Errorf("usage: spacetime names owned <identity>")
+		}
+		inventory, err := a.client.GetDatabaseInventory(args[1])
+		if err != nil {
+			return err
+		}
+		return a.print(format.FromInventory(inventory))
+
+	default:
+		return fmt.Errorf("unknown names command %q", args[0])
+	}
+}
+
+func runInfo(ctx context.Context, a *app, args []string) error {
+	db, err := a.databaseArg(args, 0)
+	if err != nil {
+		return err
+	}
+	identity, owner, hostType, program, err := a.client.GetDatabaseInfo(db)
+	if err != nil {
+		return err
+	}
+	return a.print(format.FromDatabaseInfo(identity, owner, hostType, program))
+}
+
+func runLogs(ctx context.Context, a *app, args []string) error {
+	fs := a.flags("logs")
+	lines := fs.Int("n", 0, "number of past lines, 0 for the server default")
+	follow := fs.Bool("f", false, "keep printing new records until interrupted")
+	level := fs.String("level", "trace", "minimum level to print")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	db, err := a.databaseArg(fs.Args(), 0)
+	if err != nil {
+		return err
+	}
+	minLevel, err := spacetimedb.ParseLogLevel(*level)
+	if err != nil {
+		return err
+	}
+
+	records, errs, err := a.client.StreamDatabaseLogs(ctx, db, a.token, spacetimedb.LogStreamOptions{
+		NumLines: *lines,
+		Follow:   *follow,
+		MinLevel: minLevel,
+	})
+	if err != nil {
+		return err
+	}
+	for {
+		select {
+		case rec, ok := <-records:
+			if !ok {
+				// errs is closed once the stream is over.
+				records = nil
+				continue
+			}
+			a.printf("%s %-5s %s:%d %s\n", rec.Timestamp.Format(time.RFC3339), rec.Level, rec.Filename, rec.LineNumber, rec.Message)
+		case err, ok := <-errs:
+			if !ok || !errors.Is(err, spacetimedb.ErrInvalidLogLine) {
+				return err
+			}
+			fmt.Fprintln(a.stderr, "warning:", err)
+		}
+	}
+}
+
+// runSQL runs a single query, or starts the REPL when none is given.
+func runSQL(ctx context.Context, a *app, args []string) error {
+	if len(args) != 1 || strings.HasPrefix(args[0], "-") {
+		return runREPL(ctx, a, args)
+	}
+	db, err := a.databaseArg(nil, 0)
+	if err != nil {
+		return err
+	}
+
+	results, err := a.client.RunSQLQuery(args[0], a.token, db)
+	if err != nil {
+		return err
+	}
+	for _, res := range results {
+		r, err := format.FromSQL(res)
+		if err != nil {
+			return err
+		}
+		if err := a.print(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runCall(ctx context.Context, a *app, args []string) error {
+	fs := a.flags("call")
+	raw := fs.Bool("raw", false, "send each argument as JSON or a bare string without checking the schema")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	args = fs.Args()
+	if len(args) == 0 {
+		return fmt.Errorf("usage: spacetime %s", commands["call"].usage)
+	}
+
+	// The database comes first, but may be left out when -database is set:
+	// a first argument that names no database is then taken as the reducer.
+	// Without the schema, -raw only asks whether the database exists.
+	var db string
+	var schema *spacetimedb.ModuleSchema
+	if len(args) > 1 {
+		db = args[0]
+		var err error
+		if *raw {
+			_, err = a.client.GetDatabaseIdentity(db)
+		} else {
+			schema, err = a.client.GetDatabaseSchema(db)
+		}
+		switch {
+		case errors.Is(err, spacetimedb.ErrDatabaseNotFound) && a.database != "":
+			db = ""
+		case err != nil:
+			return err
+		}
+		if db != "" {
+			args = args[1:]
+		}
+	}
+	if db == "" {
+		var err error
+		if db, err = a.databaseArg(nil, 0); err != nil {
+			return err
+		}
+	}
+	reducer := args[0]
+
+	var callArgs []any
+	if *raw {
+		for _, arg := range args[1:] {
+			callArgs = append(callArgs, parseArg(arg))
+		}
+	} else {
+		if schema == nil {
+			var err error
+			if schema, err = a.client.GetDatabaseSchema(db); err != nil {
+				return err
+			}
+		}
+		rd, ok := schema.Reducer(reducer)
+		if !ok {
+			return fmt.Errorf("database %s has no reducer %s", db, reducer)
+		}
+		var err error
+		if callArgs, err = rd.ParseArgs(args[1:]); err != nil {
+			return err
+		}
+	}
+
+	res, err := a.client.CallReducerWithResult(ctx, reducer, db, a.token, callArgs)
+	if err != nil {
+		return err
+	}
+	a.printf("called %s (%d quanta, %s)\n", reducer, res.EnergyUsed, res.Duration)
+	return nil
+}
+
+// parseArg reads a reducer argument as JSON, so numbers, booleans, arrays
+// and objects keep their type, and falls back to a plain string.
+func parseArg(arg string) any {
+	var v any
+	if err := json.Unmarshal([]byte(arg), &v); err == nil {
+		return json.RawMessage(arg)
+	}
+	return arg
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/briheet/spacetime-goclient/deploy"
+	"github.com/briheet/spacetime-goclient/spacetimedb"
+)
+
+// manifestNames are looked for in the working directory when -f is not given.
+var manifestNames = []string{"spacetime.yaml", "spacetime.yml", "spacetime.toml", "spacetime.json"}
+
+func runDeploy(ctx context.Context, a *app, args []string) error {
+	fs := a.flags("deploy")
+	file := fs.String("f", "", "manifest file, spacetime.yaml or spacetime.toml by default")
+	dryRun := fs.Bool("dry-run", false, "print the plan without applying it")
+	clearExisting := fs.Bool("clear-existing", false, "let clear: always delete the data of databases that already exist")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *file == "" {
+		for _, name := range manifestNames {
+			if _, err := os.Stat(name); err == nil {
+				*file = name
+				break
+			}
+		}
+		if *file == "" {
+			return fmt.Errorf("no manifest found, pass one with -f")
+		}
+	}
+	if !*dryRun {
+		if err := a.requireToken(); err != nil {
+			return err
+		}
+	}
+
+	m, err := deploy.Load(*file)
+	if err != nil {
+		return err
+	}
+
+	// Databases on the -server server share the global client; others get
+	// their own with the token and TLS settings of their profile, see
+	// serverOptions.
+	others, closeOthers := deploy.ServerConnector(ctx, func(server string) ([]spacetimedb.Option, error) {
+		return a.serverOptions(server, !*dryRun)
+	})
+	defer closeOthers()
+	connect := func(server string) (spacetimedb.DBClient, error) {
+		if server == "" || server == a.server {
+			return a.client, nil
+		}
+		return others(server)
+	}
+
+	plan, err := deploy.NewPlan(m, connect, *clearExisting)
+	if errors.Is(err, deploy.ErrClearsData) {
+		return fmt.Errorf("%w, pass -clear-existing to allow it", err)
+	}
+	if err != nil {
+		return err
+	}
+	a.printf("%s", plan)
+	if *dryRun {
+		a.printf("%d %s planned, run without -dry-run to apply.\n", len(plan.Steps), plural(len(plan.Steps), "step"))
+		return nil
+	}
+
+	applied := 0
+	err = plan.Apply(func(deploy.Step) { applied++ })
+	if err != nil {
+		return errors.Join(err, fmt.Errorf("applied %d of %d steps, run deploy again to continue", applied, len(plan.Steps)))
+	}
+	a.printf("Applied %d %s.\n", applied, plural(applied, "step"))
+	return nil
+}
+
+// serverOptions returns the options for a server other than -server: the
+// token and TLS settings of the config file's profile for that server. The
+// token given with -token is for -server alone and is never sent anywhere
+// else, so a server without a profile holding a token is refused when
+// needToken is set. Without a profile, the selected profile's CA and client
+// certificate are used, but not its server name, which is only valid for
+// its own host.
+func (a *app) serverOptions(server string, needToken bool) ([]spacetimedb.Option, error) {
+	if a.config != nil {
+		for _, p := range a.config.Profiles {
+			if !sameServer(p.ServerURL(), server) {
+				continue
+			}
+			p, err := a.config.Profile(p.Name)
+			if err != nil {
+				return nil, err
+			}
+			if p.Token == "" && needToken {
+				return nil, fmt.Errorf("profile %s for %s has no token", p.Name, server)
+			}
+			opts := p.TLSOptions()
+			if p.Token != "" {
+				opts = append(opts, spacetimedb.WithToken(p.Token))
+			}
+			return opts, nil
+		}
+	}
+	if needToken {
+		return nil, fmt.Errorf("no token for %s, add a profile for it to the config file", server)
+	}
+
+	var opts []spacetimedb.Option
+	if a.profile != nil {
+		p := *a.profile
+		p.ServerName = ""
+		opts = p.TLSOptions()
+	}
+	return opts, nil
+}
+
+func sameServer(a, b string) bool {
+	return a != "" && strings.TrimSuffix(a, "/") == strings.TrimSuffix(b, "/")
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/briheet/spacetime-goclient/spacetimedb/spacetimedbtest"
+)
+
+// deployFixture is a manifest deploying "app" to a main server and "edge"
+// to another one.
+type deployFixture struct {
+	main, other *spacetimedbtest.Server
+	dir         string
+	manifest    string
+	token       string
+}
+
+func newDeployFixture(t *testing.T) *deployFixture {
+	t.Helper()
+	isolate(t)
+	f := &deployFixture{main: spacetimedbtest.NewServer(), other: spacetimedbtest.NewServer(), dir: t.TempDir()}
+	t.Cleanup(f.main.Close)
+	t.Cleanup(f.other.Close)
+	_, f.token = f.main.NewIdentity()
+
+	if err := os.WriteFile(filepath.Join(f.dir, "app.wasm"), []byte("\x00asm v1"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f.manifest = f.write(t, "spacetime.yaml", fmt.Sprintf(`databases:
+  - name: app
+    wasm: app.wasm
+  - name: edge
+    wasm: app.wasm
+    server: %s
+`, f.other.URL))
+	return f
+}
+
+func (f *deployFixture) write(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(f.dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func (f *deployFixture) deploy(args ...string) (string, error) {
+	return runCLI(append([]string{"-server", f.main.URL, "-token", f.token}, args...)...)
+}
+
+func TestDeployUsesEachServersToken(t *testing.T) {
+	f := newDeployFixture(t)
+	owner, otherToken := f.other.NewIdentity()
+	config := f.write(t, "cli.toml", fmt.Sprintf(`[[server_configs]]
+nickname = "other"
+url = %q
+token = %q
+`, f.other.URL, otherToken))
+
+	if out, err := f.deploy("-config", config, "deploy", "-f", f.manifest); err != nil {
+		t.Fatalf("deploy: %v\n%s", err, out)
+	}
+	if f.main.Database("app") == nil {
+		t.Error("app was not created on the main server")
+	}
+	edge := f.other.Database("edge")
+	if edge == nil {
+		t.Fatal("edge was not created on the other server")
+	}
+	if edge.Owner() != owner {
+		t.Errorf("edge is owned by %s, want the other profile's identity %s", edge.Owner(), owner)
+	}
+}
+
+func TestDeployRefusesServerWithoutToken(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{"no config", "", "no token for"},
+		{"profile without token", "[[server_configs]]\nnickname = \"other\"\nurl = %q\n", "has no token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newDeployFixture(t)
+			args := []string{"deploy", "-f", f.manifest}
+			if tt.config != "" {
+				args = append([]string{"-config", f.write(t, "cli.toml", fmt.Sprintf(tt.config, f.other.URL))}, args...)
+			}
+
+			out, err := f.deploy(args...)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("deploy err = %v, want %q\n%s", err, tt.err, out)
+			}
+			if f.main.Database("app") != nil || f.other.Database("edge") != nil {
+				t.Error("deploy published something before refusing")
+			}
+
+			// A dry run sends nothing that needs a token.
+			if out, err := f.deploy(append(args, "-dry-run")...); err != nil {
+				t.Errorf("dry run: %v\n%s", err, out)
+			}
+		})
+	}
+}
+
+func TestDeployClearsExistingOnlyWhenAllowed(t *testing.T) {
+	f := newDeployFixture(t)
+	manifest := f.write(t, "clear.yaml", "databases:\n  - name: app\n    wasm: app.wasm\n    clear: always\n")
+
+	if out, err := f.deploy("deploy", "-f", manifest); err != nil {
+		t.Fatalf("first deploy: %v\n%s", err, out)
+	}
+	f.main.Database("app").AddTable("person", spacetimedbtest.Column{Name: "name", Type: "String"}).Insert("alice")
+
+	out, err := f.deploy("deploy", "-f", manifest)
+	if err == nil || !strings.Contains(err.Error(), "-clear-existing") {
+		t.Fatalf("rerun err = %v, want a refusal\n%s", err, out)
+	}
+	if rows := f.main.Database("app").Table("person").Rows(); len(rows) != 1 {
+		t.Fatalf("%d rows after the refused rerun, want 1", len(rows))
+	}
+
+	if out, err := f.deploy("deploy", "-f", manifest, "-clear-existing"); err != nil {
+		t.Fatalf("deploy -clear-existing: %v\n%s", err, out)
+	}
+	if rows := f.main.Database("app").Table("person").Rows(); len(rows) != 0 {
+		t.Errorf("%d rows after -clear-existing, want none", len(rows))
+	}
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// errInterrupt is returned by readLine when the user presses Ctrl-C.
+var errInterrupt = errors.New("interrupted")
+
+// maxHistory bounds the history kept in memory and on disk.
+const maxHistory = 1000
+
+// lineEditor reads lines with emacs-style editing and history when attached
+// to a terminal, and plain lines otherwise.
+type lineEditor struct {
+	in  *bufio.Reader
+	out io.Writer
+	fd  uintptr
+	tty bool
+
+	history     []string
+	historyFile string
+	fileLines   int // entries in historyFile, including those trimmed from history
+}
+
+func newLineEditor(in *os.File, out io.Writer) *lineEditor {
+	return &lineEditor{
+		in:  bufio.NewReader(in),
+		out: out,
+		fd:  in.Fd(),
+		tty: isTerminal(in.Fd()),
+	}
+}
+
+// loadHistory reads previous entries from path and appends new ones to it.
+// A missing file is not an error.
+func (e *lineEditor) loadHistory(path string) error {
+	e.historyFile = path
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading history: %w", err)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			e.history = append(e.history, line)
+		}
+	}
+	e.fileLines = len(e.history)
+	if len(e.history) > maxHistory {
+		e.history = e.history[len(e.history)-maxHistory:]
+		e.rewriteHistory()
+	}
+	return nil
+}
+
+// addHistory records an entry, skipping repeats of the previous one. The
+// entry is appended to the history file, which is rewritten with the last
+// maxHistory entries once it has grown to twice that.
+func (e *lineEditor) addHistory(entry string) {
+	entry = strings.TrimSpace(entry)
+	if entry == "" || (len(e.history) > 0 && e.history[len(e.history)-1] == entry) {
+		return
+	}
+	e.history = append(e.history, entry)
+	if len(e.history) > maxHistory {
+		e.history = e.history[1:]
+	}
+
+	if e.historyFile == "" {
+		return
+	}
+	f, err := os.OpenFile(e.historyFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return
+	}
+	fmt.Fprintln(f, entry)
+	e.fileLines++
+	f.Close()
+	if e.fileLines >= 2*maxHistory {
+		e.rewriteHistory()
+	}
+}
+
+// rewriteHistory replaces the history file with the entries kept in memory.
+// It writes a temporary file and renames it, so the old file stays intact
+// if anything fails.
+func (e *lineEditor) rewriteHistory() {
+	tmp, err := os.CreateTemp(filepath.Dir(e.historyFile), filepath.Base(e.historyFile)+".*")
+	if err != nil {
+		return
+	}
+	defer os.Remove(tmp.Name())
+	_, err = io.WriteString(tmp, strings.Join(e.history, "\n")+"\n")
+	if err := errors.Join(err, tmp.Chmod(0o600), tmp.Close()); err != nil {
+		return
+	}
+	if os.Rename(tmp.Name(), e.historyFile) == nil {
+		e.fileLines = len(e.history)
+	}
+}
+
+// readLine prompts for and returns one line without its newline. It returns
+// io.EOF at the end of input and errInterrupt on Ctrl-C.
+func (e *lineEditor) readLine(prompt string) (string, error) {
+	if !e.tty {
+		line, err := e.in.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return "", err
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	restore, err := makeRaw(e.fd)
+	if err != nil {
+		e.tty = false
+		return e.readLine(prompt)
+	}
+	defer restore()
+
+	s := &editState{prompt: prompt, out: e.out, histPos: len(e.history)}
+	s.refresh()
+
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+
+		switch r {
+		case '\r', '\n':
+			fmt.Fprint(e.out, "\n")
+			return string(s.buf), nil
+		case 3: // Ctrl-C
+			fmt.Fprint(e.out, "^C\n")
+			return "", errInterrupt
+		case 4: // Ctrl-D
+			if len(s.buf) == 0 {
+				fmt.Fprint(e.out, "\n")
+				return "", io.EOF
+			}
+			s.deleteAt(s.pos)
+		case 127, 8: // Backspace
+			if s.pos > 0 {
+				s.pos--
+				s.deleteAt(s.pos)
+			}
+		case 1: // Ctrl-A
+			s.pos = 0
+		case 5: // Ctrl-E
+			s.pos = len(s.buf)
+		case 2: // Ctrl-B
+			s.move(-1)
+		case 6: // Ctrl-F
+			s.move(1)
+		case 11: // Ctrl-K
+			s.buf = s.buf[:s.pos]
+		case 21: // Ctrl-U
+			s.buf = s.buf[s.pos:]
+			s.pos = 0
+		case 23: // Ctrl-W
+			s.deleteWord()
+		case 12: // Ctrl-L
+			fmt.Fprint(e.out, "\x1b[H\x1b[2J")
+		case 16: // Ctrl-P
+			s.recall(e.history, -1)
+		case 14: // Ctrl-N
+			s.recall(e.history, 1)
+		case 27: // escape sequence
+			e.escape(s)
+		default:
+			if unicode.IsPrint(r) {
+				s.insert(r)
+			}
+		}
+		s.refresh()
+	}
+}
+
+// escape handles the arrow, Home, End and Delete key sequences.
+func (e *lineEditor) escape(s *editState) {
+	b, err := e.in.ReadByte()
+	if err != nil || (b != '[' && b != 'O') {
+		return
+	}
+	b, err = e.in.ReadByte()
+	if err != nil {
+		return
+	}
+
+	switch b {
+	case 'A':
+		s.recall(e.history, -1)
+	case 'B':
+		s.recall(e.history, 1)
+	case 'C':
+		s.move(1)
+	case 'D':
+		s.move(-1)
+	case 'H':
+		s.pos = 0
+	case 'F':
+		s.pos = len(s.buf)
+	case '1', '3', '4', '7', '8':
+		if next, err := e.in.ReadByte(); err != nil || next != '~' {
+			return
+		}
+		switch b {
+		case '1', '7':
+			s.pos = 0
+		case '4', '8':
+			s.pos = len(s.buf)
+		case '3':
+			s.deleteAt(s.pos)
+		}
+	}
+}
+
+// editState is the line being edited.
+type editState struct {
+	prompt string
+	out    io.Writer
+	buf    []rune
+	pos    int
+
+	histPos int
+	pending []rune // the unsaved line while browsing history
+}
+
+func (s *editState) refresh() {
+	fmt.Fprintf(s.out, "\r%s%s\x1b[K", s.prompt, string(s.buf))
+	if back := len(s.buf) - s.pos; back > 0 {
+		fmt.Fprintf(s.out, "\x1b[%dD", back)
+	}
+}
+
+func (s *editState) insert(r rune) {
+	s.buf = append(s.buf, 0)
+	copy(s.buf[s.pos+1:], s.buf[s.pos:])
+	s.buf[s.pos] = r
+	s.pos++
+}
+
+func (s *editState) deleteAt(i int) {
+	if i < len(s.buf) {
+		s.buf = append(s.buf[:i], s.buf[i+1:]...)
+	}
+}
+
+func (s *editState) deleteWord() {
+	start := s.pos
+	for start > 0 && s.buf[start-1] == ' ' {
+		start--
+	}
+	for start > 0 && s.buf[start-1] != ' ' {
+		start--
+	}
+	s.buf = append(s.buf[:start], s.buf[s.pos:]...)
+	s.pos = start
+}
+
+func (s *editState) move(delta int) {
+	s.pos = max(0, min(len(s.buf), s.pos+delta))
+}
+
+// recall steps through history, keeping the line being typed so stepping
+// past the newest entry brings it back.
+func (s *editState) recall(history []string, delta int) {
+	next := s.histPos + delta
+	if next < 0 || next > len(history) {
+		return
+	}
+	if s.histPos == len(history) {
+		s.pending = s.buf
+	}
+	s.histPos = next
+
+	if next == len(history) {
+		s.buf = s.pending
+	} else {
+		s.buf = []rune(history[next])
+	}
+	s.pos = len(s.buf)
+}
@@ -1,119 +1,238 @@
+// Command cmd is a command-line client for SpacetimeDB built entirely on the
+// spacetimedb package, so every subcommand doubles as an end-to-end check of
+// the library against a running server.
+//
+//	go run ./cmd -server http://localhost:3000 -database quickstart-chat sql "SELECT * FROM person"
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
 
+	"github.com/briheet/spacetime-goclient/format"
 	"github.com/briheet/spacetime-goclient/spacetimedb"
 )
 
-// For calling a reducer, first we get a dbID from a database http call, then we get token id, then we have to know before hand about the reducers that we have written in the lib.rs
-// Now we make the call that we are getting after doing a tcp dump ->  sudo tcpdump -i lo port 3000 -A
+// app holds the global flags and the client built from them.
+type app struct {
+	server   string
+	database string
+	token    string
+	output   format.Format
+	config   *spacetimedb.Config // nil without a config file
+	profile  *spacetimedb.Profile
+
+	client spacetimedb.DBClient
+	stdout io.Writer
+	stderr io.Writer
+}
+
+type command struct {
+	usage string
+	help  string
+	run   func(ctx context.Context, a *app, args []string) error
+}
+
+// commands is filled in by init because the commands print their own usage.
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"ping":      {"ping", "check that the server is reachable", runPing},
+		"identity":  {"identity new [-email addr] | verify <identity> | databases <identity>", "create, verify or inspect an identity", runIdentity},
+		"profiles":  {"profiles", "list the server profiles in the config file", runProfiles},
+		"publish":   {"publish [-clear] [-name name] <module.wasm>", "publish a module, named after -database by default", runPublish},
+		"deploy":    {"deploy [-f manifest] [-dry-run] [-clear-existing]", "publish and name the databases a manifest describes", runDeploy},
+		"delete":    {"delete [database]", "delete a database", runDelete},
+		"names":     {"names list [db] | add|remove <name> [db] | replace <old> <new> [db] | set <db> [name...] | lookup <name> | owned <identity>", "manage database names and see who holds them", runNames},
+		"info":      {"info [database]", "show a database's identity, owner and module", runInfo},
+		"logs":      {"logs [-n lines] [-f] [-level level] [database]", "print module logs", runLogs},
+		"sql":       {"sql [<query> | -history file -timing=false]", "run a SQL query, or start a REPL without one", runSQL},
+		"call":      {"call [-raw] [database] <reducer> [args...]", "call a reducer, arguments are parsed by their schema types", runCall},
+		"export":    {"export [-format f] [-dir dir] [table...]", "dump tables to ndjson, csv or columnar files", runExport},
+		"import":    {"import [-reducer r] [-batch n] [-rate n] <file>...", "replay exported rows through an insert reducer", runImport},
+		"subscribe": {"subscribe [-table] [-no-color] <query>...", "print row changes, or redraw the rows, of subscribed queries until interrupted", runSubscribe},
+	}
+}
+
 func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := run(ctx, os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		if !errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintln(os.Stderr, "error:", err)
+		}
+		os.Exit(1)
+	}
+}
 
-	// Need to connect to existing server running on some port
-	spdb, err := spacetimedb.Connect("localhost", "3000", "testDB")
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	a := &app{stdout: stdout, stderr: stderr}
+
+	fs := flag.NewFlagSet("spacetime", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&a.server, "server", os.Getenv("SPACETIME_SERVER"), "server URL, or $SPACETIME_SERVER, the profile's or "+spacetimedb.DefaultURL)
+	fs.StringVar(&a.database, "database", os.Getenv("SPACETIME_DATABASE"), "database name or identity, or $SPACETIME_DATABASE or the profile's")
+	fs.StringVar(&a.token, "token", os.Getenv("SPACETIME_TOKEN"), "identity token, or $SPACETIME_TOKEN or the profile's")
+	profile := fs.String("profile", "", "server profile from the config file, or $"+spacetimedb.ProfileEnv+" or the file's default_server")
+	configFile := fs.String("config", "", "config file, or $"+spacetimedb.ConfigEnv+" or the spacetime CLI's cli.toml")
+	output := fs.String("o", string(format.Table), "output format: table, json, ndjson, csv or yaml")
+	fs.Usage = func() { usage(fs) }
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	f, err := format.Parse(*output)
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+	a.output = f
+	if err := a.loadProfile(*configFile, *profile); err != nil {
+		return err
 	}
 
-	// Defer it to close which does cleanup
-	defer spdb.Disconnect()
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	name, rest := fs.Arg(0), fs.Args()[1:]
+	cmd, ok := commands[name]
+	if !ok {
+		fs.Usage()
+		return fmt.Errorf("unknown command %q", name)
+	}
 
-	// First ping and check if it works or not
-	err = spdb.Ping()
+	var opts []spacetimedb.Option
+	if a.onProfileServer() {
+		opts = a.profile.Options()
+	}
+	opts = append(opts,
+		spacetimedb.WithURL(a.server),
+		spacetimedb.WithDatabase(a.database),
+		spacetimedb.WithToken(a.token),
+	)
+	client, err := spacetimedb.Connect(ctx, opts...)
 	if err != nil {
-		log.Fatal(err)
-	} else {
-		log.Println("Successfully connected, you are good to go!")
+		return err
 	}
+	defer client.Disconnect()
+	a.client = client
 
-	// Create Identity
-	identity, token, err := spdb.CreateIdentity()
-	if err != nil {
-		log.Fatal(err)
+	return cmd.run(ctx, a, rest)
+}
+
+func usage(fs *flag.FlagSet) {
+	out := fs.Output()
+	fmt.Fprintf(out, "Usage: spacetime [flags] <command> [args]\n\nCommands:\n")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(out, "  %-50s %s\n", commands[name].usage, commands[name].help)
 	}
-	log.Println("identity:", identity)
-	log.Println("token:", token)
 
-	dbIden, _, _, _, err := spdb.GetDatabaseInfo("quickstart-chat")
-	if err != nil {
-		log.Fatal(err)
-	}
-	log.Println(dbIden)
-
-	// reducerName := "send_message"
-	// err = spdb.SendMessageDatabase(reducerName, dbIden, token, "Hello, world")
-	// if err != nil {
-	// 	log.Fatal(err)
-	// }
-	//
-	// log.Println("Successfully called reducer from golang")
-
-	// err = spdb.DeleteDatabase(dbIden, token)
-	// if err != nil {
-	// 	log.Fatal(err)
-	// }
-
-	// 5.
-	// names, err := spdb.GetDatabaseNames(dbIden)
-	// if err != nil {
-	// 	log.Fatalf("Error fetching names: %v", err)
-	// }
-	// log.Println("Names:", names)
-
-	// 6.
-	// err = spdb.AddDatabaseName(dbIden, "mychat", token)
-	// if err != nil {
-	// 	log.Fatalf("Failed to name database: %v", err)
-	// }
-	// log.Println("Database name assigned successfully.")
-
-	// 7.
-	// id, err := spdb.GetDatabaseIdentity("quickstart-chat")
-	// if err != nil {
-	// 	log.Fatalf("Failed to fetch identity: %v", err)
-	// }
-	// log.Println("Database identity:", id)
-
-	// 8. Websocket
-	// conn, err := spdb.WebsocketSubscribe("quickstart-chat", token, "")
-	// if err != nil {
-	// 	log.Fatal(err)
-	// }
-	// defer conn.Close()
-	//
-	// for {
-	// 	_, msg, err := conn.ReadMessage()
-	// 	if err != nil {
-	// 		log.Println("WebSocket read error:", err)
-	// 		break
-	// 	}
-	// 	log.Println("Received message:", string(msg))
-	// }
-
-	// 9.
-	// logs, err := spdb.GetDatabaseLogs("quickstart-chat", token, 100, false)
-	// if err != nil {
-	// 	log.Fatalf("failed to get logs: %v", err)
-	// }
-	// defer logs.Close()
-	//
-	// scanner := bufio.NewScanner(logs)
-	// for scanner.Scan() {
-	// 	fmt.Println(scanner.Text())
-	// }
-
-	// 10.
-	dbName := "quickstart-chat"
-	results, err := spdb.RunSQLQuery(`SELECT * FROM person;`, token, dbName)
-	if err != nil {
-		log.Fatal(err)
+	fmt.Fprintf(out, "\nFlags:\n")
+	fs.PrintDefaults()
+}
+
+// loadProfile reads the config file and fills in the server, database and
+// token not given by flags or the environment from the selected profile.
+// The profile's token, which may be the file's spacetimedb_token, is only
+// used when the server is the profile's own, so -server pointing elsewhere
+// never receives it. A missing or unreadable default config file is not an
+// error unless a profile was asked for.
+func (a *app) loadProfile(path, name string) error {
+	explicit := path != "" || name != "" || os.Getenv(spacetimedb.ProfileEnv) != ""
+
+	cfg, err := spacetimedb.LoadConfig(path)
+	switch {
+	case err != nil && explicit:
+		return err
+	case err != nil:
+		if !errors.Is(err, os.ErrNotExist) {
+			fmt.Fprintln(a.stderr, "warning:", err)
+		}
+	default:
+		a.config = cfg
+		p, err := cfg.Profile(name)
+		if err != nil && !errors.Is(err, spacetimedb.ErrNoProfile) {
+			return err
+		}
+		if p != nil {
+			a.profile = p
+			a.server = firstOf(a.server, p.ServerURL())
+			a.database = firstOf(a.database, p.Database)
+		}
+	}
+	a.server = firstOf(a.server, spacetimedb.DefaultURL)
+	if a.onProfileServer() {
+		a.token = firstOf(a.token, a.profile.Token)
+	}
+	return nil
+}
+
+// onProfileServer reports whether the server is the selected profile's, so
+// that its token and TLS settings may be used. A profile without a host
+// stands for the default server.
+func (a *app) onProfileServer() bool {
+	return a.profile != nil && sameServer(a.server, firstOf(a.profile.ServerURL(), spacetimedb.DefaultURL))
+}
+
+func firstOf(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// flags returns a flag set for a subcommand that reports errors instead of
+// exiting.
+func (a *app) flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(a.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(a.stderr, "Usage: spacetime %s\n", commands[name].usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// databaseArg returns the database named by args[i], falling back to the
+// -database flag.
+func (a *app) databaseArg(args []string, i int) (string, error) {
+	if len(args) > i && args[i] != "" {
+		return args[i], nil
 	}
+	if a.database == "" {
+		return "", fmt.Errorf("no database given, pass one or set -database")
+	}
+	return a.database, nil
+}
 
-	for _, res := range results {
-		fmt.Printf("Schema: %+v\n", res.Schema)
-		fmt.Printf("Rows: %+v\n", res.Rows)
+// requireToken fails commands that cannot run anonymously.
+func (a *app) requireToken() error {
+	if a.token == "" {
+		return fmt.Errorf("this command needs a token, set -token or $SPACETIME_TOKEN")
 	}
+	return nil
+}
+
+// print renders r in the format chosen with -o.
+func (a *app) print(r format.Result) error {
+	return format.Write(a.stdout, a.output, r)
+}
 
+func (a *app) printf(format string, args ...any) {
+	fmt.Fprintf(a.stdout, format, args...)
 }
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/briheet/spacetime-goclient/spacetimedb/spacetimedbtest"
+)
+
+// isolate keeps the environment and any real config file out of a test.
+func isolate(t *testing.T) {
+	t.Helper()
+	for _, env := range []string{"SPACETIME_SERVER", "SPACETIME_DATABASE", "SPACETIME_TOKEN", "SPACETIME_PROFILE", "SPACETIME_CONFIG"} {
+		t.Setenv(env, "")
+	}
+	t.Setenv("HOME", t.TempDir())
+}
+
+// runCLI runs the command line and returns what it printed.
+func runCLI(args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	err := run(context.Background(), args, &stdout, &stderr)
+	return stdout.String() + stderr.String(), err
+}
+
+// The profile's token, or the file's spacetimedb_token, must only reach the
+// profile's own server.
+func TestProfileTokenStaysOnItsServer(t *testing.T) {
+	isolate(t)
+	prod, other := spacetimedbtest.NewServer(), spacetimedbtest.NewServer()
+	defer prod.Close()
+	defer other.Close()
+	owner, prodToken := prod.NewIdentity()
+	_, loginToken := other.NewIdentity()
+
+	dir := t.TempDir()
+	wasm := filepath.Join(dir, "app.wasm")
+	config := filepath.Join(dir, "cli.toml")
+	must(t, os.WriteFile(wasm, []byte("\x00asm"), 0o644))
+	must(t, os.WriteFile(config, []byte(fmt.Sprintf(`default_server = "prod"
+spacetimedb_token = %q
+
+[[server_configs]]
+nickname = "prod"
+url = %q
+token = %q
+
+[[server_configs]]
+nickname = "local"
+`, loginToken, prod.URL, prodToken)), 0o644))
+
+	tests := []struct {
+		name    string
+		args    []string
+		created *spacetimedbtest.Server
+		err     string
+	}{
+		{"profile server", nil, prod, ""},
+		{"same server given", []string{"-server", prod.URL + "/"}, prod, ""},
+		{"other server", []string{"-server", other.URL}, nil, "needs a token"},
+		{"login token elsewhere", []string{"-profile", "local", "-server", other.URL}, nil, "needs a token"},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			name := fmt.Sprintf("app%d", i)
+			args := append([]string{"-config", config}, tt.args...)
+			out, err := runCLI(append(args, "publish", "-name", name, wasm)...)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want %q\n%s", err, tt.err, out)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("publish: %v\n%s", err, out)
+			}
+			if db := tt.created.Database(name); db == nil || db.Owner() != owner {
+				t.Errorf("%s not published with the profile's token", name)
+			}
+		})
+	}
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/briheet/spacetime-goclient/format"
+	"github.com/briheet/spacetime-goclient/spacetimedb"
+)
+
+const replHelp = `Statements end with ';' and may span several lines.
+
+  \d            list tables
+  \d <table>    describe a table
+  \dr           list reducers
+  \timing       toggle query timing
+  \?            show this help
+  \q            quit
+`
+
+// repl is an interactive SQL session against one database.
+type repl struct {
+	a      *app
+	db     string
+	editor *lineEditor
+	timing bool
+	schema *spacetimedb.ModuleSchema
+
+	mu     sync.Mutex
+	cancel context.CancelFunc // cancels the running query, if any
+}
+
+func runREPL(ctx context.Context, a *app, args []string) error {
+	fs := a.flags("sql")
+	history := fs.String("history", defaultHistoryFile(), "history file, empty to disable")
+	timing := fs.Bool("timing", true, "print how long each query took")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	db, err := a.databaseArg(nil, 0)
+	if err != nil {
+		return err
+	}
+
+	r := &repl{a: a, db: db, editor: newLineEditor(os.Stdin, a.stdout), timing: *timing}
+	if *history != "" {
+		if err := r.editor.loadHistory(*history); err != nil {
+			fmt.Fprintln(a.stderr, "warning:", err)
+		}
+	}
+	if r.editor.tty {
+		a.printf("Connected to %s on %s. Type \\? for help.\n", db, a.server)
+	}
+
+	// Ctrl-C cancels the running query instead of the session. At the
+	// prompt a terminal reads it as a key; otherwise it ends the session.
+	signal.Reset(os.Interrupt)
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go r.interrupt(ctx, interrupts, cancel)
+
+	return r.loop(ctx)
+}
+
+// interrupt cancels the running query on each signal, or the session when
+// none is running.
+func (r *repl) interrupt(ctx context.Context, signals <-chan os.Signal, cancelSession context.CancelFunc) {
+	for {
+		select {
+		case <-signals:
+		case <-ctx.Done():
+			return
+		}
+		r.mu.Lock()
+		cancel := r.cancel
+		r.mu.Unlock()
+		if cancel == nil {
+			cancel = cancelSession
+		}
+		cancel()
+	}
+}
+
+func defaultHistoryFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".spacetime_sql_history")
+}
+
+func (r *repl) loop(ctx context.Context) error {
+	var stmt []string
+	for ctx.Err() == nil {
+		prompt := r.db + "> "
+		if len(stmt) > 0 {
+			prompt = strings.Repeat(" ", len(r.db)-1) + "-> "
+		}
+		if !r.editor.tty {
+			prompt = ""
+		}
+
+		line, err := r.editor.readLine(prompt)
+		switch {
+		case errors.Is(err, errInterrupt):
+			stmt = nil
+			continue
+		case errors.Is(err, io.EOF):
+			if len(stmt) > 0 {
+				r.exec(ctx, strings.Join(stmt, "\n"))
+			}
+			return nil
+		case err != nil:
+			return err
+		}
+
+		trimmed := strings.TrimSpace(line)
+		if len(stmt) == 0 && strings.HasPrefix(trimmed, `\`) {
+			r.editor.addHistory(trimmed)
+			if quit := r.meta(trimmed); quit {
+				return nil
+			}
+			continue
+		}
+		if trimmed == "" && len(stmt) == 0 {
+			continue
+		}
+
+		stmt = append(stmt, line)
+		if strings.HasSuffix(trimmed, ";") {
+			query := strings.Join(stmt, "\n")
+			r.editor.addHistory(strings.Join(strings.Fields(query), " "))
+			r.exec(ctx, query)
+			stmt = nil
+		}
+	}
+	return nil
+}
+
+// exec runs one statement and prints its results or error. Ctrl-C cancels
+// the statement.
+func (r *repl) exec(ctx context.Context, query string) {
+	query = strings.TrimSuffix(strings.TrimSpace(query), ";")
+	if query == "" {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	r.mu.Lock()
+	r.cancel = cancel
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		r.cancel = nil
+		r.mu.Unlock()
+		cancel()
+	}()
+
+	start := time.Now()
+	results, err := r.a.client.RunSQLQueryContext(ctx, query, r.a.token, r.db)
+	elapsed := time.Since(start)
+	if errors.Is(err, context.Canceled) {
+		fmt.Fprintln(r.a.stderr, "query cancelled")
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(r.a.stderr, "error:", err)
+		return
+	}
+
+	rows := 0
+	for _, res := range results {
+		out, err := format.FromSQL(res)
+		if err == nil {
+			err = r.a.print(out)
+		}
+		if err != nil {
+			fmt.Fprintln(r.a.stderr, "error:", err)
+		}
+		rows += len(res.Rows)
+	}
+	if r.timing {
+		r.a.printf("(%d %s, %s)\n", rows, plural(rows, "row"), elapsed.Round(time.Microsecond))
+	}
+}
+
+// meta runs a backslash command and reports whether to quit.
+func (r *repl) meta(cmd string) bool {
+	fields := strings.Fields(cmd)
+	switch fields[0] {
+	case `\q`:
+		return true
+	case `\?`:
+		r.a.printf("%s", replHelp)
+	case `\timing`:
+		r.timing = !r.timing
+		r.a.printf("Timing is %s.\n", map[bool]string{true: "on", false: "off"}[r.timing])
+	case `\d`:
+		schema, err := r.loadSchema()
+		if err != nil {
+			fmt.Fprintln(r.a.stderr, "error:", err)
+			return false
+		}
+		if len(fields) > 1 {
+			r.describeTable(schema, fields[1])
+		} else {
+			r.listTables(schema)
+		}
+	case `\dr`:
+		schema, err := r.loadSchema()
+		if err != nil {
+			fmt.Fprintln(r.a.stderr, "error:", err)
+			return false
+		}
+		r.listReducers(schema)
+	default:
+		fmt.Fprintf(r.a.stderr, "unknown command %s, try \\?\n", fields[0])
+	}
+	return false
+}
+
+// loadSchema fetches the module schema once per session.
+func (r *repl) loadSchema() (*spacetimedb.ModuleSchema, error) {
+	if r.schema == nil {
+		schema, err := r.a.client.GetDatabaseSchema(r.db)
+		if err != nil {
+			return nil, err
+		}
+		r.schema = schema
+	}
+	return r.schema, nil
+}
+
+func (r *repl) listTables(schema *spacetimedb.ModuleSchema) {
+	out := format.Result{Columns: []string{"table", "columns", "access"}}
+	for _, t := range schema.Tables {
+		access := "private"
+		if t.Public {
+			access = "public"
+		}
+		out.Rows = append(out.Rows, []any{t.Name, len(t.Columns), access})
+	}
+	r.a.print(out)
+}
+
+func (r *repl) describeTable(schema *spacetimedb.ModuleSchema, name string) {
+	t, ok := schema.Table(name)
+	if !ok {
+		fmt.Fprintf(r.a.stderr, "no table named %s\n", name)
+		return
+	}
+
+	out := format.Result{Columns: []string{"column", "type", "key"}}
+	for i, c := range t.Columns {
+		key := ""
+		if slices.Contains(t.PrimaryKey, i) {
+			key = "primary"
+		}
+		out.Rows = append(out.Rows, []any{c.Name, c.Type.String(), key})
+	}
+	r.a.print(out)
+}
+
+func (r *repl) listReducers(schema *spacetimedb.ModuleSchema) {
+	for _, rd := range schema.Reducers {
+		params := make([]string, len(rd.Params))
+		for i, p := range rd.Params {
+			params[i] = p.Name + ": " + p.Type.String()
+		}
+		line := fmt.Sprintf("%s(%s)", rd.Name, strings.Join(params, ", "))
+		if rd.Lifecycle != "" {
+			line += " [" + rd.Lifecycle + "]"
+		}
+		r.a.printf("%s\n", line)
+	}
+}
+
+func plural(n int, word string) string {
+	if n == 1 {
+		return word
+	}
+	return word + "s"
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/briheet/spacetime-goclient/format"
+	"github.com/briheet/spacetime-goclient/spacetimedb"
+)
+
+const (
+	colorReset  = "\x1b[0m"
+	colorRed    = "\x1b[31m"
+	colorGreen  = "\x1b[32m"
+	colorYellow = "\x1b[33m"
+	colorDim    = "\x1b[2m"
+)
+
+// viewer prints the changes of a subscription, or redraws its rows, as
+// messages arrive.
+type viewer struct {
+	a      *app
+	db     string
+	schema *spacetimedb.ModuleSchema
+	cache  *spacetimedb.Cache
+	color  bool
+}
+
+func runSubscribe(ctx context.Context, a *app, args []string) error {
+	fs := a.flags("subscribe")
+	table := fs.Bool("table", false, "redraw the subscribed tables instead of printing changes")
+	interval := fs.Duration("interval", 250*time.Millisecond, "how often -table redraws at most")
+	noColor := fs.Bool("no-color", false, "do not colour changes")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("usage: spacetime %s", commands["subscribe"].usage)
+	}
+	db, err := a.databaseArg(nil, 0)
+	if err != nil {
+		return err
+	}
+
+	// Without the schema, rows still show, only with numbered columns and
+	// raw values.
+	schema, err := a.client.GetDatabaseSchema(db)
+	if err != nil {
+		fmt.Fprintln(a.stderr, "warning:", err)
+		schema = nil
+	}
+
+	v := &viewer{
+		a:      a,
+		db:     db,
+		schema: schema,
+		cache:  spacetimedb.NewCache(schema),
+		color:  !*noColor && os.Getenv("NO_COLOR") == "" && stdoutIsTerminal(a),
+	}
+
+	conn, err := a.client.OpenConnection(ctx, db, a.token, "")
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Subscribe(ctx, fs.Args()...); err != nil {
+		return err
+	}
+
+	var redraw <-chan time.Time
+	if *table {
+		ticker := time.NewTicker(*interval)
+		defer ticker.Stop()
+		redraw = ticker.C
+	}
+
+	var dirty bool
+	var last *spacetimedb.CacheUpdate
+	for {
+		select {
+		case msg, ok := <-conn.Messages():
+			if !ok {
+				if err := conn.Err(); !errors.Is(err, context.Canceled) {
+					return err
+				}
+				return nil
+			}
+			update, err := v.cache.Apply(msg.Data)
+			if err != nil {
+				return err
+			}
+			if update == nil {
+				continue
+			}
+			if *table {
+				dirty, last = true, update
+				continue
+			}
+			if err := v.printChanges(update); err != nil {
+				return err
+			}
+		case <-redraw:
+			if dirty {
+				v.drawTables(last)
+				dirty = false
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func stdoutIsTerminal(a *app) bool {
+	f, ok := a.stdout.(*os.File)
+	return ok && isTerminal(f.Fd())
+}
+
+func (v *viewer) paint(color, s string) string {
+	if !v.color {
+		return s
+	}
+	return color + s + colorReset
+}
+
+// fields returns a table's columns, or numbered untyped ones for a row of
+// width n when the schema is unknown.
+func (v *viewer) fields(table string, n int) []spacetimedb.Field {
+	if v.schema != nil {
+		if def, ok := v.schema.Table(table); ok {
+			return def.Columns
+		}
+	}
+	fields := make([]spacetimedb.Field, n)
+	for i := range fields {
+		fields[i].Name = fmt.Sprintf("column%d", i)
+	}
+	return fields
+}
+
+// printChanges prints one line per change, diff style, or one JSON object
+// per change with -o json or -o ndjson.
+func (v *viewer) printChanges(update *spacetimedb.CacheUpdate) error {
+	if v.a.output == format.JSON || v.a.output == format.NDJSON {
+		return v.printEvents(update)
+	}
+
+	if update.Reducer != "" {
+		v.a.printf("%s\n", v.paint(colorDim, fmt.Sprintf("@ %s %s", time.Now().Format(time.TimeOnly), update.Reducer)))
+	}
+	for _, c := range update.Changes {
+		switch c.Kind {
+		case spacetimedb.RowInsert:
+			v.a.printf("%s\n", v.paint(colorGreen, "+ "+c.Table+"  "+v.describe(c.Table, c.New)))
+		case spacetimedb.RowDelete:
+			v.a.printf("%s\n", v.paint(colorRed, "- "+c.Table+"  "+v.describe(c.Table, c.Old)))
+		case spacetimedb.RowUpdate:
+			v.a.printf("%s\n", v.paint(colorYellow, "~ "+c.Table+"  "+v.describeUpdate(c.Table, c.Old, c.New)))
+		}
+	}
+	return nil
+}
+
+// describe renders a row as name=value pairs.
+func (v *viewer) describe(table string, row []any) string {
+	fields := v.fields(table, len(row))
+	values := format.Row(fields, row)
+	parts := make([]string, len(values))
+	for i, val := range values {
+		parts[i] = fields[i].Name + "=" + format.Cell(val)
+	}
+	return strings.Join(parts, " ")
+}
+
+// describeUpdate renders the primary key of a row and the columns that
+// changed, as name=old→new.
+func (v *viewer) describeUpdate(table string, old, new []any) string {
+	fields := v.fields(table, len(new))
+	before, after := format.Row(fields, old), format.Row(fields, new)
+
+	var key []int
+	if v.schema != nil {
+		if def, ok := v.schema.Table(table); ok {
+			key = def.PrimaryKey
+		}
+	}
+
+	var parts []string
+	for _, i := range key {
+		if i < len(after) {
+			parts = append(parts, fields[i].Name+"="+format.Cell(after[i]))
+		}
+	}
+	for i := range after {
+		was, now := "", format.Cell(after[i])
+		if i < len(before) {
+			was = format.Cell(before[i])
+		}
+		if was != now {
+			parts = append(parts, fields[i].Name+"="+was+"→"+now)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// printEvents prints each change as a JSON object with the rows keyed by
+// column name.
+func (v *viewer) printEvents(update *spacetimedb.CacheUpdate) error {
+	object := func(table string, row []any) map[string]any {
+		if row == nil {
+			return nil
+		}
+		fields := v.fields(table, len(row))
+		values := format.Row(fields, row)
+		out := make(map[string]any, len(values))
+		for i, val := range values {
+			out[fields[i].Name] = val
+		}
+		return out
+	}
+
+	for _, c := range update.Changes {
+		event := map[string]any{"table": c.Table, "op": c.Kind.String()}
+		if update.Reducer != "" {
+			event["reducer"] = update.Reducer
+		}
+		if c.Old != nil {
+			event["old"] = object(c.Table, c.Old)
+		}
+		if c.New != nil {
+			event["new"] = object(c.Table, c.New)
+		}
+		b, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		v.a.printf("%s\n", b)
+	}
+	return nil
+}
+
+// drawTables clears the screen and prints every cached table.
+func (v *viewer) drawTables(last *spacetimedb.CacheUpdate) {
+	if stdoutIsTerminal(v.a) {
+		v.a.printf("\x1b[H\x1b[2J")
+	}
+
+	status := fmt.Sprintf("%s on %s, updated %s", v.db, v.a.server, time.Now().Format(time.TimeOnly))
+	if last != nil && last.Reducer != "" {
+		status += " by " + last.Reducer
+	}
+	v.a.printf("%s\n", v.paint(colorDim, status))
+
+	for _, name := range v.cache.Tables() {
+		rows := v.cache.Rows(name)
+		width := 0
+		if len(rows) > 0 {
+			width = len(rows[0])
+		}
+		v.a.printf("\n%s (%d %s)\n", name, len(rows), plural(len(rows), "row"))
+		if err := format.Write(v.a.stdout, format.Table, format.FromRows(v.fields(name, width), rows)); err != nil {
+			fmt.Fprintln(v.a.stderr, "error:", err)
+		}
+	}
+}
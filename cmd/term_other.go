@@ -0,0 +1,14 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+// Line editing needs raw terminal mode, which is only implemented for
+// Linux. Elsewhere the REPL reads plain lines.
+
+func isTerminal(fd uintptr) bool { return false }
+
+func makeRaw(fd uintptr) (func(), error) {
+	return nil, errors.New("raw terminal mode is not supported on this platform")
+}
@@ -0,0 +1,779 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/briheet/spacetime-goclient/format"
+	"github.com/briheet/spacetime-goclient/spacetimedb"
+)
+
+// Export formats. Every file starts with the table's schema, so import
+// needs nothing but the file.
+const (
+	dumpNDJSON   = "ndjson"   // a {"schema":...} line, then one JSON array per row
+	dumpCSV      = "csv"      // a "# {"schema":...}" line, a header, then one record per row
+	dumpColumnar = "columnar" // one JSON object holding the schema and a value array per column
+)
+
+var dumpExtensions = map[string]string{
+	dumpNDJSON:   ".ndjson",
+	dumpCSV:      ".csv",
+	dumpColumnar: ".columnar.json",
+}
+
+// dumpSchema describes the table a dump holds. Values are kept in SATS
+// JSON, exactly as the server sent them, so they can be replayed as
+// reducer arguments.
+type dumpSchema struct {
+	Table      string       `json:"table"`
+	Database   string       `json:"database"`
+	ExportedAt time.Time    `json:"exported_at"`
+	Rows       int          `json:"rows"`
+	Columns    []dumpColumn `json:"columns"`
+}
+
+type dumpColumn struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+func runExport(ctx context.Context, a *app, args []string) error {
+	fs := a.flags("export")
+	kind := fs.String("format", dumpNDJSON, "file format: ndjson, csv or columnar")
+	dir := fs.String("dir", ".", "directory to write the files to")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	ext, ok := dumpExtensions[*kind]
+	if !ok {
+		return fmt.Errorf("unknown export format %q, want ndjson, csv or columnar", *kind)
+	}
+	db, err := a.databaseArg(nil, 0)
+	if err != nil {
+		return err
+	}
+
+	tables := fs.Args()
+	if len(tables) == 0 {
+		schema, err := a.client.GetDatabaseSchema(db)
+		if err != nil {
+			return fmt.Errorf("listing tables: %w", err)
+		}
+		for _, t := range schema.Tables {
+			tables = append(tables, t.Name)
+		}
+	}
+	if err := os.MkdirAll(*dir, 0o755); err != nil {
+		return err
+	}
+
+	for _, table := range tables {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if !isIdentifier(table) {
+			return fmt.Errorf("%q is not a table name", table)
+		}
+		path := filepath.Join(*dir, table+ext)
+		n, err := a.exportTable(db, table, *kind, path)
+		if err != nil {
+			return fmt.Errorf("exporting %s: %w", table, err)
+		}
+		a.printf("%s: %d %s to %s\n", table, n, plural(n, "row"), path)
+	}
+	return nil
+}
+
+// isIdentifier reports whether s is a plain SQL identifier, which is all a
+// module's table names can be. Anything else is refused rather than quoted,
+// since it is spliced into a query and a file name.
+func isIdentifier(s string) bool {
+	for i, r := range s {
+		switch {
+		case r == '_', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case i > 0 && r >= '0' && r <= '9':
+		default:
+			return false
+		}
+	}
+	return s != ""
+}
+
+// exportTable writes every row of table to path and returns the row count.
+// The rows come from a single SELECT and are held in memory until written,
+// so a table has to fit in memory, and within what the server returns for
+// one query, to be exported.
+func (a *app) exportTable(db, table, kind, path string) (int, error) {
+	results, err := a.client.RunSQLQuery("SELECT * FROM "+table, a.token, db)
+	if err != nil {
+		return 0, err
+	}
+	if len(results) != 1 {
+		return 0, fmt.Errorf("expected one result, got %d", len(results))
+	}
+	fields, err := results[0].Columns()
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := format.SQLRows(results[0])
+	if err != nil {
+		return 0, err
+	}
+	schema := dumpSchema{Table: table, Database: db, ExportedAt: time.Now().UTC(), Rows: len(rows)}
+	for _, f := range fields {
+		schema.Columns = append(schema.Columns, dumpColumn{Name: f.Name, Type: f.Type.String()})
+	}
+	for _, values := range rows {
+		if len(values) != len(fields) {
+			return 0, fmt.Errorf("unexpected row %v", values)
+		}
+	}
+
+	// Write to a temporary file first so a failed export never leaves a
+	// truncated dump behind.
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	switch kind {
+	case dumpNDJSON:
+		err = writeNDJSONDump(w, schema, rows)
+	case dumpCSV:
+		err = writeCSVDump(w, schema, rows)
+	case dumpColumnar:
+		err = writeColumnarDump(w, schema, rows)
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(rows), os.Rename(tmp.Name(), path)
+}
+
+func writeNDJSONDump(w io.Writer, schema dumpSchema, rows [][]any) error {
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(map[string]any{"schema": schema}); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if err := enc.Encode(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeCSVDump(w io.Writer, schema dumpSchema, rows [][]any) error {
+	header, err := json.Marshal(map[string]any{"schema": schema})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "# %s\n", header)
+
+	cw := csv.NewWriter(w)
+	names := make([]string, len(schema.Columns))
+	for i, c := range schema.Columns {
+		names[i] = c.Name
+	}
+	if err := cw.Write(names); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		cells := make([]string, len(row))
+		for i, v := range row {
+			cell, err := csvCell(schema.Columns[i], v)
+			if err != nil {
+				return fmt.Errorf("column %s: %w", schema.Columns[i].Name, err)
+			}
+			cells[i] = cell
+		}
+		if err := cw.Write(cells); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvCell writes String columns as plain text and everything else as
+// SATS JSON.
+func csvCell(c dumpColumn, v any) (string, error) {
+	if s, ok := v.(string); ok && c.Type == "String" {
+		return s, nil
+	}
+	b, err := json.Marshal(v)
+	return string(b), err
+}
+
+func writeColumnarDump(w io.Writer, schema dumpSchema, rows [][]any) error {
+	columns := make([][]any, len(schema.Columns))
+	for i := range columns {
+		columns[i] = make([]any, len(rows))
+		for j, row := range rows {
+			columns[i][j] = row[i]
+		}
+	}
+	// The schema goes first, so readers find it without skipping the values.
+	return json.NewEncoder(w).Encode(struct {
+		Schema  dumpSchema `json:"schema"`
+		Columns [][]any    `json:"columns"`
+	}{schema, columns})
+}
+
+// dump is a file being imported.
+type dump struct {
+	schema dumpSchema
+	next   func() ([]any, error) // returns io.EOF after the last row
+	close  func() error
+}
+
+// openDump reads a file written by export, telling the format from its
+// extension.
+func openDump(path string) (*dump, error) {
+	if strings.HasSuffix(path, dumpExtensions[dumpColumnar]) {
+		d, err := readColumnarDump(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+		return d, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	r := bufio.NewReader(f)
+
+	var d *dump
+	switch {
+	case strings.HasSuffix(path, dumpExtensions[dumpNDJSON]), strings.HasSuffix(path, ".jsonl"):
+		d, err = readNDJSONDump(r)
+	case strings.HasSuffix(path, dumpExtensions[dumpCSV]):
+		d, err = readCSVDump(r)
+	default:
+		err = fmt.Errorf("unknown file type, want %s, %s or %s",
+			dumpExtensions[dumpNDJSON], dumpExtensions[dumpCSV], dumpExtensions[dumpColumnar])
+	}
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	d.close = f.Close
+	return d, nil
+}
+
+func decodeNumbers(data []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
+
+func readNDJSONDump(r *bufio.Reader) (*dump, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	var header struct {
+		Schema *dumpSchema `json:"schema"`
+	}
+	if err := dec.Decode(&header); err != nil || header.Schema == nil {
+		return nil, fmt.Errorf("missing schema line")
+	}
+
+	d := &dump{schema: *header.Schema}
+	d.next = func() ([]any, error) {
+		var row []any
+		if err := dec.Decode(&row); err != nil {
+			return nil, err
+		}
+		return row, nil
+	}
+	return d, nil
+}
+
+func readCSVDump(r *bufio.Reader) (*dump, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("missing schema line")
+	}
+	var header struct {
+		Schema *dumpSchema `json:"schema"`
+	}
+	rest, ok := strings.CutPrefix(line, "# ")
+	if !ok || decodeNumbers([]byte(rest), &header) != nil || header.Schema == nil {
+		return nil, fmt.Errorf("missing schema line")
+	}
+
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = len(header.Schema.Columns)
+	if _, err := cr.Read(); err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+
+	d := &dump{schema: *header.Schema}
+	d.next = func() ([]any, error) {
+		record, err := cr.Read()
+		if err != nil {
+			return nil, err
+		}
+		row := make([]any, len(record))
+		for i, cell := range record {
+			if d.schema.Columns[i].Type == "String" {
+				row[i] = cell
+				continue
+			}
+			if err := decodeNumbers([]byte(cell), &row[i]); err != nil {
+				return nil, fmt.Errorf("column %s: %w", d.schema.Columns[i].Name, err)
+			}
+		}
+		return row, nil
+	}
+	return d, nil
+}
+
+// readColumnarDump streams a columnar file. A row takes one value from each
+// column, so every column gets its own reader positioned at its values, and
+// memory use does not grow with the file.
+func readColumnarDump(path string) (*dump, error) {
+	schema, n, err := columnarHeader(path)
+	if err != nil {
+		return nil, err
+	}
+	if n != len(schema.Columns) {
+		return nil, fmt.Errorf("schema has %d columns, file has %d", len(schema.Columns), n)
+	}
+
+	var files []*os.File
+	closeAll := func() error {
+		var errs []error
+		for _, f := range files {
+			errs = append(errs, f.Close())
+		}
+		return errors.Join(errs...)
+	}
+	decs := make([]*json.Decoder, n)
+	for c := range decs {
+		f, err := os.Open(path)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		files = append(files, f)
+		decs[c] = json.NewDecoder(bufio.NewReader(f))
+		decs[c].UseNumber()
+		if err := seekColumn(decs[c], c); err != nil {
+			closeAll()
+			return nil, fmt.Errorf("column %s: %w", schema.Columns[c].Name, err)
+		}
+	}
+
+	d := &dump{schema: *schema, close: closeAll}
+	d.next = func() ([]any, error) {
+		if len(decs) == 0 || !decs[0].More() {
+			for c, dec := range decs {
+				if dec.More() {
+					return nil, fmt.Errorf("column %s is longer than column %s", schema.Columns[c].Name, schema.Columns[0].Name)
+				}
+			}
+			return nil, io.EOF
+		}
+		row := make([]any, len(decs))
+		for c, dec := range decs {
+			if !dec.More() {
+				return nil, fmt.Errorf("column %s is short", schema.Columns[c].Name)
+			}
+			if err := dec.Decode(&row[c]); err != nil {
+				return nil, fmt.Errorf("column %s: %w", schema.Columns[c].Name, err)
+			}
+		}
+		return row, nil
+	}
+	return d, nil
+}
+
+// columnarHeader reads the schema of a columnar file and counts its
+// columns, skipping over their values.
+func columnarHeader(path string) (*dumpSchema, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+	dec := json.NewDecoder(bufio.NewReader(f))
+	dec.UseNumber()
+
+	if err := expectDelim(dec, '{'); err != nil {
+		return nil, 0, err
+	}
+	var schema *dumpSchema
+	columns := 0
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return nil, 0, err
+		}
+		switch key {
+		case "schema":
+			if err := dec.Decode(&schema); err != nil {
+				return nil, 0, fmt.Errorf("schema: %w", err)
+			}
+		case "columns":
+			if err := expectDelim(dec, '['); err != nil {
+				return nil, 0, err
+			}
+			for ; dec.More(); columns++ {
+				if err := skipValue(dec); err != nil {
+					return nil, 0, err
+				}
+			}
+			if err := expectDelim(dec, ']'); err != nil {
+				return nil, 0, err
+			}
+		default:
+			if err := skipValue(dec); err != nil {
+				return nil, 0, err
+			}
+		}
+	}
+	if schema == nil {
+		return nil, 0, fmt.Errorf("missing schema")
+	}
+	return schema, columns, nil
+}
+
+// seekColumn advances dec, at the start of a columnar file, to the first
+// value of column c.
+func seekColumn(dec *json.Decoder, c int) error {
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if key != "columns" {
+			if err := skipValue(dec); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := expectDelim(dec, '['); err != nil {
+			return err
+		}
+		for i := 0; i < c; i++ {
+			if err := skipValue(dec); err != nil {
+				return err
+			}
+		}
+		return expectDelim(dec, '[')
+	}
+	return fmt.Errorf("missing columns")
+}
+
+func expectDelim(dec *json.Decoder, want json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok != want {
+		return fmt.Errorf("expected %v, got %v", want, tok)
+	}
+	return nil
+}
+
+// skipValue reads past the next value without keeping it.
+func skipValue(dec *json.Decoder) error {
+	depth := 0
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case json.Delim('['), json.Delim('{'):
+			depth++
+		case json.Delim(']'), json.Delim('}'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+// importProgress is saved next to a file being imported after every
+// committed batch, so an interrupted import picks up where it stopped. It
+// records where the rows went, so resuming against another server or
+// database is refused instead of skipping rows it never received.
+type importProgress struct {
+	Server   string    `json:"server"`
+	Database string    `json:"database"`
+	Reducer  string    `json:"reducer"`
+	Rows     int       `json:"rows"`
+	Updated  time.Time `json:"updated"`
+}
+
+func loadProgress(path string) (importProgress, error) {
+	var p importProgress
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return p, nil
+	}
+	if err != nil {
+		return p, err
+	}
+	if err := json.Unmarshal(data, &p); err != nil {
+		return p, fmt.Errorf("reading progress %s: %w", path, err)
+	}
+	return p, nil
+}
+
+func saveProgress(path string, p importProgress) error {
+	p.Updated = time.Now().UTC()
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// importer replays dumped rows through a reducer.
+type importer struct {
+	a       *app
+	db      string
+	dbID    string // identity of db, which progress is keyed by
+	schema  *spacetimedb.ModuleSchema
+	reducer string
+	batch   int
+	every   time.Duration // minimum time between calls, zero for no limit
+	restart bool
+}
+
+func runImport(ctx context.Context, a *app, args []string) error {
+	fs := a.flags("import")
+	reducer := fs.String("reducer", "", "reducer to insert rows with, insert_<table> by default")
+	batch := fs.Int("batch", 1, "rows per reducer call; above 1 the reducer takes a single list of rows")
+	rate := fs.Float64("rate", 0, "maximum reducer calls per second, 0 for no limit")
+	restart := fs.Bool("restart", false, "ignore saved progress and import from the first row")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("usage: spacetime %s", commands["import"].usage)
+	}
+	if *batch < 1 {
+		return fmt.Errorf("-batch must be at least 1")
+	}
+	if err := a.requireToken(); err != nil {
+		return err
+	}
+	db, err := a.databaseArg(nil, 0)
+	if err != nil {
+		return err
+	}
+
+	im := &importer{a: a, db: db, reducer: *reducer, batch: *batch, restart: *restart}
+	if *rate > 0 {
+		im.every = time.Duration(float64(time.Second) / *rate)
+	}
+	// A name may be moved to a new database; the identity may not.
+	if im.dbID, err = a.client.GetDatabaseIdentity(db); err != nil {
+		return err
+	}
+	if im.schema, err = a.client.GetDatabaseSchema(db); err != nil {
+		fmt.Fprintln(a.stderr, "warning: reducer arguments are not checked:", err)
+	}
+
+	for _, path := range fs.Args() {
+		if err := im.importFile(ctx, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (im *importer) importFile(ctx context.Context, path string) error {
+	d, err := openDump(path)
+	if err != nil {
+		return err
+	}
+	defer d.close()
+
+	reducer := im.reducer
+	if reducer == "" {
+		reducer = "insert_" + d.schema.Table
+	}
+	if err := im.check(reducer, d.schema); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	progressFile := path + ".progress"
+	progress := importProgress{Server: im.a.server, Database: im.dbID, Reducer: reducer}
+	if !im.restart {
+		saved, err := loadProgress(progressFile)
+		if err != nil {
+			return err
+		}
+		if saved.Rows > 0 {
+			if saved.Server != progress.Server || saved.Database != progress.Database {
+				return fmt.Errorf("%s was partly imported into %s on %s, pass -restart to start over in %s", path, saved.Database, saved.Server, im.db)
+			}
+			if saved.Reducer != reducer {
+				return fmt.Errorf("%s was partly imported with %s, pass -restart to start over with %s", path, saved.Reducer, reducer)
+			}
+			progress.Rows = saved.Rows
+			fmt.Fprintf(im.a.stderr, "%s: resuming after row %d\n", path, saved.Rows)
+		}
+	}
+
+	for i := 0; i < progress.Rows; i++ {
+		if _, err := d.next(); err != nil {
+			return fmt.Errorf("%s: skipping imported rows: %w", path, err)
+		}
+	}
+
+	start := time.Now()
+	next := start
+	imported := 0
+	for {
+		rows, err := readBatch(d, im.batch)
+		if err != nil {
+			return fmt.Errorf("%s: row %d: %w", path, progress.Rows+len(rows)+1, err)
+		}
+		if len(rows) == 0 {
+			break
+		}
+
+		if im.every > 0 {
+			if err := sleepUntil(ctx, next); err != nil {
+				return err
+			}
+			next = next.Add(im.every)
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var callArgs any = rows[0]
+		if im.batch > 1 {
+			callArgs = []any{rows}
+		}
+		if err := im.a.client.CallReducer(ctx, reducer, im.db, im.a.token, callArgs); err != nil {
+			span := fmt.Sprintf("row %d", progress.Rows+1)
+			if len(rows) > 1 {
+				span = fmt.Sprintf("rows %d-%d", progress.Rows+1, progress.Rows+len(rows))
+			}
+			return fmt.Errorf("%s: %s: %w (progress saved, run again to resume)", path, span, err)
+		}
+		progress.Rows += len(rows)
+		imported += len(rows)
+		if err := saveProgress(progressFile, progress); err != nil {
+			return fmt.Errorf("saving progress: %w", err)
+		}
+	}
+
+	if err := os.Remove(progressFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	elapsed := time.Since(start).Round(time.Millisecond)
+	im.a.printf("%s: %d %s into %s with %s (%s)\n", path, imported, plural(imported, "row"), d.schema.Table, reducer, elapsed)
+	return nil
+}
+
+// check compares the reducer's parameters with the dumped columns when the
+// module schema is known. Types are compared with the columns of the
+// table of the same name, whose types are resolved like the reducer's;
+// the type names in the dump are only a summary and do not say enough.
+func (im *importer) check(reducer string, schema dumpSchema) error {
+	if im.schema == nil {
+		return nil
+	}
+	rd, ok := im.schema.Reducer(reducer)
+	if !ok {
+		return fmt.Errorf("no reducer named %s, choose one with -reducer", reducer)
+	}
+
+	if im.batch > 1 {
+		if len(rd.Params) != 1 || rd.Params[0].Type.Kind != spacetimedb.KindArray {
+			return fmt.Errorf("-batch needs %s to take a single list of rows", reducer)
+		}
+		return nil
+	}
+	if len(rd.Params) != len(schema.Columns) {
+		return fmt.Errorf("%s takes %d arguments, %s has %d columns", reducer, len(rd.Params), schema.Table, len(schema.Columns))
+	}
+
+	table, ok := im.schema.Table(schema.Table)
+	if !ok {
+		fmt.Fprintf(im.a.stderr, "warning: %s has no table %s, argument types are not checked\n", im.db, schema.Table)
+		return nil
+	}
+	if len(table.Columns) != len(schema.Columns) {
+		return fmt.Errorf("table %s has %d columns, the dump has %d", table.Name, len(table.Columns), len(schema.Columns))
+	}
+	for i, p := range rd.Params {
+		dumped, col := schema.Columns[i], table.Columns[i]
+		if col.Name != dumped.Name {
+			return fmt.Errorf("column %d of %s is %s, the dump has %s", i+1, table.Name, col.Name, dumped.Name)
+		}
+		if !p.Type.Equal(col.Type) {
+			return fmt.Errorf("%s argument %s is %s, column %s is %s", reducer, p.Name, p.Type, col.Name, col.Type)
+		}
+	}
+	return nil
+}
+
+// readBatch reads up to n rows, returning fewer at the end of the file.
+func readBatch(d *dump, n int) ([]any, error) {
+	rows := make([]any, 0, n)
+	for len(rows) < n {
+		row, err := d.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return rows, err
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func sleepUntil(ctx context.Context, t time.Time) error {
+	d := time.Until(t)
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
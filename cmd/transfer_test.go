@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/briheet/spacetime-goclient/spacetimedb/spacetimedbtest"
+)
+
+var personColumns = []spacetimedbtest.Column{
+	{Name: "name", Type: "String"},
+	{Name: "age", Type: "U32"},
+	{Name: "nick", Type: "Option<String>"},
+	{Name: "tags", Type: "Vec<String>"},
+}
+
+// transferFixture is a fake server with a source database "src" holding
+// people and an empty target "dst" with an insert_person reducer.
+type transferFixture struct {
+	srv   *spacetimedbtest.Server
+	token string
+}
+
+func newTransferFixture(t *testing.T, params ...spacetimedbtest.Column) *transferFixture {
+	t.Helper()
+	isolate(t)
+
+	srv := spacetimedbtest.NewServer()
+	t.Cleanup(srv.Close)
+	owner, token := srv.NewIdentity()
+
+	src := srv.AddDatabaseOwnedBy("src", owner).AddTable("person", personColumns...)
+	src.Insert("alice", 30, map[string]any{"some": "al"}, []any{"admin"})
+	src.Insert("bob, jr.", 4, map[string]any{"none": []any{}}, []any{})
+	src.Insert(`carol "c"`, 4294967295, map[string]any{"some": "line\nbreak"}, []any{"a", "b"})
+
+	dst := srv.AddDatabaseOwnedBy("dst", owner)
+	dst.AddTable("person", personColumns...)
+	if params == nil {
+		params = personColumns
+	}
+	dst.AddReducer("insert_person", func(ctx *spacetimedbtest.ReducerContext, args json.RawMessage) error {
+		var row []any
+		if err := json.Unmarshal(args, &row); err != nil {
+			return err
+		}
+		return ctx.Insert("person", row...)
+	}, params...)
+	return &transferFixture{srv: srv, token: token}
+}
+
+func (f *transferFixture) run(t *testing.T, db string, args ...string) (string, error) {
+	t.Helper()
+	return runCLI(append([]string{"-server", f.srv.URL, "-database", db, "-token", f.token}, args...)...)
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	for _, kind := range []string{dumpNDJSON, dumpCSV, dumpColumnar} {
+		t.Run(kind, func(t *testing.T) {
+			f := newTransferFixture(t)
+			dir := t.TempDir()
+
+			out, err := f.run(t, "src", "export", "-format", kind, "-dir", dir, "person")
+			if err != nil {
+				t.Fatalf("export: %v\n%s", err, out)
+			}
+			path := filepath.Join(dir, "person"+dumpExtensions[kind])
+			if out, err := f.run(t, "dst", "import", path); err != nil {
+				t.Fatalf("import: %v\n%s", err, out)
+			}
+
+			// Compare through JSON, since numbers come back as float64.
+			want, _ := json.Marshal(f.srv.Database("src").Table("person").Rows())
+			got, _ := json.Marshal(f.srv.Database("dst").Table("person").Rows())
+			if !reflect.DeepEqual(normalize(t, got), normalize(t, want)) {
+				t.Errorf("imported rows\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+func normalize(t *testing.T, data []byte) any {
+	t.Helper()
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestImportChecksTypes(t *testing.T) {
+	tests := []struct {
+		name   string
+		params []spacetimedbtest.Column
+		err    string
+	}{
+		{"wrong type", []spacetimedbtest.Column{
+			{Name: "name", Type: "String"}, {Name: "age", Type: "U64"}, {Name: "nick", Type: "Option<String>"}, {Name: "tags", Type: "Vec<String>"},
+		}, "insert_person argument age is U64, column age is U32"},
+		{"wrong element type", []spacetimedbtest.Column{
+			{Name: "name", Type: "String"}, {Name: "age", Type: "U32"}, {Name: "nick", Type: "Option<String>"}, {Name: "tags", Type: "Vec<U8>"},
+		}, "argument tags is Vec<U8>, column tags is Vec<String>"},
+		{"missing argument", personColumns[:3], "insert_person takes 3 arguments, person has 4 columns"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newTransferFixture(t, tt.params...)
+			dir := t.TempDir()
+			if out, err := f.run(t, "src", "export", "-dir", dir, "person"); err != nil {
+				t.Fatalf("export: %v\n%s", err, out)
+			}
+			out, err := f.run(t, "dst", "import", filepath.Join(dir, "person.ndjson"))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("import err = %v, want %q\n%s", err, tt.err, out)
+			}
+			if rows := f.srv.Database("dst").Table("person").Rows(); len(rows) != 0 {
+				t.Errorf("%d rows imported, want none", len(rows))
+			}
+		})
+	}
+}
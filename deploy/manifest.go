@@ -0,0 +1,175 @@
+// Package deploy publishes databases and registers their names from a
+// declarative manifest. NewPlan compares the manifest with what the server
+// reports: databases and names that already exist are not created again,
+// while every existing database is republished, since the server does not
+// report a hash of its current module to compare with the file.
+//
+//	m, err := deploy.Load("spacetime.yaml")
+//	connect, closeAll := deploy.Connector(ctx, spacetimedb.WithToken(token))
+//	defer closeAll()
+//	plan, err := deploy.NewPlan(m, connect, false)
+//	fmt.Print(plan)
+//	err = plan.Apply(nil)
+package deploy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/briheet/spacetime-goclient/internal/parse"
+)
+
+// ClearPolicy says whether publishing a changed module clears the
+// database's data.
+type ClearPolicy string
+
+const (
+	// ClearNever keeps the data; the server refuses incompatible schema
+	// changes. It is the default.
+	ClearNever ClearPolicy = "never"
+	// ClearAlways clears the data on every publish, which suits staging
+	// databases that are seeded afresh. Databases that already exist are
+	// only cleared when NewPlan is told it may.
+	ClearAlways ClearPolicy = "always"
+)
+
+// Manifest describes the databases to deploy.
+type Manifest struct {
+	// Server is the default server URL for every database.
+	Server    string     `json:"server"`
+	Databases []Database `json:"databases"`
+
+	// Dir is the directory module paths are relative to, the manifest's
+	// own directory when loaded from a file.
+	Dir string `json:"-"`
+}
+
+// Database is one database in a manifest.
+type Database struct {
+	// Name is the name the database is published under.
+	Name string `json:"name"`
+	// Wasm is the path of the compiled module.
+	Wasm string `json:"wasm"`
+	// Aliases are further names registered for the database.
+	Aliases []string    `json:"aliases"`
+	Clear   ClearPolicy `json:"clear"`
+	// Server overrides the manifest's server for this database.
+	Server string `json:"server"`
+}
+
+// Load reads a manifest, telling YAML, TOML and JSON apart by extension.
+func Load(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading manifest: %w", err)
+	}
+
+	var syntax string
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		syntax = "yaml"
+	case ".toml":
+		syntax = "toml"
+	case ".json":
+		syntax = "json"
+	default:
+		return nil, fmt.Errorf("manifest %s: unknown extension, want .yaml, .toml or .json", path)
+	}
+
+	m, err := Parse(data, syntax)
+	if err != nil {
+		return nil, fmt.Errorf("manifest %s: %w", path, err)
+	}
+	m.Dir = filepath.Dir(path)
+	return m, nil
+}
+
+// Parse decodes a manifest written in syntax, "yaml", "toml" or "json",
+// and validates it.
+func Parse(data []byte, syntax string) (*Manifest, error) {
+	var doc any
+	var err error
+	switch syntax {
+	case "yaml":
+		doc, err = parse.YAML(data)
+	case "toml":
+		doc, err = parse.TOML(data)
+	case "json":
+		err = json.Unmarshal(data, &doc)
+	default:
+		return nil, fmt.Errorf("unknown manifest syntax %q", syntax)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The parsers produce plain maps and slices; going through JSON maps
+	// them onto the structs and catches misspelt keys.
+	encoded, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(encoded))
+	dec.DisallowUnknownFields()
+	var m Manifest
+	if err := dec.Decode(&m); err != nil {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+	if err := m.validate(); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (m *Manifest) validate() error {
+	if len(m.Databases) == 0 {
+		return fmt.Errorf("manifest lists no databases")
+	}
+
+	// Names are per server, so the same name may be deployed to staging
+	// and production from one manifest.
+	taken := map[string]string{}
+	for i, db := range m.Databases {
+		if db.Name == "" {
+			return fmt.Errorf("database %d has no name", i+1)
+		}
+		if db.Wasm == "" {
+			return fmt.Errorf("database %s has no wasm path", db.Name)
+		}
+		switch db.Clear {
+		case "", ClearNever, ClearAlways:
+		default:
+			return fmt.Errorf("database %s: clear must be %q or %q, not %q", db.Name, ClearNever, ClearAlways, db.Clear)
+		}
+
+		server := m.server(db)
+		for _, name := range append([]string{db.Name}, db.Aliases...) {
+			key := server + " " + name
+			if other, dup := taken[key]; dup {
+				return fmt.Errorf("name %s is used by both %s and %s", name, other, db.Name)
+			}
+			taken[key] = db.Name
+		}
+	}
+	return nil
+}
+
+// server returns the server db deploys to, empty for the caller's default.
+func (m *Manifest) server(db Database) string {
+	if db.Server != "" {
+		return db.Server
+	}
+	return m.Server
+}
+
+// wasmPath resolves a module path against the manifest's directory.
+func (m *Manifest) wasmPath(db Database) string {
+	if filepath.IsAbs(db.Wasm) || m.Dir == "" {
+		return db.Wasm
+	}
+	return filepath.Join(m.Dir, db.Wasm)
+}
@@ -0,0 +1,217 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/briheet/spacetime-goclient/spacetimedb"
+)
+
+// ErrClearsData is returned by NewPlan when a database that already exists
+// has the ClearAlways policy, and clearing existing databases was not
+// allowed.
+var ErrClearsData = errors.New("clear: always would delete the data of an existing database")
+
+// Op is the kind of change a step makes.
+type Op string
+
+const (
+	OpCreate  Op = "create"
+	OpUpdate  Op = "update"
+	OpAddName Op = "name"
+)
+
+// Step is one change a plan makes.
+type Step struct {
+	Op Op
+	// Server is where the database lives, empty for the default server.
+	Server string
+	// Database is the name the database is published under.
+	Database string
+	// Wasm is the module published by create and update steps.
+	Wasm  string
+	Clear bool
+	// Name is the alias registered by a name step.
+	Name string
+
+	client spacetimedb.DBClient
+}
+
+func (s Step) String() string {
+	var b strings.Builder
+	switch s.Op {
+	case OpCreate, OpUpdate:
+		fmt.Fprintf(&b, "%-7s %s from %s", s.Op, s.Database, s.Wasm)
+		if s.Clear {
+			b.WriteString(", clearing data")
+		}
+	case OpAddName:
+		fmt.Fprintf(&b, "%-7s %s -> %s", s.Op, s.Name, s.Database)
+	}
+	if s.Server != "" {
+		fmt.Fprintf(&b, " on %s", s.Server)
+	}
+	return b.String()
+}
+
+// Plan lists the steps that bring the servers in line with a manifest.
+type Plan struct {
+	Steps []Step
+}
+
+// ConnectFunc returns the client for a server URL, where an empty URL
+// stands for the default server. Steps on a server are applied with the
+// token its client was connected with.
+type ConnectFunc func(server string) (spacetimedb.DBClient, error)
+
+// NewPlan compares m with the servers it names. A database that does not
+// exist is created, one that does is updated, and missing aliases are
+// registered. Names the manifest does not mention are left alone. An
+// existing database with the ClearAlways policy fails with ErrClearsData
+// unless clearExisting is set.
+//
+// Updates are not skipped for unchanged modules: the program hash that
+// GetDatabaseInfo reports is the one the database was created with, and
+// the host's hash function is not SHA-256, so it says nothing about
+// whether the file differs from what is running.
+func NewPlan(m *Manifest, connect ConnectFunc, clearExisting bool) (*Plan, error) {
+	p := &Plan{}
+	for _, db := range m.Databases {
+		server := m.server(db)
+		client, err := connect(server)
+		if err != nil {
+			return nil, fmt.Errorf("connecting to %s: %w", server, err)
+		}
+		steps, err := planDatabase(client, m, db, clearExisting)
+		if err != nil {
+			return nil, fmt.Errorf("planning %s: %w", db.Name, err)
+		}
+		for _, s := range steps {
+			s.Server, s.client = server, client
+			p.Steps = append(p.Steps, s)
+		}
+	}
+	return p, nil
+}
+
+func planDatabase(client spacetimedb.DBClient, m *Manifest, db Database, clearExisting bool) ([]Step, error) {
+	wasm := m.wasmPath(db)
+	if _, err := os.Stat(wasm); err != nil {
+		return nil, err
+	}
+	publish := Step{Op: OpUpdate, Database: db.Name, Wasm: wasm, Clear: db.Clear == ClearAlways}
+
+	identity, _, _, _, err := client.GetDatabaseInfo(db.Name)
+	var names []string
+	switch {
+	case errors.Is(err, spacetimedb.ErrDatabaseNotFound):
+		publish.Op = OpCreate
+	case err != nil:
+		return nil, err
+	case publish.Clear && !clearExisting:
+		return nil, ErrClearsData
+	default:
+		if names, err = client.GetDatabaseNames(db.Name); err != nil {
+			return nil, err
+		}
+	}
+	steps := []Step{publish}
+
+	for _, alias := range db.Aliases {
+		if slices.Contains(names, alias) {
+			continue
+		}
+		named, err := client.GetDatabaseIdentity(alias)
+		switch {
+		case errors.Is(err, spacetimedb.ErrDatabaseNotFound):
+		case err != nil:
+			return nil, err
+		case !strings.EqualFold(named, identity):
+			return nil, fmt.Errorf("alias %s already names database %s", alias, named)
+		default:
+			continue
+		}
+		steps = append(steps, Step{Op: OpAddName, Database: db.Name, Name: alias})
+	}
+	return steps, nil
+}
+
+func (p *Plan) String() string {
+	var b strings.Builder
+	for _, s := range p.Steps {
+		fmt.Fprintf(&b, "%s\n", s)
+	}
+	return b.String()
+}
+
+// Apply runs the steps in order, calling done, if set, after each one. It
+// stops at the first failure; planning again afterwards picks up the steps
+// still outstanding.
+func (p *Plan) Apply(done func(Step)) error {
+	for _, s := range p.Steps {
+		var err error
+		switch s.Op {
+		case OpCreate, OpUpdate:
+			_, _, _, err = s.client.PublishNamedDatabase(s.Database, s.Wasm, "", s.Clear)
+		case OpAddName:
+			err = s.client.AddDatabaseName(s.Database, s.Name, "")
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", s, err)
+		}
+		if done != nil {
+			done(s)
+		}
+	}
+	return nil
+}
+
+// Connector returns a ConnectFunc that connects once per server with opts,
+// followed by WithURL for any server other than the default. Close
+// disconnects every client it opened.
+func Connector(ctx context.Context, opts ...spacetimedb.Option) (connect ConnectFunc, close func()) {
+	return ServerConnector(ctx, func(string) ([]spacetimedb.Option, error) { return opts, nil })
+}
+
+// ServerConnector is Connector with the options chosen per server, such as
+// the token and TLS settings that server needs. An error from opts fails
+// the connection.
+func ServerConnector(ctx context.Context, opts func(server string) ([]spacetimedb.Option, error)) (connect ConnectFunc, close func()) {
+	var mu sync.Mutex
+	clients := map[string]spacetimedb.DBClient{}
+
+	connect = func(server string) (spacetimedb.DBClient, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if c, ok := clients[server]; ok {
+			return c, nil
+		}
+		serverOpts, err := opts(server)
+		if err != nil {
+			return nil, err
+		}
+		serverOpts = slices.Clone(serverOpts)
+		if server != "" {
+			serverOpts = append(serverOpts, spacetimedb.WithURL(server))
+		}
+		c, err := spacetimedb.Connect(ctx, serverOpts...)
+		if err != nil {
+			return nil, err
+		}
+		clients[server] = c
+		return c, nil
+	}
+	close = func() {
+		mu.Lock()
+		defer mu.Unlock()
+		for _, c := range clients {
+			c.Disconnect()
+		}
+	}
+	return connect, close
+}
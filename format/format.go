@@ -0,0 +1,181 @@
+// Package format renders query results and database metadata as aligned
+// tables, JSON, NDJSON, CSV or YAML. It is what the command-line client
+// prints with, and works the same from library code:
+//
+//	results, _ := client.RunSQLQuery("SELECT * FROM person", token, db)
+//	res, _ := format.FromSQL(results[0])
+//	format.Write(os.Stdout, format.JSON, res)
+package format
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+)
+
+// Format is an output format.
+type Format string
+
+const (
+	Table  Format = "table"
+	JSON   Format = "json"
+	NDJSON Format = "ndjson"
+	CSV    Format = "csv"
+	YAML   Format = "yaml"
+)
+
+// Formats lists every supported format.
+var Formats = []Format{Table, JSON, NDJSON, CSV, YAML}
+
+// Parse returns the format called name, case-insensitively.
+func Parse(name string) (Format, error) {
+	for _, f := range Formats {
+		if strings.EqualFold(name, string(f)) {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("unknown format %q, want one of %s", name, joinFormats())
+}
+
+func joinFormats() string {
+	names := make([]string, len(Formats))
+	for i, f := range Formats {
+		names[i] = string(f)
+	}
+	return strings.Join(names, ", ")
+}
+
+// Result is tabular data with named columns. Rows hold plain values,
+// strings, numbers, bools, nil, []any, map[string]any and Object, as
+// produced by the From functions.
+type Result struct {
+	Columns []string
+	Rows    [][]any
+}
+
+// Write renders r in format f.
+func Write(w io.Writer, f Format, r Result) error {
+	switch f {
+	case Table, "":
+		return writeTable(w, r)
+	case JSON:
+		return writeJSON(w, r)
+	case NDJSON:
+		return writeNDJSON(w, r)
+	case CSV:
+		return writeCSV(w, r)
+	case YAML:
+		return writeYAML(w, r)
+	default:
+		return fmt.Errorf("unknown format %q", f)
+	}
+}
+
+func writeTable(w io.Writer, r Result) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(r.Columns, "\t"))
+	for _, row := range r.Rows {
+		cells := make([]string, len(row))
+		for i, v := range row {
+			cells[i] = Cell(v)
+		}
+		fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	return tw.Flush()
+}
+
+func writeCSV(w io.Writer, r Result) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(r.Columns); err != nil {
+		return err
+	}
+	for _, row := range r.Rows {
+		cells := make([]string, len(row))
+		for i, v := range row {
+			cells[i] = Cell(v)
+		}
+		if err := cw.Write(cells); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeJSON(w io.Writer, r Result) error {
+	var buf bytes.Buffer
+	buf.WriteString("[")
+	for i, row := range r.Rows {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		buf.WriteString("\n  ")
+		if err := writeObject(&buf, r.Columns, row); err != nil {
+			return err
+		}
+	}
+	if len(r.Rows) > 0 {
+		buf.WriteString("\n")
+	}
+	buf.WriteString("]\n")
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func writeNDJSON(w io.Writer, r Result) error {
+	var buf bytes.Buffer
+	for _, row := range r.Rows {
+		if err := writeObject(&buf, r.Columns, row); err != nil {
+			return err
+		}
+		buf.WriteString("\n")
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// writeObject encodes a row as a JSON object with keys in column order,
+// which encoding/json would sort.
+func writeObject(buf *bytes.Buffer, columns []string, row []any) error {
+	buf.WriteString("{")
+	for i, col := range columns {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		key, _ := json.Marshal(col)
+		buf.Write(key)
+		buf.WriteString(":")
+
+		var v any
+		if i < len(row) {
+			v = row[i]
+		}
+		val, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("encoding column %s: %w", col, err)
+		}
+		buf.Write(val)
+	}
+	buf.WriteString("}")
+	return nil
+}
+
+// Cell renders a value for a table or CSV cell: strings as they are, nil
+// as an empty cell and anything else as compact JSON.
+func Cell(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
@@ -0,0 +1,310 @@
+package format
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/briheet/spacetime-goclient/spacetimedb"
+)
+
+// FromSQL converts a SQL result, keeping the column order of its schema and
+// rendering each value readably for its type, see Value.
+func FromSQL(res spacetimedb.SQLResult) (Result, error) {
+	fields, err := res.Columns()
+	if err != nil {
+		return Result{}, err
+	}
+	rows, err := SQLRows(res)
+	if err != nil {
+		return Result{}, err
+	}
+	return FromRows(fields, rows), nil
+}
+
+// SQLRows returns the rows of a SQL result as lists of values. Rows the
+// result holds as received are decoded again with numbers as json.Number,
+// so 64- and 128-bit integers keep their precision.
+func SQLRows(res spacetimedb.SQLResult) ([][]any, error) {
+	decoded := res.Rows
+	if len(res.RawRows) > 0 {
+		dec := json.NewDecoder(bytes.NewReader(res.RawRows))
+		dec.UseNumber()
+		decoded = nil
+		if err := dec.Decode(&decoded); err != nil {
+			return nil, fmt.Errorf("decoding rows: %w", err)
+		}
+	}
+
+	rows := make([][]any, len(decoded))
+	for i, row := range decoded {
+		values, ok := row.([]any)
+		if !ok {
+			return nil, fmt.Errorf("unexpected row %v", row)
+		}
+		rows[i] = values
+	}
+	return rows, nil
+}
+
+// FromRows converts rows of the given columns, such as a table's rows held
+// in a spacetimedb.Cache, the same way as FromSQL.
+func FromRows(fields []spacetimedb.Field, rows [][]any) Result {
+	out := Result{Columns: make([]string, len(fields)), Rows: make([][]any, 0, len(rows))}
+	for i, f := range fields {
+		out.Columns[i] = f.Name
+		if f.Name == "" {
+			out.Columns[i] = fmt.Sprintf("column%d", i)
+		}
+	}
+
+	for _, values := range rows {
+		out.Rows = append(out.Rows, Row(fields, values))
+	}
+	return out
+}
+
+// Row renders each value of a row for its column's type, see Value.
+func Row(fields []spacetimedb.Field, values []any) []any {
+	converted := make([]any, len(values))
+	for i, v := range values {
+		var t *spacetimedb.AlgebraicType
+		if i < len(fields) {
+			t = fields[i].Type
+		}
+		converted[i] = Value(t, v)
+	}
+	return converted
+}
+
+// FromDatabaseInfo converts the values returned by GetDatabaseInfo.
+func FromDatabaseInfo(identity, owner, hostType, program string) Result {
+	return Result{
+		Columns: []string{"database_identity", "owner_identity", "host_type", "program_hash"},
+		Rows:    [][]any{{identity, owner, hostType, program}},
+	}
+}
+
+// FromNames converts the names returned by GetDatabaseNames.
+func FromNames(names []string) Result {
+	return column("name", names)
+}
+
+// FromDatabases converts the identities returned by GetDatabasesByIdentity.
+func FromDatabases(identities []string) Result {
+	return column("database_identity", identities)
+}
+
+// FromNameInfo converts the result of LookupDatabaseName.
+func FromNameInfo(info *spacetimedb.NameInfo) Result {
+	return Result{
+		Columns: []string{"name", "database_identity", "owner_identity", "names"},
+		Rows:    [][]any{{info.Name, info.Database, info.Owner, list(info.Names)}},
+	}
+}
+
+// FromInventory converts the result of GetDatabaseInventory.
+func FromInventory(inventory []spacetimedb.DatabaseNames) Result {
+	r := Result{Columns: []string{"database_identity", "names"}, Rows: make([][]any, 0, len(inventory))}
+	for _, db := range inventory {
+		r.Rows = append(r.Rows, []any{db.Database, list(db.Names)})
+	}
+	return r
+}
+
+// list turns names into a list value, so every format renders it as one.
+func list(values []string) []any {
+	out := make([]any, len(values))
+	for i, v := range values {
+		out[i] = v
+	}
+	return out
+}
+
+func column(name string, values []string) Result {
+	r := Result{Columns: []string{name}, Rows: make([][]any, 0, len(values))}
+	for _, v := range values {
+		r.Rows = append(r.Rows, []any{v})
+	}
+	return r
+}
+
+// Value turns a SATS JSON value of type t into a plain, readable one:
+// identities and connection ids become hex strings, timestamps RFC 3339
+// strings, durations Go duration strings, options their value or nil,
+// products Objects in field order and unit enum variants their name.
+// With a nil t, v is returned unchanged.
+func Value(t *spacetimedb.AlgebraicType, v any) any {
+	if t == nil || v == nil {
+		return v
+	}
+
+	switch t.Special() {
+	case "Identity":
+		return hexID(unwrap(v), 64)
+	case "ConnectionId":
+		return hexID(unwrap(v), 32)
+	case "Timestamp":
+		if micros, ok := integer(unwrap(v)); ok {
+			return time.UnixMicro(micros).UTC().Format(time.RFC3339Nano)
+		}
+		return unwrap(v)
+	case "TimeDuration":
+		if micros, ok := integer(unwrap(v)); ok {
+			return (time.Duration(micros) * time.Microsecond).String()
+		}
+		return unwrap(v)
+	}
+
+	if inner, ok := t.Option(); ok {
+		m, isMap := v.(map[string]any)
+		if !isMap {
+			return v
+		}
+		if some, ok := m["some"]; ok {
+			return Value(inner, some)
+		}
+		return nil
+	}
+
+	switch t.Kind {
+	case spacetimedb.KindArray:
+		items, ok := v.([]any)
+		if !ok {
+			return v
+		}
+		out := make([]any, len(items))
+		for i, item := range items {
+			out[i] = Value(t.Elem, item)
+		}
+		return out
+
+	case spacetimedb.KindProduct:
+		items, ok := v.([]any)
+		if !ok || len(items) != len(t.Elements) {
+			return v
+		}
+		out := make(Object, len(items))
+		for i, item := range items {
+			name := t.Elements[i].Name
+			if name == "" {
+				name = fmt.Sprint(i)
+			}
+			out[i] = Member{Name: name, Value: Value(t.Elements[i].Type, item)}
+		}
+		return out
+
+	case spacetimedb.KindSum:
+		m, ok := v.(map[string]any)
+		if !ok || len(m) != 1 {
+			return v
+		}
+		for name, payload := range m {
+			for _, variant := range t.Variants {
+				if variant.Name != name {
+					continue
+				}
+				if unit(variant.Type) {
+					return name
+				}
+				return map[string]any{name: Value(variant.Type, payload)}
+			}
+		}
+	}
+	return v
+}
+
+// Object is a product value with its fields in declaration order. It
+// encodes as a JSON object with the keys in that order, where a map's keys
+// would be sorted.
+type Object []Member
+
+// Member is one field of an Object.
+type Member struct {
+	Name  string
+	Value any
+}
+
+func (o Object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("{")
+	for i, m := range o {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		key, _ := json.Marshal(m.Name)
+		buf.Write(key)
+		buf.WriteString(":")
+		val, err := json.Marshal(m.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(val)
+	}
+	buf.WriteString("}")
+	return buf.Bytes(), nil
+}
+
+// unwrap takes the value out of a special product, which the host may send
+// as a one-element array or an object keyed by the special field name.
+func unwrap(v any) any {
+	switch v := v.(type) {
+	case []any:
+		if len(v) == 1 {
+			return v[0]
+		}
+	case map[string]any:
+		if len(v) == 1 {
+			for _, inner := range v {
+				return inner
+			}
+		}
+	}
+	return v
+}
+
+// hexID renders an identity or connection id as a zero-padded hex string of
+// the given width.
+func hexID(v any, width int) any {
+	var n *big.Int
+	switch v := v.(type) {
+	case string:
+		s := strings.TrimPrefix(strings.ToLower(v), "0x")
+		if _, ok := new(big.Int).SetString(s, 16); !ok {
+			return v
+		}
+		return "0x" + strings.Repeat("0", max(0, width-len(s))) + s
+	case json.Number:
+		n, _ = new(big.Int).SetString(v.String(), 10)
+	case float64:
+		n, _ = big.NewFloat(v).Int(nil)
+	}
+	if n == nil {
+		return v
+	}
+	return fmt.Sprintf("0x%0*x", width, n)
+}
+
+func integer(v any) (int64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return int64(v), true
+	case json.Number:
+		n, err := v.Int64()
+		return n, err == nil
+	case int64:
+		return v, true
+	case int:
+		return int64(v), true
+	}
+	return 0, false
+}
+
+// unit reports whether t is the empty product, the payload of plain enum
+// variants.
+func unit(t *spacetimedb.AlgebraicType) bool {
+	return t == nil || (t.Kind == spacetimedb.KindProduct && len(t.Elements) == 0)
+}
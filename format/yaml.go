@@ -0,0 +1,154 @@
+package format
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// writeYAML renders r as a YAML sequence of mappings, keys in column order.
+// It covers the values a Result holds, so no YAML library is needed.
+func writeYAML(w io.Writer, r Result) error {
+	bw := bufio.NewWriter(w)
+	if len(r.Rows) == 0 {
+		bw.WriteString("[]\n")
+		return bw.Flush()
+	}
+
+	for _, row := range r.Rows {
+		for i, col := range r.Columns {
+			prefix := "  "
+			if i == 0 {
+				prefix = "- "
+			}
+			var v any
+			if i < len(row) {
+				v = row[i]
+			}
+			bw.WriteString(prefix + yamlKey(col) + ":")
+			writeYAMLValue(bw, v, 2)
+		}
+	}
+	return bw.Flush()
+}
+
+// writeYAMLValue writes v after a "key:" or "-" at column indent, either
+// inline or as a block indented below it on the following lines.
+func writeYAMLValue(w *bufio.Writer, v any, indent int) {
+	pad := strings.Repeat(" ", indent+2)
+
+	switch v := v.(type) {
+	case []any:
+		if len(v) == 0 {
+			w.WriteString(" []\n")
+			return
+		}
+		w.WriteString("\n")
+		for _, item := range v {
+			w.WriteString(pad + "-")
+			writeYAMLValue(w, item, indent+2)
+		}
+	case Object:
+		if len(v) == 0 {
+			w.WriteString(" {}\n")
+			return
+		}
+		w.WriteString("\n")
+		for _, m := range v {
+			w.WriteString(pad + yamlKey(m.Name) + ":")
+			writeYAMLValue(w, m.Value, indent+2)
+		}
+	case map[string]any:
+		// Plain maps have no field order; sort them to be stable.
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		obj := make(Object, len(keys))
+		for i, k := range keys {
+			obj[i] = Member{Name: k, Value: v[k]}
+		}
+		writeYAMLValue(w, obj, indent)
+	default:
+		w.WriteString(" " + yamlScalar(v) + "\n")
+	}
+}
+
+func yamlKey(k string) string {
+	if k != "" && !needsQuotes(k) {
+		return k
+	}
+	return strconv.Quote(k)
+}
+
+func yamlScalar(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(v)
+	case string:
+		if needsQuotes(v) {
+			return strconv.Quote(v)
+		}
+		return v
+	case float64:
+		if math.IsInf(v, 0) || math.IsNaN(v) {
+			return strconv.Quote(fmt.Sprint(v))
+		}
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case json.Number:
+		return v.String()
+	case int, int64, uint64, uint32, int32:
+		return fmt.Sprint(v)
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return strconv.Quote(fmt.Sprint(v))
+		}
+		return string(b)
+	}
+}
+
+// needsQuotes reports whether a plain string would be read back as
+// something else, or is not valid as a plain scalar.
+func needsQuotes(s string) bool {
+	if s == "" || strings.TrimSpace(s) != s {
+		return true
+	}
+	switch strings.ToLower(s) {
+	case "null", "~", "true", "false", "yes", "no", "on", "off":
+		return true
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return true
+	}
+	if looksNumeric(s) {
+		return true
+	}
+	if strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@`") {
+		return true
+	}
+	return strings.Contains(s, ": ") || strings.Contains(s, " #") || strings.ContainsAny(s, "\n\r\t")
+}
+
+// looksNumeric reports whether s would resolve to an integer or timestamp
+// under YAML rules Go's number parsing does not cover: hex and octal
+// literals such as identities, and dates.
+func looksNumeric(s string) bool {
+	lower := strings.ToLower(s)
+	for _, prefix := range []string{"0x", "0o"} {
+		if rest, ok := strings.CutPrefix(lower, prefix); ok && rest != "" &&
+			strings.Trim(rest, "0123456789abcdef_") == "" {
+			return true
+		}
+	}
+	return len(s) >= 10 && s[4] == '-' && s[7] == '-' &&
+		strings.Trim(s[:4]+s[5:7]+s[8:10], "0123456789") == ""
+}
@@ -0,0 +1,456 @@
+package parse
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// TOML reads a subset of TOML: key/value pairs with
+// bare, quoted or dotted keys, [tables], [[arrays of tables]], strings,
+// including multi-line ones, integers, floats, booleans, arrays, which may
+// span lines, and inline tables. Dates and times are rejected with an error
+// saying so; quoted, they are read as strings.
+func TOML(data []byte) (map[string]any, error) {
+	root := map[string]any{}
+	current := root
+
+	lines := strings.Split(string(data), "\n")
+	for i := 0; i < len(lines); i++ {
+		num := i + 1
+		raw := strings.TrimRight(lines[i], "\r")
+		line := strings.TrimSpace(stripTOMLComment(raw))
+		if line == "" {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "[["):
+			if !strings.HasSuffix(line, "]]") {
+				return nil, fmt.Errorf("toml line %d: invalid table header", num)
+			}
+			path, err := tomlKey(line[2 : len(line)-2])
+			if err != nil {
+				return nil, fmt.Errorf("toml line %d: %w", num, err)
+			}
+			parent, err := tomlTable(root, path[:len(path)-1])
+			if err != nil {
+				return nil, fmt.Errorf("toml line %d: %w", num, err)
+			}
+			last := path[len(path)-1]
+			list, _ := parent[last].([]any)
+			if _, exists := parent[last]; exists && list == nil {
+				return nil, fmt.Errorf("toml line %d: %s is not an array of tables", num, strings.Join(path, "."))
+			}
+			current = map[string]any{}
+			parent[last] = append(list, current)
+
+		case strings.HasPrefix(line, "["):
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("toml line %d: invalid table header", num)
+			}
+			path, err := tomlKey(line[1 : len(line)-1])
+			if err != nil {
+				return nil, fmt.Errorf("toml line %d: %w", num, err)
+			}
+			if current, err = tomlTable(root, path); err != nil {
+				return nil, fmt.Errorf("toml line %d: %w", num, err)
+			}
+
+		default:
+			eq := tomlAssign(line)
+			if eq < 0 {
+				return nil, fmt.Errorf("toml line %d: expected key = value", num)
+			}
+			path, err := tomlKey(line[:eq])
+			if err != nil {
+				return nil, fmt.Errorf("toml line %d: %w", num, err)
+			}
+
+			// An array may continue over several lines until its brackets
+			// balance. A multi-line string runs to its closing delimiter and
+			// keeps the lines in between verbatim.
+			value := strings.TrimSpace(line[eq+1:])
+			for strings.HasPrefix(value, "[") && !tomlBalanced(value) && i+1 < len(lines) {
+				i++
+				value += " " + strings.TrimSpace(stripTOMLComment(strings.TrimRight(lines[i], "\r")))
+			}
+			if tomlMultiline(value) {
+				lead := len(raw) - len(strings.TrimLeft(raw, " \t"))
+				value = strings.TrimLeft(raw[lead+eq+1:], " \t")
+				for tomlMultilineEnd(value) < 0 && i+1 < len(lines) {
+					i++
+					value += "\n" + strings.TrimRight(lines[i], "\r")
+				}
+			}
+
+			v, rest, err := tomlValue(value)
+			rest = stripTOMLComment(rest)
+			if err == nil && strings.TrimSpace(rest) != "" {
+				err = fmt.Errorf("unexpected %q after value", rest)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("toml line %d: %w", num, err)
+			}
+			table, err := tomlTable(current, path[:len(path)-1])
+			if err != nil {
+				return nil, fmt.Errorf("toml line %d: %w", num, err)
+			}
+			key := path[len(path)-1]
+			if _, dup := table[key]; dup {
+				return nil, fmt.Errorf("toml line %d: duplicate key %q", num, key)
+			}
+			table[key] = v
+		}
+	}
+	return root, nil
+}
+
+// tomlTable walks path from t, creating tables as needed. A path ending
+// in an array of tables resolves to its last element.
+func tomlTable(t map[string]any, path []string) (map[string]any, error) {
+	for _, key := range path {
+		switch next := t[key].(type) {
+		case nil:
+			child := map[string]any{}
+			t[key] = child
+			t = child
+		case map[string]any:
+			t = next
+		case []any:
+			if len(next) == 0 {
+				return nil, fmt.Errorf("%s is not a table", key)
+			}
+			last, ok := next[len(next)-1].(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("%s is not a table", key)
+			}
+			t = last
+		default:
+			return nil, fmt.Errorf("%s is not a table", key)
+		}
+	}
+	return t, nil
+}
+
+// tomlKey splits a possibly dotted, possibly quoted key.
+func tomlKey(s string) ([]string, error) {
+	var path []string
+	for _, part := range splitOutsideQuotes(s, '.') {
+		part = strings.TrimSpace(part)
+		switch {
+		case part == "":
+			return nil, fmt.Errorf("empty key in %q", s)
+		case part[0] == '"' || part[0] == '\'':
+			v, rest, err := tomlString(part)
+			if err != nil || rest != "" {
+				return nil, fmt.Errorf("invalid key %s", part)
+			}
+			part = v
+		case strings.Trim(part, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_-") != "":
+			return nil, fmt.Errorf("invalid bare key %q", part)
+		}
+		path = append(path, part)
+	}
+	return path, nil
+}
+
+// tomlAssign returns the index of the = separating key and value, or -1.
+func tomlAssign(line string) int {
+	parts := splitOutsideQuotes(line, '=')
+	if len(parts) < 2 {
+		return -1
+	}
+	return len(parts[0])
+}
+
+func splitOutsideQuotes(s string, sep byte) []string {
+	var parts []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == sep:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func tomlBalanced(s string) bool {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		}
+	}
+	return depth <= 0
+}
+
+// tomlValue parses the value at the start of s and returns the rest.
+func tomlValue(s string) (any, string, error) {
+	s = strings.TrimLeft(s, " \t")
+	if s == "" {
+		return nil, "", fmt.Errorf("missing value")
+	}
+
+	switch s[0] {
+	case '"', '\'':
+		return tomlString(s)
+	case '[':
+		items := []any{}
+		s = strings.TrimLeft(s[1:], " \t")
+		for {
+			if strings.HasPrefix(s, "]") {
+				return items, s[1:], nil
+			}
+			v, rest, err := tomlValue(s)
+			if err != nil {
+				return nil, "", err
+			}
+			items = append(items, v)
+			rest = strings.TrimLeft(rest, " \t")
+			switch {
+			case strings.HasPrefix(rest, ","):
+				s = strings.TrimLeft(rest[1:], " \t")
+			case strings.HasPrefix(rest, "]"):
+				return items, rest[1:], nil
+			default:
+				return nil, "", fmt.Errorf("expected , or ] in array")
+			}
+		}
+	case '{':
+		table := map[string]any{}
+		s = strings.TrimLeft(s[1:], " \t")
+		if strings.HasPrefix(s, "}") {
+			return table, s[1:], nil
+		}
+		for {
+			eq := strings.IndexByte(s, '=')
+			if eq < 0 {
+				return nil, "", fmt.Errorf("expected key = value in inline table")
+			}
+			path, err := tomlKey(s[:eq])
+			if err != nil {
+				return nil, "", err
+			}
+			v, rest, err := tomlValue(s[eq+1:])
+			if err != nil {
+				return nil, "", err
+			}
+			t, err := tomlTable(table, path[:len(path)-1])
+			if err != nil {
+				return nil, "", err
+			}
+			t[path[len(path)-1]] = v
+			rest = strings.TrimLeft(rest, " \t")
+			switch {
+			case strings.HasPrefix(rest, ","):
+				s = strings.TrimLeft(rest[1:], " \t")
+			case strings.HasPrefix(rest, "}"):
+				return table, rest[1:], nil
+			default:
+				return nil, "", fmt.Errorf("expected , or } in inline table")
+			}
+		}
+	}
+
+	end := strings.IndexAny(s, ",]} \t")
+	if end < 0 {
+		end = len(s)
+	}
+	word, rest := s[:end], s[end:]
+	switch word {
+	case "true":
+		return true, rest, nil
+	case "false":
+		return false, rest, nil
+	}
+	plain := strings.ReplaceAll(word, "_", "")
+	if n, ok, err := tomlInteger(plain); ok {
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid integer %q: %w", word, err)
+		}
+		return n, rest, nil
+	}
+	if f, err := strconv.ParseFloat(plain, 64); err == nil {
+		return f, rest, nil
+	}
+	if isDateTime(word) {
+		return nil, "", fmt.Errorf("dates and times are not supported, quote %s to keep it as a string", word)
+	}
+	return nil, "", fmt.Errorf("invalid value %q", word)
+}
+
+// isDateTime reports whether s starts like a date, 1979-05-27, or a time
+// of day, 07:32:00.
+func isDateTime(s string) bool {
+	digits := func(s string) bool { return s != "" && strings.Trim(s, "0123456789") == "" }
+	switch {
+	case len(s) >= 10 && s[4] == '-' && s[7] == '-':
+		return digits(s[:4]) && digits(s[5:7]) && digits(s[8:10])
+	case len(s) >= 8 && s[2] == ':' && s[5] == ':':
+		return digits(s[:2]) && digits(s[3:5]) && digits(s[6:8])
+	}
+	return false
+}
+
+// stripTOMLComment removes a trailing # comment outside quotes.
+func stripTOMLComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// tomlInteger parses a decimal integer, or a hexadecimal, octal or binary
+// one with its 0x, 0o or 0b prefix. ok reports whether s is an integer at
+// all, so that floats fall through.
+func tomlInteger(s string) (n int64, ok bool, err error) {
+	if len(s) > 2 && s[0] == '0' {
+		if base := map[byte]int{'x': 16, 'o': 8, 'b': 2}[s[1]]; base != 0 {
+			n, err := strconv.ParseInt(s[2:], base, 64)
+			return n, true, err
+		}
+	}
+	digits := strings.TrimLeft(s, "+-")
+	if len(s)-len(digits) > 1 || digits == "" || strings.Trim(digits, "0123456789") != "" {
+		return 0, false, nil
+	}
+	if len(digits) > 1 && digits[0] == '0' {
+		return 0, true, fmt.Errorf("leading zeros are not allowed")
+	}
+	n, err = strconv.ParseInt(s, 10, 64)
+	return n, true, err
+}
+
+// tomlMultiline reports whether s opens a multi-line string that does not
+// close on the same line.
+func tomlMultiline(s string) bool {
+	return (strings.HasPrefix(s, `"""`) || strings.HasPrefix(s, "'''")) && tomlMultilineEnd(s) < 0
+}
+
+// tomlMultilineEnd returns the index of the delimiter closing the
+// multi-line string at the start of s, or -1. Up to two quotes right
+// before the delimiter belong to the string.
+func tomlMultilineEnd(s string) int {
+	delim := s[:3]
+	for i := 3; i+3 <= len(s); i++ {
+		switch {
+		case s[i] == '\\' && delim == `"""`:
+			i++
+		case s[i:i+3] == delim:
+			for extra := 0; extra < 2 && i+3 < len(s) && s[i+3] == delim[0]; extra++ {
+				i++
+			}
+			return i
+		}
+	}
+	return -1
+}
+
+// tomlString parses a basic "..." or literal '...' string, or their
+// multi-line forms, at the start of s.
+func tomlString(s string) (string, string, error) {
+	if strings.HasPrefix(s, `"""`) || strings.HasPrefix(s, "'''") {
+		end := tomlMultilineEnd(s)
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated multi-line string")
+		}
+		// A newline right after the opening delimiter is not part of the
+		// string.
+		body := strings.TrimPrefix(strings.TrimPrefix(s[3:end], "\r"), "\n")
+		if s[0] == '\'' {
+			return body, s[end+3:], nil
+		}
+		v, err := tomlUnescape(body)
+		if err != nil {
+			return "", "", err
+		}
+		return v, s[end+3:], nil
+	}
+	if s[0] == '\'' {
+		end := strings.IndexByte(s[1:], '\'')
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated string")
+		}
+		return s[1 : end+1], s[end+2:], nil
+	}
+
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			v, err := strconv.Unquote(s[:i+1])
+			if err != nil {
+				return "", "", fmt.Errorf("invalid string %s", s[:i+1])
+			}
+			return v, s[i+1:], nil
+		}
+	}
+	return "", "", fmt.Errorf("unterminated string")
+}
+
+// tomlUnescape resolves the escapes in the body of a multi-line basic
+// string. A backslash ending a line drops the line break and any
+// whitespace that follows.
+func tomlUnescape(s string) (string, error) {
+	var b strings.Builder
+	for s != "" {
+		if s[0] == '\\' {
+			if rest := strings.TrimLeft(s[1:], " \t\r"); strings.HasPrefix(rest, "\n") {
+				s = strings.TrimLeft(rest, " \t\r\n")
+				continue
+			}
+		}
+		if s[0] == '"' {
+			b.WriteByte('"')
+			s = s[1:]
+			continue
+		}
+		r, _, tail, err := strconv.UnquoteChar(s, '"')
+		if err != nil {
+			return "", fmt.Errorf("invalid escape in string")
+		}
+		b.WriteRune(r)
+		s = tail
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,115 @@
+package parse
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTOML(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want map[string]any
+	}{
+		{
+			name: "scalars",
+			in:   "s = \"a\\tb\" # comment\nl = 'C:\\dir'\ni = 1_000\nf = 1.5\nb = true",
+			want: map[string]any{"s": "a\tb", "l": `C:\dir`, "i": int64(1000), "f": 1.5, "b": true},
+		},
+		{
+			name: "quoted date and comment without space",
+			in:   "d = \"1979-05-27\"#comment\nn = 1#comment",
+			want: map[string]any{"d": "1979-05-27", "n": int64(1)},
+		},
+		{
+			name: "decimal with prefixes",
+			in:   "dec = -42\nzero = 0\nhex = 0xff\noct = 0o10\nbin = 0b101",
+			want: map[string]any{"dec": int64(-42), "zero": int64(0), "hex": int64(255), "oct": int64(8), "bin": int64(5)},
+		},
+		{
+			name: "tables",
+			in:   "top = 1\n[a.b]\nc = 2\n[[list]]\nn = 1\n[[list]]\nn = 2\n[list.sub]\nx = 'y'",
+			want: map[string]any{
+				"top": int64(1),
+				"a":   map[string]any{"b": map[string]any{"c": int64(2)}},
+				"list": []any{
+					map[string]any{"n": int64(1)},
+					map[string]any{"n": int64(2), "sub": map[string]any{"x": "y"}},
+				},
+			},
+		},
+		{
+			name: "quoted and dotted keys",
+			in:   "\"a.b\" = 1\nc.'d' = 2",
+			want: map[string]any{"a.b": int64(1), "c": map[string]any{"d": int64(2)}},
+		},
+		{
+			name: "arrays over several lines",
+			in:   "xs = [\n  1, # one\n  2,\n]\nt = { a = 'x', b.c = [true] }",
+			want: map[string]any{
+				"xs": []any{int64(1), int64(2)},
+				"t":  map[string]any{"a": "x", "b": map[string]any{"c": []any{true}}},
+			},
+		},
+		{
+			name: "multi-line literal string",
+			in:   "ecdsa_public_key = '''\n-----BEGIN PUBLIC KEY-----\nMFkw#notacomment\n-----END PUBLIC KEY-----\n''' # trailing\nafter = 1",
+			want: map[string]any{
+				"ecdsa_public_key": "-----BEGIN PUBLIC KEY-----\nMFkw#notacomment\n-----END PUBLIC KEY-----\n",
+				"after":            int64(1),
+			},
+		},
+		{
+			name: "multi-line basic string",
+			in:   "s = \"\"\"\nline \"one\"\\n  two \\\n    three\"\"\"\"\none = \"\"\"x\"\"\"",
+			want: map[string]any{"s": "line \"one\"\n  two three\"", "one": "x"},
+		},
+		{
+			name: "crlf",
+			in:   "a = 1\r\nb = '''\r\nx\r\n'''\r\n",
+			want: map[string]any{"a": int64(1), "b": "x\n"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := TOML([]byte(tt.in))
+			if err != nil {
+				t.Fatalf("TOML: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TOML =\n%#v\nwant\n%#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTOMLErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"leading zero", "n = 010", "leading zeros"},
+		{"duplicate key", "a = 1\na = 2", "duplicate key"},
+		{"missing value", "a =", "missing value"},
+		{"no assignment", "a", "expected key = value"},
+		{"bad bare key", "a b = 1", "invalid bare key"},
+		{"unterminated string", "a = \"x", "unterminated string"},
+		{"unterminated multi-line string", "a = '''\nx", "unterminated multi-line string"},
+		{"trailing junk", "a = 1 2", "after value"},
+		{"date", "d = 1979-05-27", "dates and times are not supported"},
+		{"date-time", "d = 1979-05-27T07:32:00Z", "dates and times are not supported"},
+		{"time", "t = 07:32:00", "dates and times are not supported"},
+		{"table over value", "a = 1\n[a]", "not a table"},
+		{"table header", "[a", "invalid table header"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := TOML([]byte(tt.in))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("TOML(%q) error = %v, want %q", tt.in, err, tt.want)
+			}
+		})
+	}
+}
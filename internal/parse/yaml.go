@@ -0,0 +1,339 @@
+// Package parse reads the subsets of YAML and TOML used by manifests and
+// config files into plain maps, slices and scalars, without third-party
+// dependencies.
+package parse
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// yamlLine is a non-blank line with its comment removed.
+type yamlLine struct {
+	num    int
+	indent int
+	text   string
+}
+
+// YAML reads the block-style subset of YAML:
+// mappings, sequences, flow sequences such as [a, b], quoted and plain
+// scalars, and comments. A quote only opens a quoted scalar at the start of
+// a value, so plain scalars may hold apostrophes. Anchors, multi-document
+// files, block scalars, flow mappings and plain dates, which YAML 1.1 reads
+// as timestamps, are rejected rather than misread.
+func YAML(data []byte) (any, error) {
+	var lines []yamlLine
+	for i, raw := range strings.Split(string(data), "\n") {
+		raw = strings.TrimRight(stripYAMLComment(raw), " \t\r")
+		text := strings.TrimLeft(raw, " ")
+		if text == "" || (i == 0 && text == "---") {
+			continue
+		}
+		if strings.HasPrefix(text, "\t") {
+			return nil, fmt.Errorf("yaml line %d: tabs are not allowed for indentation", i+1)
+		}
+		lines = append(lines, yamlLine{num: i + 1, indent: len(raw) - len(text), text: text})
+	}
+	if len(lines) == 0 {
+		return map[string]any{}, nil
+	}
+
+	p := &yamlParser{lines: lines}
+	v, err := p.block(lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(lines) {
+		return nil, fmt.Errorf("yaml line %d: unexpected indentation", lines[p.pos].num)
+	}
+	return v, nil
+}
+
+// stripYAMLComment removes a trailing # comment outside quotes. A quote
+// only opens a quoted scalar at the start of a key, a value or a flow
+// sequence item; anywhere else it is part of a plain scalar, as in
+// "name: don't panic # comment".
+func stripYAMLComment(line string) string {
+	var quote byte
+	depth := 0    // open flow sequences
+	start := true // whether a scalar may start here
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote == '"':
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				quote = 0
+			}
+		case quote == '\'':
+			if c == '\'' && i+1 < len(line) && line[i+1] == '\'' {
+				i++
+			} else if c == '\'' {
+				quote = 0
+			}
+		case c == ' ' || c == '\t':
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		case start && (c == '"' || c == '\''):
+			quote = c
+			start = false
+		case start && c == '-' && (i+1 == len(line) || line[i+1] == ' '):
+			// A sequence item, whose value starts after it.
+		case start && c == '[':
+			depth++
+		case depth > 0 && c == ',':
+			start = true
+		case depth > 0 && c == ']':
+			depth--
+		case depth == 0 && c == ':' && (i+1 == len(line) || line[i+1] == ' ' || line[i+1] == '\t'):
+			start = true
+		default:
+			start = false
+		}
+	}
+	return line
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+func (p *yamlParser) errorf(format string, args ...any) error {
+	num := p.lines[len(p.lines)-1].num
+	if p.pos < len(p.lines) {
+		num = p.lines[p.pos].num
+	}
+	return fmt.Errorf("yaml line %d: %s", num, fmt.Sprintf(format, args...))
+}
+
+// block parses the mapping or sequence starting at the current line.
+func (p *yamlParser) block(indent int) (any, error) {
+	if isSequenceItem(p.lines[p.pos].text) {
+		return p.sequence(indent)
+	}
+	return p.mapping(indent)
+}
+
+func isSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+func (p *yamlParser) sequence(indent int) ([]any, error) {
+	items := []any{}
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isSequenceItem(p.lines[p.pos].text) {
+		line := p.lines[p.pos]
+		rest := strings.TrimLeft(strings.TrimPrefix(line.text, "-"), " ")
+
+		switch {
+		case rest == "":
+			p.pos++
+			if p.pos >= len(p.lines) || p.lines[p.pos].indent <= indent {
+				items = append(items, nil)
+				continue
+			}
+			item, err := p.block(p.lines[p.pos].indent)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+
+		case isSequenceItem(rest) || mappingKey(rest) >= 0:
+			// "- key: value" opens a mapping, or a nested sequence, whose
+			// lines are indented to where the first one starts.
+			offset := len(line.text) - len(rest)
+			p.lines[p.pos] = yamlLine{num: line.num, indent: indent + offset, text: rest}
+			item, err := p.block(indent + offset)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+
+		default:
+			v, err := yamlScalar(rest)
+			if err != nil {
+				return nil, p.errorf("%v", err)
+			}
+			items = append(items, v)
+			p.pos++
+		}
+	}
+	return items, nil
+}
+
+func (p *yamlParser) mapping(indent int) (map[string]any, error) {
+	m := map[string]any{}
+	for p.pos < len(p.lines) && p.lines[p.pos].indent == indent {
+		line := p.lines[p.pos]
+		if isSequenceItem(line.text) {
+			return nil, p.errorf("expected a key, found a list item")
+		}
+		i := mappingKey(line.text)
+		if i < 0 {
+			return nil, p.errorf("expected \"key: value\"")
+		}
+		key, err := yamlKey(line.text[:i])
+		if err != nil {
+			return nil, p.errorf("%v", err)
+		}
+		if _, dup := m[key]; dup {
+			return nil, p.errorf("duplicate key %q", key)
+		}
+		value := strings.TrimSpace(line.text[i+1:])
+		p.pos++
+
+		if value != "" {
+			v, err := yamlScalar(value)
+			if err != nil {
+				p.pos--
+				return nil, p.errorf("%v", err)
+			}
+			m[key] = v
+			continue
+		}
+
+		// A nested block is indented further, except that a sequence may
+		// sit at the same indentation as its key.
+		switch {
+		case p.pos < len(p.lines) && p.lines[p.pos].indent > indent:
+			m[key], err = p.block(p.lines[p.pos].indent)
+		case p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isSequenceItem(p.lines[p.pos].text):
+			m[key], err = p.sequence(indent)
+		default:
+			m[key] = nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// mappingKey returns the index of the colon ending a mapping key, or -1.
+func mappingKey(text string) int {
+	var quote byte
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && i == 0:
+			quote = c
+		case c == ':' && (i == len(text)-1 || text[i+1] == ' '):
+			return i
+		case c == '[' || c == '{':
+			if i == 0 {
+				return -1
+			}
+		}
+	}
+	return -1
+}
+
+func yamlKey(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if s != "" && (s[0] == '"' || s[0] == '\'') {
+		v, err := yamlScalar(s)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprint(v), nil
+	}
+	return s, nil
+}
+
+// yamlScalar parses an inline value: a quoted or plain scalar or a flow
+// sequence.
+func yamlScalar(s string) (any, error) {
+	switch {
+	case s == "|" || s == ">" || strings.HasPrefix(s, "|") || strings.HasPrefix(s, ">"):
+		return nil, fmt.Errorf("block scalars are not supported")
+	case strings.HasPrefix(s, "&") || strings.HasPrefix(s, "*") || strings.HasPrefix(s, "!"):
+		return nil, fmt.Errorf("anchors, aliases and tags are not supported")
+	case strings.HasPrefix(s, "{"):
+		return nil, fmt.Errorf("flow mappings are not supported")
+	case strings.HasPrefix(s, "["):
+		if !strings.HasSuffix(s, "]") {
+			return nil, fmt.Errorf("unterminated list %s", s)
+		}
+		items := []any{}
+		for _, item := range splitFlow(s[1 : len(s)-1]) {
+			item = strings.TrimSpace(item)
+			if item == "" {
+				continue
+			}
+			v, err := yamlScalar(item)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, v)
+		}
+		return items, nil
+	case strings.HasPrefix(s, `"`):
+		v, err := strconv.Unquote(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid quoted string %s", s)
+		}
+		return v, nil
+	case strings.HasPrefix(s, "'"):
+		if len(s) < 2 || !strings.HasSuffix(s, "'") {
+			return nil, fmt.Errorf("invalid quoted string %s", s)
+		}
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	}
+
+	switch s {
+	case "null", "Null", "NULL", "~":
+		return nil, nil
+	case "true", "True", "TRUE":
+		return true, nil
+	case "false", "False", "FALSE":
+		return false, nil
+	}
+	if isDateTime(s) {
+		return nil, fmt.Errorf("dates and times are not supported, quote %s to keep it as a string", s)
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return n, nil
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f, nil
+	}
+	return s, nil
+}
+
+// splitFlow splits the inside of a flow sequence on commas outside quotes
+// and nested brackets. Like in stripYAMLComment, a quote only opens at the
+// start of an item.
+func splitFlow(s string) []string {
+	var parts []string
+	var quote byte
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case (c == '"' || c == '\'') && strings.TrimSpace(s[start:i]) == "":
+			quote = c
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		case c == ',' && depth == 0:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
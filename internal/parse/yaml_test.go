@@ -0,0 +1,92 @@
+package parse
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestYAML(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want any
+	}{
+		{
+			name: "empty",
+			in:   "# nothing\n",
+			want: map[string]any{},
+		},
+		{
+			name: "scalars",
+			in:   "---\ns: hello world\nq: \"a: b\" # comment\nl: 'it''s'\nn: 010\nf: 1.5\nb: true\nz: ~",
+			want: map[string]any{"s": "hello world", "q": "a: b", "l": "it's", "n": int64(10), "f": 1.5, "b": true, "z": nil},
+		},
+		{
+			name: "nested",
+			in:   "servers:\n  - name: local\n    tags: [a, 'b, c']\n  - name: prod\nplain:\n  key: value",
+			want: map[string]any{
+				"servers": []any{
+					map[string]any{"name": "local", "tags": []any{"a", "b, c"}},
+					map[string]any{"name": "prod"},
+				},
+				"plain": map[string]any{"key": "value"},
+			},
+		},
+		{
+			name: "apostrophes in plain scalars",
+			in:   "name: don't panic # comment\n'quoted key': it's 'fine'\nlist: [rock 'n' roll, 'a # b']\nitems:\n  - o'clock # c",
+			want: map[string]any{
+				"name":       "don't panic",
+				"quoted key": "it's 'fine'",
+				"list":       []any{"rock 'n' roll", "a # b"},
+				"items":      []any{"o'clock"},
+			},
+		},
+		{
+			name: "quoted date",
+			in:   "d: '2024-01-02'",
+			want: map[string]any{"d": "2024-01-02"},
+		},
+		{
+			name: "top-level sequence",
+			in:   "- 1\n- two",
+			want: []any{int64(1), "two"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := YAML([]byte(tt.in))
+			if err != nil {
+				t.Fatalf("YAML: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("YAML =\n%#v\nwant\n%#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestYAMLErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"tab indentation", "a:\n\tb: 1", "tabs"},
+		{"bad indentation", "a: 1\n  b: 2", "indentation"},
+		{"block scalar", "a: |\n  text", ""},
+		{"anchor", "a: &x 1", ""},
+		{"date", "a: 2024-01-02", "dates and times are not supported"},
+		{"date in a list", "a: [1, 2024-01-02]", "dates and times are not supported"},
+		{"unterminated quote", "a: \"b # c", "invalid quoted string"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := YAML([]byte(tt.in))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("YAML(%q) error = %v, want %q", tt.in, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBuckets are the latency buckets in seconds, matching the Prometheus
+// client defaults.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+var _ Recorder = (*Collector)(nil)
+
+// Collector is an in-memory Recorder. It implements http.Handler and serves
+// its metrics in the Prometheus text exposition format, so it can be mounted
+// on a /metrics endpoint directly.
+type Collector struct {
+	mu sync.Mutex
+
+	httpRequests    *family
+	httpDuration    *family
+	reducerCalls    *family
+	reducerDuration *family
+	wsMessages      *family
+	wsBytes         *family
+	cacheRows       *family
+}
+
+// NewCollector creates a Collector whose metric names start with
+// "spacetimedb_client_".
+func NewCollector() *Collector {
+	const p = "spacetimedb_client_"
+	return &Collector{
+		httpRequests:    newFamily(p+"http_requests_total", "HTTP requests by method, route and status code.", "counter", "method", "route", "code"),
+		httpDuration:    newFamily(p+"http_request_duration_seconds", "HTTP request latency.", "histogram", "method", "route"),
+		reducerCalls:    newFamily(p+"reducer_calls_total", "Reducer calls by outcome.", "counter", "reducer", "outcome"),
+		reducerDuration: newFamily(p+"reducer_call_duration_seconds", "Reducer call latency.", "histogram", "reducer"),
+		wsMessages:      newFamily(p+"websocket_messages_total", "Websocket messages by direction and type.", "counter", "direction", "type"),
+		wsBytes:         newFamily(p+"websocket_message_bytes_total", "Websocket payload bytes by direction.", "counter", "direction"),
+		cacheRows:       newFamily(p+"subscription_cache_rows", "Rows held in the subscription cache per table.", "gauge", "table"),
+	}
+}
+
+func (c *Collector) HTTPRequest(method, route string, status int, duration time.Duration, err error) {
+	code := strconv.Itoa(status)
+	if err != nil {
+		code = "error"
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.httpRequests.add(1, method, route, code)
+	c.httpDuration.observe(duration.Seconds(), method, route)
+}
+
+func (c *Collector) ReducerCall(reducer, outcome string, duration time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reducerCalls.add(1, reducer, outcome)
+	c.reducerDuration.observe(duration.Seconds(), reducer)
+}
+
+func (c *Collector) WebsocketMessage(direction, msgType string, bytes int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.wsMessages.add(1, direction, msgType)
+	c.wsBytes.add(float64(bytes), direction)
+}
+
+func (c *Collector) CacheRows(table string, rows int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cacheRows.set(float64(rows), table)
+}
+
+// WriteTo writes all metrics in the Prometheus text exposition format.
+func (c *Collector) WriteTo(w io.Writer) (int64, error) {
+	var sb strings.Builder
+	c.mu.Lock()
+	for _, f := range []*family{
+		c.httpRequests, c.httpDuration, c.reducerCalls, c.reducerDuration,
+		c.wsMessages, c.wsBytes, c.cacheRows,
+	} {
+		f.write(&sb)
+	}
+	c.mu.Unlock()
+
+	n, err := io.WriteString(w, sb.String())
+	return int64(n), err
+}
+
+func (c *Collector) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.WriteTo(w)
+}
+
+// family is one metric name with its labelled series.
+type family struct {
+	name   string
+	help   string
+	kind   string
+	labels []string
+	series map[string]*series
+}
+
+type series struct {
+	values []string
+	value  float64
+	// histogram only
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func newFamily(name, help, kind string, labels ...string) *family {
+	return &family{name: name, help: help, kind: kind, labels: labels, series: map[string]*series{}}
+}
+
+func (f *family) get(values []string) *series {
+	key := strings.Join(values, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{values: values}
+		if f.kind == "histogram" {
+			s.counts = make([]uint64, len(DefaultBuckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+func (f *family) add(v float64, values ...string) { f.get(values).value += v }
+
+func (f *family) set(v float64, values ...string) { f.get(values).value = v }
+
+func (f *family) observe(v float64, values ...string) {
+	s := f.get(values)
+	for i, upper := range DefaultBuckets {
+		if v <= upper {
+			s.counts[i]++
+		}
+	}
+	s.sum += v
+	s.count++
+}
+
+func (f *family) write(sb *strings.Builder) {
+	if len(f.series) == 0 {
+		return
+	}
+	fmt.Fprintf(sb, "# HELP %s %s\n# TYPE %s %s\n", f.name, f.help, f.name, f.kind)
+
+	keys := make([]string, 0, len(f.series))
+	for k := range f.series {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	for _, k := range keys {
+		s := f.series[k]
+		if f.kind != "histogram" {
+			fmt.Fprintf(sb, "%s%s %s\n", f.name, labelString(f.labels, s.values), formatFloat(s.value))
+			continue
+		}
+		for i, upper := range DefaultBuckets {
+			fmt.Fprintf(sb, "%s_bucket%s %d\n", f.name,
+				labelString(append(slices.Clone(f.labels), "le"), append(slices.Clone(s.values), formatFloat(upper))), s.counts[i])
+		}
+		fmt.Fprintf(sb, "%s_bucket%s %d\n", f.name,
+			labelString(append(slices.Clone(f.labels), "le"), append(slices.Clone(s.values), "+Inf")), s.count)
+		fmt.Fprintf(sb, "%s_sum%s %s\n", f.name, labelString(f.labels, s.values), formatFloat(s.sum))
+		fmt.Fprintf(sb, "%s_count%s %d\n", f.name, labelString(f.labels, s.values), s.count)
+	}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func labelString(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf(`%s="%s"`, name, labelEscaper.Replace(values[i]))
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
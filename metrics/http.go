@@ -0,0 +1,49 @@
+package metrics
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	httpClient "github.com/briheet/spacetime-goclient/transport/http"
+)
+
+// HTTPMiddleware records every request sent through the HTTP transport.
+// Requests to the reducer call endpoint are also recorded as reducer calls.
+func HTTPMiddleware(r Recorder) httpClient.Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return httpClient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+			elapsed := time.Since(start)
+
+			route := Route(req.URL.Path)
+			status := 0
+			if resp != nil {
+				status = resp.StatusCode
+			}
+			r.HTTPRequest(req.Method, route, status, elapsed, err)
+
+			if reducer, ok := reducerName(req.URL.Path); ok {
+				outcome := "ok"
+				switch {
+				case err != nil:
+					outcome = "error"
+				case status != http.StatusOK:
+					outcome = "failed"
+				}
+				r.ReducerCall(reducer, outcome, elapsed)
+			}
+
+			return resp, err
+		})
+	}
+}
+
+func reducerName(path string) (string, bool) {
+	segs := strings.Split(strings.Trim(path, "/"), "/")
+	if len(segs) == 5 && segs[0] == "v1" && segs[1] == "database" && segs[3] == "call" {
+		return segs[4], true
+	}
+	return "", false
+}
@@ -0,0 +1,60 @@
+// Package metrics records client-side metrics through a neutral Recorder
+// interface. Collector is a ready-made Recorder that serves the Prometheus
+// text format; other backends only need to implement Recorder.
+package metrics
+
+import (
+	"strings"
+	"time"
+)
+
+// Recorder receives measurements from the transports and caches.
+type Recorder interface {
+	// HTTPRequest records one HTTP attempt. status is 0 when err is set.
+	HTTPRequest(method, route string, status int, duration time.Duration, err error)
+	// ReducerCall records the outcome ("ok", "failed", "error") of a reducer call.
+	ReducerCall(reducer, outcome string, duration time.Duration)
+	// WebsocketMessage records a websocket message sent ("out") or received ("in").
+	WebsocketMessage(direction, msgType string, bytes int)
+	// CacheRows reports the number of rows currently cached for a table.
+	CacheRows(table string, rows int)
+}
+
+// Noop is a Recorder that drops everything.
+var Noop Recorder = noop{}
+
+type noop struct{}
+
+func (noop) HTTPRequest(string, string, int, time.Duration, error) {}
+func (noop) ReducerCall(string, string, time.Duration)             {}
+func (noop) WebsocketMessage(string, string, int)                  {}
+func (noop) CacheRows(string, int)                                 {}
+
+// Route collapses database names, identities and reducer names in an API
+// path so it can be used as a low-cardinality label, e.g.
+// "/v1/database/quickstart-chat/call/send_message" becomes
+// "/v1/database/:db/call/:reducer".
+func Route(path string) string {
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path = path[:i]
+	}
+	segs := strings.Split(strings.Trim(path, "/"), "/")
+	if len(segs) < 3 || segs[0] != "v1" {
+		return path
+	}
+
+	switch segs[1] {
+	case "database":
+		segs[2] = ":db"
+		if len(segs) >= 5 && segs[3] == "call" {
+			segs[4] = ":reducer"
+		}
+	case "identity":
+		if segs[2] != "public-key" && segs[2] != "websocket-token" {
+			segs[2] = ":identity"
+		}
+	case "energy":
+		segs[2] = ":identity"
+	}
+	return "/" + strings.Join(segs, "/")
+}
@@ -0,0 +1,18 @@
+package metrics
+
+import (
+	websocketsClient "github.com/briheet/spacetime-goclient/transport/websockets"
+	"github.com/gorilla/websocket"
+)
+
+// WebsocketHook records every message on connections opened by a websocket
+// transport configured with websocketsClient.WithMessageHook.
+func WebsocketHook(r Recorder) websocketsClient.MessageHook {
+	return func(direction string, messageType int, size int) {
+		msgType := "binary"
+		if messageType == websocket.TextMessage {
+			msgType = "text"
+		}
+		r.WebsocketMessage(direction, msgType, size)
+	}
+}
@@ -0,0 +1,616 @@
+package spacetimedb
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidArgs matches every error reporting reducer arguments that do
+// not fit the reducer's parameters, see ArgError.
+var ErrInvalidArgs = errors.New("invalid reducer arguments")
+
+// ErrLifecycleReducer is returned for a call to a reducer only the host
+// may call, such as init or client_connected.
+var ErrLifecycleReducer = errors.New("lifecycle reducers cannot be called")
+
+// ArgError reports a reducer argument that does not fit its parameter.
+type ArgError struct {
+	Reducer string
+	// Index is the argument's position, from 0.
+	Index int
+	Param Field
+	Err   error
+}
+
+func (e *ArgError) Error() string {
+	name := ""
+	if e.Param.Name != "" {
+		name = " (" + e.Param.Name + ")"
+	}
+	return fmt.Sprintf("arg %d%s of %s expects %s: %v", e.Index+1, name, e.Reducer, e.Param.Type, e.Err)
+}
+
+func (e *ArgError) Unwrap() error { return e.Err }
+
+// Is reports ArgError as ErrInvalidArgs.
+func (e *ArgError) Is(target error) bool { return target == ErrInvalidArgs }
+
+// Signature renders the reducer's parameters, e.g. "send_message(text: String)".
+func (r *ReducerDef) Signature() string {
+	return r.Name + "(" + fieldList(r.Params, map[*AlgebraicType]bool{}) + ")"
+}
+
+// ParseArgs converts arguments written as text, as on a command line, into
+// the SATS JSON values the reducer's parameters expect, checking their
+// number and types first. See ParseValue for the accepted forms.
+func (r *ReducerDef) ParseArgs(args []string) ([]any, error) {
+	if err := r.callable(); err != nil {
+		return nil, err
+	}
+	if err := r.checkCount(len(args)); err != nil {
+		return nil, err
+	}
+	out := make([]any, len(args))
+	for i, arg := range args {
+		v, err := ParseValue(r.Params[i].Type, arg)
+		if err != nil {
+			return nil, &ArgError{Reducer: r.Name, Index: i, Param: r.Params[i], Err: err}
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+// CheckArgs checks the arguments of a call to the reducer, given as they
+// would be passed to CallReducer: a slice or array with one element per
+// parameter, or a struct or map keyed by parameter name. nil stands for no
+// arguments. Lifecycle reducers are refused with ErrLifecycleReducer.
+func (r *ReducerDef) CheckArgs(args any) error {
+	_, err := r.normalizeArgs(args)
+	return err
+}
+
+// normalizeArgs checks args like CheckArgs and returns them in the SATS
+// JSON form the host expects, one element per parameter: RFC 3339
+// timestamps and "1m30s" durations become microseconds, bare hex
+// identities gain their 0x and quoted numbers are unquoted.
+func (r *ReducerDef) normalizeArgs(args any) ([]any, error) {
+	if err := r.callable(); err != nil {
+		return nil, err
+	}
+	if args == nil {
+		if err := r.checkCount(0); err != nil {
+			return nil, err
+		}
+		return []any{}, nil
+	}
+	v := reflect.ValueOf(args)
+	for v.Kind() == reflect.Pointer && !v.IsNil() {
+		v = v.Elem()
+	}
+
+	var values []any
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		if _, raw := args.(json.RawMessage); !raw && v.Type().Elem().Kind() != reflect.Uint8 {
+			for i := 0; i < v.Len(); i++ {
+				values = append(values, v.Index(i).Interface())
+			}
+			if err := r.checkCount(len(values)); err != nil {
+				return nil, err
+			}
+			break
+		}
+		fallthrough
+	default:
+		// Anything else is checked as the JSON it encodes to.
+		decoded, err := decodeJSON(args)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %w", ErrInvalidArgs, r.Name, err)
+		}
+		switch decoded := decoded.(type) {
+		case []any:
+			values = decoded
+			if err := r.checkCount(len(values)); err != nil {
+				return nil, err
+			}
+		case map[string]any:
+			for name := range decoded {
+				if !hasField(r.Params, name) {
+					return nil, fmt.Errorf("%w: %s has no parameter %s", ErrInvalidArgs, r.Signature(), name)
+				}
+			}
+			for _, p := range r.Params {
+				arg, ok := decoded[p.Name]
+				if !ok {
+					return nil, fmt.Errorf("%w: %s is missing %s", ErrInvalidArgs, r.Signature(), p.Name)
+				}
+				values = append(values, arg)
+			}
+		default:
+			return nil, fmt.Errorf("%w: %s takes a list or object of arguments, got %s", ErrInvalidArgs, r.Name, describe(decoded))
+		}
+	}
+
+	out := make([]any, len(values))
+	for i, arg := range values {
+		decoded, err := decodeJSON(arg)
+		if err == nil {
+			out[i], err = checkValue(r.Params[i].Type, decoded)
+		}
+		if err != nil {
+			// A value of the wrong kind is best described by its Go type.
+			if _, ok := err.(*mismatchError); ok {
+				if _, fromJSON := arg.(json.RawMessage); !fromJSON && !isDecoded(arg) {
+					err = fmt.Errorf("got %T", arg)
+				}
+			}
+			return nil, &ArgError{Reducer: r.Name, Index: i, Param: r.Params[i], Err: err}
+		}
+	}
+	return out, nil
+}
+
+// callable refuses the lifecycle reducers the host calls itself.
+func (r *ReducerDef) callable() error {
+	if r.Lifecycle != "" {
+		return fmt.Errorf("%w: %s is the %s reducer", ErrLifecycleReducer, r.Name, r.Lifecycle)
+	}
+	return nil
+}
+
+func (r *ReducerDef) checkCount(n int) error {
+	if n != len(r.Params) {
+		return fmt.Errorf("%w: %s takes %d argument(s), got %d", ErrInvalidArgs, r.Signature(), len(r.Params), n)
+	}
+	return nil
+}
+
+// decodeJSON round-trips v through JSON, keeping numbers as json.Number.
+func decodeJSON(v any) (any, error) {
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(encoded))
+	dec.UseNumber()
+	var decoded any
+	if err := dec.Decode(&decoded); err != nil {
+		return nil, err
+	}
+	return decoded, nil
+}
+
+// isDecoded reports whether v is already a decoded JSON value, whose Go
+// type says less than describe does.
+func isDecoded(v any) bool {
+	switch v.(type) {
+	case nil, []any, map[string]any, json.Number:
+		return true
+	}
+	return false
+}
+
+// mismatchError reports a value of the wrong kind for its type.
+type mismatchError struct{ v any }
+
+func (e *mismatchError) Error() string { return "got " + describe(e.v) }
+
+// ParseValue parses s as a value of type t and returns it in SATS JSON
+// form. Strings are taken as they are, numbers and booleans in Go syntax
+// with ranges checked, Identity and ConnectionId as hex with or without
+// 0x, Timestamp as RFC 3339 or microseconds since the epoch and
+// TimeDuration as a Go duration such as "1m30s". An Option is "null" or
+// its value, a plain enum variant its bare name, and arrays, structs and
+// enums with a payload are JSON, e.g. [1,2], {"x":1,"y":2} or
+// {"Move":{"x":1}}.
+func ParseValue(t *AlgebraicType, s string) (any, error) {
+	if t == nil {
+		return nil, fmt.Errorf("unknown type")
+	}
+	if t.Kind == KindRef {
+		return nil, fmt.Errorf("unresolved type %s", t)
+	}
+	if t.Special() != "" {
+		return checkValue(t, s)
+	}
+	if inner, ok := t.Option(); ok {
+		if s == "null" {
+			return map[string]any{"none": []any{}}, nil
+		}
+		v, err := ParseValue(inner, s)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"some": v}, nil
+	}
+
+	switch t.Kind {
+	case "String":
+		return s, nil
+	case "Bool":
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, fmt.Errorf("want true or false, got %q", s)
+		}
+		return b, nil
+	case KindSum:
+		for _, v := range t.Variants {
+			if v.Name == s && isUnit(v.Type) {
+				return map[string]any{s: []any{}}, nil
+			}
+		}
+	}
+	if _, ok := intBits[t.Kind]; ok || t.Kind == "F32" || t.Kind == "F64" {
+		return checkValue(t, json.Number(s))
+	}
+
+	switch t.Kind {
+	case KindArray, KindProduct, KindSum:
+		dec := json.NewDecoder(strings.NewReader(s))
+		dec.UseNumber()
+		var v any
+		if err := dec.Decode(&v); err != nil {
+			return nil, fmt.Errorf("want JSON for %s: %w", t, err)
+		}
+		return checkValue(t, v)
+	}
+	return nil, fmt.Errorf("unsupported type %s", t)
+}
+
+// intBits gives the width of each integer type and whether it is signed.
+var intBits = map[string]struct {
+	bits   int
+	signed bool
+}{
+	"U8": {8, false}, "U16": {16, false}, "U32": {32, false}, "U64": {64, false}, "U128": {128, false}, "U256": {256, false},
+	"I8": {8, true}, "I16": {16, true}, "I32": {32, true}, "I64": {64, true}, "I128": {128, true}, "I256": {256, true},
+}
+
+// checkValue checks v, a value decoded from JSON with numbers as
+// json.Number, against t and returns it in the SATS JSON form the host
+// expects, e.g. products as arrays in field order. Schemas resolve every
+// Ref when they are decoded, so one left in t is an error, not a value to
+// pass through unchecked.
+func checkValue(t *AlgebraicType, v any) (any, error) {
+	if t == nil {
+		return v, nil
+	}
+	if t.Kind == KindRef {
+		return nil, fmt.Errorf("unresolved type %s", t)
+	}
+	switch t.Special() {
+	case "Identity":
+		return hexValue("__identity__", v, 64)
+	case "ConnectionId":
+		return hexValue("__connection_id__", v, 32)
+	case "Timestamp":
+		return timestampValue(v)
+	case "TimeDuration":
+		return durationValue(v)
+	}
+
+	if inner, ok := t.Option(); ok {
+		if v == nil {
+			return map[string]any{"none": []any{}}, nil
+		}
+		if m, ok := v.(map[string]any); ok && len(m) == 1 {
+			if _, none := m["none"]; none {
+				return map[string]any{"none": []any{}}, nil
+			}
+			if some, ok := m["some"]; ok {
+				v = some
+			}
+		}
+		inner, err := checkValue(inner, v)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"some": inner}, nil
+	}
+
+	if w, ok := intBits[t.Kind]; ok {
+		return intValue(t.Kind, w.bits, w.signed, v)
+	}
+
+	switch t.Kind {
+	case "String":
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+		return nil, &mismatchError{v}
+	case "Bool":
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+		return nil, &mismatchError{v}
+	case "F32", "F64":
+		n, ok := number(v)
+		if !ok {
+			return nil, &mismatchError{v}
+		}
+		bits := 64
+		if t.Kind == "F32" {
+			bits = 32
+		}
+		f, err := strconv.ParseFloat(string(n), bits)
+		if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+			return nil, fmt.Errorf("%s is not a finite %s", n, t.Kind)
+		}
+		return json.Number(strconv.FormatFloat(f, 'g', -1, bits)), nil
+
+	case KindArray:
+		items, ok := v.([]any)
+		if !ok {
+			return nil, &mismatchError{v}
+		}
+		out := make([]any, len(items))
+		for i, item := range items {
+			c, err := checkValue(t.Elem, item)
+			if err != nil {
+				return nil, fmt.Errorf("element %d: %w", i, err)
+			}
+			out[i] = c
+		}
+		return out, nil
+
+	case KindProduct:
+		return productValue(t, v)
+
+	case KindSum:
+		return sumValue(t, v)
+	}
+	return nil, fmt.Errorf("unsupported type %s", t)
+}
+
+func productValue(t *AlgebraicType, v any) (any, error) {
+	var items []any
+	switch v := v.(type) {
+	case []any:
+		if len(v) != len(t.Elements) {
+			return nil, fmt.Errorf("want %d fields for %s, got %d", len(t.Elements), t, len(v))
+		}
+		items = v
+	case map[string]any:
+		items = make([]any, len(t.Elements))
+		for i, f := range t.Elements {
+			item, ok := v[f.Name]
+			if !ok {
+				return nil, fmt.Errorf("missing field %s", f.Name)
+			}
+			items[i] = item
+		}
+		for name := range v {
+			if !hasField(t.Elements, name) {
+				return nil, fmt.Errorf("unknown field %s", name)
+			}
+		}
+	default:
+		return nil, &mismatchError{v}
+	}
+
+	out := make([]any, len(items))
+	for i, item := range items {
+		c, err := checkValue(t.Elements[i].Type, item)
+		if err != nil {
+			name := t.Elements[i].Name
+			if name == "" {
+				name = strconv.Itoa(i)
+			}
+			return nil, fmt.Errorf("field %s: %w", name, err)
+		}
+		out[i] = c
+	}
+	return out, nil
+}
+
+func sumValue(t *AlgebraicType, v any) (any, error) {
+	name, payload := "", any([]any{})
+	switch v := v.(type) {
+	case string:
+		name = v
+	case map[string]any:
+		if len(v) != 1 {
+			return nil, fmt.Errorf("want one variant of %s, got %d keys", t, len(v))
+		}
+		for k, p := range v {
+			name, payload = k, p
+		}
+	default:
+		return nil, &mismatchError{v}
+	}
+
+	for _, variant := range t.Variants {
+		if variant.Name != name {
+			continue
+		}
+		c, err := checkValue(variant.Type, payload)
+		if err != nil {
+			return nil, fmt.Errorf("variant %s: %w", name, err)
+		}
+		return map[string]any{name: c}, nil
+	}
+	return nil, fmt.Errorf("%s has no variant %q", t, name)
+}
+
+func hasField(fields []Field, name string) bool {
+	for _, f := range fields {
+		if f.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func isUnit(t *AlgebraicType) bool {
+	return t == nil || (t.Kind == KindProduct && len(t.Elements) == 0)
+}
+
+// number returns v as a json.Number, accepting numeric strings too since
+// large integers are often quoted.
+func number(v any) (json.Number, bool) {
+	switch v := v.(type) {
+	case json.Number:
+		return v, true
+	case float64:
+		return json.Number(strconv.FormatFloat(v, 'g', -1, 64)), true
+	case string:
+		if _, err := strconv.ParseFloat(v, 64); err == nil && !strings.ContainsAny(v, "xXoObB") {
+			return json.Number(v), true
+		}
+		if n, ok := parseInt(v); ok {
+			return json.Number(n.String()), true
+		}
+	}
+	return "", false
+}
+
+// parseInt reads an integer in Go syntax: decimal unless it carries a 0x,
+// 0o or 0b prefix, so that 010 is ten, with optional underscores between
+// digits.
+func parseInt(s string) (*big.Int, bool) {
+	digits := strings.TrimLeft(s, "+-")
+	if len(digits) > 1 && digits[0] == '0' && strings.ContainsRune("xXoObB", rune(digits[1])) {
+		return new(big.Int).SetString(s, 0)
+	}
+	return new(big.Int).SetString(strings.ReplaceAll(s, "_", ""), 10)
+}
+
+func intValue(kind string, bits int, signed bool, v any) (any, error) {
+	n, ok := number(v)
+	if !ok {
+		return nil, &mismatchError{v}
+	}
+	i, ok := parseInt(string(n))
+	if !ok {
+		// Whole floats such as 1e3 or 2.0 are fine.
+		f, _, err := big.ParseFloat(string(n), 10, 512, big.ToZero)
+		if err != nil || !f.IsInt() {
+			return nil, fmt.Errorf("%s is not an integer", n)
+		}
+		i, _ = f.Int(nil)
+	}
+
+	min, max := new(big.Int), new(big.Int).Lsh(big.NewInt(1), uint(bits))
+	if signed {
+		max.Rsh(max, 1)
+		min.Neg(max)
+	}
+	max.Sub(max, big.NewInt(1))
+	if i.Cmp(min) < 0 || i.Cmp(max) > 0 {
+		return nil, fmt.Errorf("%s is out of range for %s", i, kind)
+	}
+	return json.Number(i.String()), nil
+}
+
+// hexValue parses an Identity or ConnectionId, given as a hex string or in
+// its SATS form, and returns the SATS form.
+func hexValue(tag string, v any, digits int) (any, error) {
+	switch inner := v.(type) {
+	case map[string]any:
+		if x, ok := inner[tag]; ok && len(inner) == 1 {
+			v = x
+		}
+	case []any:
+		if len(inner) == 1 {
+			v = inner[0]
+		}
+	}
+
+	var hex string
+	switch v := v.(type) {
+	case string:
+		hex = strings.TrimPrefix(strings.TrimPrefix(strings.ToLower(v), "0x"), "0X")
+		if hex == "" || strings.Trim(hex, "0123456789abcdef") != "" {
+			return nil, fmt.Errorf("want %d hex digits, got %q", digits, v)
+		}
+	case json.Number:
+		n, ok := new(big.Int).SetString(string(v), 10)
+		if !ok || n.Sign() < 0 {
+			return nil, fmt.Errorf("want %d hex digits, got %s", digits, v)
+		}
+		hex = n.Text(16)
+	default:
+		return nil, fmt.Errorf("want %d hex digits, got %s", digits, describe(v))
+	}
+	if len(hex) > digits {
+		return nil, fmt.Errorf("want %d hex digits, got %d", digits, len(hex))
+	}
+	return map[string]any{tag: "0x" + strings.Repeat("0", digits-len(hex)) + hex}, nil
+}
+
+const (
+	timestampTag = "__timestamp_micros_since_unix_epoch__"
+	durationTag  = "__time_duration_micros__"
+)
+
+func timestampValue(v any) (any, error) {
+	v = unwrapTag(timestampTag, v)
+	if s, ok := v.(string); ok {
+		if ts, err := time.Parse(time.RFC3339Nano, s); err == nil {
+			return map[string]any{timestampTag: json.Number(strconv.FormatInt(ts.UnixMicro(), 10))}, nil
+		}
+	}
+	micros, err := intValue("Timestamp", 64, true, v)
+	if err != nil {
+		return nil, fmt.Errorf("want an RFC 3339 time or microseconds since the epoch, got %s", describe(v))
+	}
+	return map[string]any{timestampTag: micros}, nil
+}
+
+func durationValue(v any) (any, error) {
+	v = unwrapTag(durationTag, v)
+	if s, ok := v.(string); ok {
+		if d, err := time.ParseDuration(s); err == nil {
+			return map[string]any{durationTag: json.Number(strconv.FormatInt(d.Microseconds(), 10))}, nil
+		}
+	}
+	micros, err := intValue("TimeDuration", 64, true, v)
+	if err != nil {
+		return nil, fmt.Errorf("want a duration such as 1m30s or microseconds, got %s", describe(v))
+	}
+	return map[string]any{durationTag: micros}, nil
+}
+
+func unwrapTag(tag string, v any) any {
+	switch inner := v.(type) {
+	case map[string]any:
+		if x, ok := inner[tag]; ok && len(inner) == 1 {
+			return x
+		}
+	case []any:
+		if len(inner) == 1 {
+			return inner[0]
+		}
+	}
+	return v
+}
+
+// describe names a decoded JSON value and its kind for error messages.
+func describe(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string " + strconv.Quote(v)
+	case bool:
+		return "bool " + strconv.FormatBool(v)
+	case json.Number:
+		return "number " + string(v)
+	case float64:
+		return "number " + strconv.FormatFloat(v, 'g', -1, 64)
+	case []any:
+		return "an array"
+	case map[string]any:
+		return "an object"
+	}
+	return fmt.Sprintf("%T", v)
+}
@@ -0,0 +1,124 @@
+package spacetimedb_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/briheet/spacetime-goclient/spacetimedb"
+	"github.com/briheet/spacetime-goclient/spacetimedb/spacetimedbtest"
+)
+
+// addMove registers a reducer whose parameter is a module type, which the
+// schema refers to through a typespace Ref, and returns the arguments it
+// is called with.
+func addMove(f *fixture) *[]json.RawMessage {
+	var calls []json.RawMessage
+	f.db.AddType("Point", spacetimedbtest.Column{Name: "x", Type: "I32"}, spacetimedbtest.Column{Name: "y", Type: "I32"})
+	f.db.AddReducer("move", func(ctx *spacetimedbtest.ReducerContext, args json.RawMessage) error {
+		calls = append(calls, args)
+		return nil
+	}, spacetimedbtest.Column{Name: "to", Type: "Point"}, spacetimedbtest.Column{Name: "path", Type: "Vec<Point>"})
+	return &calls
+}
+
+func TestRefParams(t *testing.T) {
+	f := newFixture(t, spacetimedb.WithArgValidation())
+	calls := addMove(f)
+
+	schema, err := f.client.GetDatabaseSchema("chat")
+	must(t, err)
+	rd, ok := schema.Reducer("move")
+	if !ok {
+		t.Fatal("no reducer move")
+	}
+	equal(t, "signature", rd.Signature(), "move(to: (x: I32, y: I32), path: Vec<(x: I32, y: I32)>)")
+
+	args, err := rd.ParseArgs([]string{`{"x":1,"y":-2}`, `[[3,4]]`})
+	must(t, err)
+	got, _ := json.Marshal(args)
+	equal(t, "parsed", string(got), `[[1,-2],[[3,4]]]`)
+
+	must(t, f.client.CallReducer(context.Background(), "move", "chat", f.token, []any{
+		map[string]any{"x": 5, "y": 6},
+		[]any{},
+	}))
+	equal(t, "calls", len(*calls), 1)
+	equal(t, "sent", string((*calls)[0]), `[[5,6],[]]`)
+}
+
+// Recursive module types resolve to themselves, and must still print and
+// check in finite time.
+func TestRecursiveRefParam(t *testing.T) {
+	f := newFixture(t, spacetimedb.WithArgValidation())
+	f.db.AddType("Tree", spacetimedbtest.Column{Name: "children", Type: "Vec<Tree>"})
+	f.db.AddReducer("plant", func(*spacetimedbtest.ReducerContext, json.RawMessage) error { return nil },
+		spacetimedbtest.Column{Name: "tree", Type: "Tree"})
+
+	schema, err := f.client.GetDatabaseSchema("chat")
+	must(t, err)
+	rd, _ := schema.Reducer("plant")
+	equal(t, "signature", rd.Signature(), "plant(tree: (children: Vec<...>))")
+
+	args, err := rd.ParseArgs([]string{`{"children":[{"children":[]}]}`})
+	must(t, err)
+	got, _ := json.Marshal(args)
+	equal(t, "parsed", string(got), `[[[[[]]]]]`)
+}
+
+func TestBadRefParams(t *testing.T) {
+	f := newFixture(t, spacetimedb.WithArgValidation())
+	calls := addMove(f)
+	f.db.AddEnum("Shape",
+		spacetimedbtest.Column{Name: "Dot"},
+		spacetimedbtest.Column{Name: "Circle", Type: "U32"},
+		spacetimedbtest.Column{Name: "Line", Type: "Point"})
+	f.db.AddReducer("draw", func(*spacetimedbtest.ReducerContext, json.RawMessage) error { return nil },
+		spacetimedbtest.Column{Name: "shape", Type: "Shape"})
+
+	schema, err := f.client.GetDatabaseSchema("chat")
+	must(t, err)
+	draw, _ := schema.Reducer("draw")
+	args, err := draw.ParseArgs([]string{`{"Line":{"x":1,"y":2}}`})
+	must(t, err)
+	got, _ := json.Marshal(args)
+	equal(t, "parsed", string(got), `[{"Line":[1,2]}]`)
+
+	move, _ := schema.Reducer("move")
+	for _, tc := range []struct {
+		name string
+		rd   *spacetimedb.ReducerDef
+		args []any
+	}{
+		{"missing field", move, []any{map[string]any{"x": 1}, []any{}}},
+		{"unknown field", move, []any{map[string]any{"x": 1, "y": 2, "z": 3}, []any{}}},
+		{"wrong field type", move, []any{map[string]any{"x": "one", "y": 2}, []any{}}},
+		{"wrong arity", move, []any{[]any{1}, []any{}}},
+		{"bad element", move, []any{[]any{1, 2}, []any{[]any{1, 2, 3}}}},
+		{"unknown variant", draw, []any{"Square"}},
+		{"bad payload", draw, []any{map[string]any{"Circle": -1}}},
+		{"bad nested payload", draw, []any{map[string]any{"Line": map[string]any{"x": 1}}}},
+	} {
+		if err := f.client.CallReducer(context.Background(), tc.rd.Name, "chat", f.token, tc.args); err == nil {
+			t.Errorf("%s: CallReducer succeeded", tc.name)
+		}
+		var strs []string
+		for _, a := range tc.args {
+			b, _ := json.Marshal(a)
+			strs = append(strs, string(b))
+		}
+		if _, err := tc.rd.ParseArgs(strs); err == nil {
+			t.Errorf("%s: ParseArgs succeeded", tc.name)
+		}
+	}
+	equal(t, "calls", len(*calls), 0)
+}
+
+func TestUnresolvedRef(t *testing.T) {
+	ref := &spacetimedb.AlgebraicType{Kind: spacetimedb.KindRef, Ref: 3}
+	_, err := spacetimedb.ParseValue(ref, "1")
+	equal(t, "ParseValue", fmt.Sprint(err), "unresolved type &3")
+	_, err = spacetimedb.ParseValue(&spacetimedb.AlgebraicType{Kind: spacetimedb.KindArray, Elem: ref}, "[1]")
+	equal(t, "ParseValue array", fmt.Sprint(err), "element 0: unresolved type &3")
+}
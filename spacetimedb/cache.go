@@ -0,0 +1,307 @@
+package spacetimedb
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ErrSubscription wraps the error a SubscriptionError message reports.
+var ErrSubscription = errors.New("subscription failed")
+
+// ChangeKind is what happened to a cached row.
+type ChangeKind int
+
+const (
+	RowInsert ChangeKind = iota
+	RowDelete
+	RowUpdate
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case RowInsert:
+		return "insert"
+	case RowDelete:
+		return "delete"
+	case RowUpdate:
+		return "update"
+	}
+	return fmt.Sprintf("ChangeKind(%d)", int(k))
+}
+
+// RowChange is one row inserted, deleted or updated in a table. Old is nil
+// for inserts and New for deletes. A delete and an insert of rows with the
+// same primary key in one transaction are reported as a single update.
+type RowChange struct {
+	Table string
+	Kind  ChangeKind
+	Old   []any
+	New   []any
+}
+
+// CacheUpdate is the effect of one server message on a Cache.
+type CacheUpdate struct {
+	// Initial is set for the rows sent in reply to a subscription.
+	Initial bool
+	// Reducer names the reducer whose call caused a transaction, if any.
+	Reducer string
+	Changes []RowChange
+}
+
+// Cache is a client-side copy of the rows a subscription covers, kept
+// current by applying the messages of a JSON protocol websocket in the
+// order they arrive:
+//
+//	cache := spacetimedb.NewCache(schema)
+//	for msg := range conn.Messages() {
+//		update, err := cache.Apply(msg.Data)
+//		...
+//	}
+//
+// A Cache is safe for concurrent use.
+type Cache struct {
+	// OnRows, if set, is called with a table's row count whenever it
+	// changes, e.g. with metrics.Collector.CacheRows.
+	OnRows func(table string, rows int)
+
+	mu     sync.RWMutex
+	schema *ModuleSchema
+	tables map[string]*cachedTable
+}
+
+type cachedTable struct {
+	keys []int // primary key columns, none to key rows by their full value
+	rows map[string][]any
+}
+
+// NewCache returns an empty cache. The schema, which may be nil, lets
+// updates be recognised by primary key; without it every change is an
+// insert or a delete.
+func NewCache(schema *ModuleSchema) *Cache {
+	return &Cache{schema: schema, tables: make(map[string]*cachedTable)}
+}
+
+// Tables returns the names of the cached tables, sorted.
+func (c *Cache) Tables() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	names := make([]string, 0, len(c.tables))
+	for name := range c.tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Rows returns a table's cached rows ordered by key.
+func (c *Cache) Rows(table string) [][]any {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	t, ok := c.tables[table]
+	if !ok {
+		return nil
+	}
+	keys := make([]string, 0, len(t.rows))
+	for k := range t.rows {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	rows := make([][]any, len(keys))
+	for i, k := range keys {
+		rows[i] = t.rows[k]
+	}
+	return rows
+}
+
+// Len returns the number of rows cached for a table.
+func (c *Cache) Len(table string) int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if t, ok := c.tables[table]; ok {
+		return len(t.rows)
+	}
+	return 0
+}
+
+type tableUpdateMessage struct {
+	TableName string `json:"table_name"`
+	Updates   []struct {
+		Deletes []json.RawMessage `json:"deletes"`
+		Inserts []json.RawMessage `json:"inserts"`
+	} `json:"updates"`
+}
+
+type databaseUpdateMessage struct {
+	Tables []tableUpdateMessage `json:"tables"`
+}
+
+// Apply updates the cache from one server message and returns what
+// changed. Messages that do not touch the cache, such as IdentityToken or a
+// failed transaction, return a nil update. A SubscriptionError returns an
+// error wrapping ErrSubscription.
+func (c *Cache) Apply(msg []byte) (*CacheUpdate, error) {
+	var envelope struct {
+		InitialSubscription *struct {
+			DatabaseUpdate databaseUpdateMessage `json:"database_update"`
+		} `json:"InitialSubscription"`
+		TransactionUpdate *struct {
+			Status struct {
+				Committed *databaseUpdateMessage `json:"Committed"`
+			} `json:"status"`
+			ReducerCall struct {
+				ReducerName string `json:"reducer_name"`
+			} `json:"reducer_call"`
+		} `json:"TransactionUpdate"`
+		TransactionUpdateLight *struct {
+			Update databaseUpdateMessage `json:"update"`
+		} `json:"TransactionUpdateLight"`
+		SubscriptionError *struct {
+			Error string `json:"error"`
+		} `json:"SubscriptionError"`
+	}
+	if err := json.Unmarshal(msg, &envelope); err != nil {
+		return nil, fmt.Errorf("failed to decode message: %w", err)
+	}
+
+	switch {
+	case envelope.InitialSubscription != nil:
+		return c.apply(envelope.InitialSubscription.DatabaseUpdate, true, "")
+	case envelope.TransactionUpdate != nil:
+		tx := envelope.TransactionUpdate
+		if tx.Status.Committed == nil {
+			return nil, nil
+		}
+		return c.apply(*tx.Status.Committed, false, tx.ReducerCall.ReducerName)
+	case envelope.TransactionUpdateLight != nil:
+		return c.apply(envelope.TransactionUpdateLight.Update, false, "")
+	case envelope.SubscriptionError != nil:
+		return nil, fmt.Errorf("%w: %s", ErrSubscription, envelope.SubscriptionError.Error)
+	}
+	return nil, nil
+}
+
+func (c *Cache) apply(db databaseUpdateMessage, initial bool, reducer string) (*CacheUpdate, error) {
+	c.mu.Lock()
+	update := &CacheUpdate{Initial: initial, Reducer: reducer}
+	touched := make(map[string]int)
+
+	if initial {
+		// A new subscription replaces the previous one, rows and all.
+		for name, t := range c.tables {
+			for _, row := range t.rows {
+				update.Changes = append(update.Changes, RowChange{Table: name, Kind: RowDelete, Old: row})
+			}
+			touched[name] = 0
+		}
+		c.tables = make(map[string]*cachedTable)
+	}
+
+	for _, tu := range db.Tables {
+		t := c.table(tu.TableName)
+		for _, qu := range tu.Updates {
+			deleted := make(map[string][]any)
+			var order []string
+			for _, raw := range qu.Deletes {
+				row, err := decodeRow(raw)
+				if err != nil {
+					c.mu.Unlock()
+					return nil, fmt.Errorf("table %s: %w", tu.TableName, err)
+				}
+				key := t.key(row)
+				if _, ok := t.rows[key]; !ok {
+					continue
+				}
+				delete(t.rows, key)
+				deleted[key] = row
+				order = append(order, key)
+			}
+
+			for _, raw := range qu.Inserts {
+				row, err := decodeRow(raw)
+				if err != nil {
+					c.mu.Unlock()
+					return nil, fmt.Errorf("table %s: %w", tu.TableName, err)
+				}
+				key := t.key(row)
+				t.rows[key] = row
+				if old, ok := deleted[key]; ok && len(t.keys) > 0 {
+					update.Changes = append(update.Changes, RowChange{Table: tu.TableName, Kind: RowUpdate, Old: old, New: row})
+					delete(deleted, key)
+					continue
+				}
+				update.Changes = append(update.Changes, RowChange{Table: tu.TableName, Kind: RowInsert, New: row})
+			}
+
+			for _, key := range order {
+				if old, ok := deleted[key]; ok {
+					update.Changes = append(update.Changes, RowChange{Table: tu.TableName, Kind: RowDelete, Old: old})
+				}
+			}
+		}
+		touched[tu.TableName] = len(t.rows)
+	}
+	c.mu.Unlock()
+
+	if c.OnRows != nil {
+		for name, rows := range touched {
+			c.OnRows(name, rows)
+		}
+	}
+	return update, nil
+}
+
+// table returns the named table, creating it if needed. c.mu must be held.
+func (c *Cache) table(name string) *cachedTable {
+	if t, ok := c.tables[name]; ok {
+		return t
+	}
+	t := &cachedTable{rows: make(map[string][]any)}
+	if c.schema != nil {
+		if def, ok := c.schema.Table(name); ok {
+			t.keys = def.PrimaryKey
+		}
+	}
+	c.tables[name] = t
+	return t
+}
+
+// key identifies a row by its primary key columns, or by its whole value
+// when the table has none.
+func (t *cachedTable) key(row []any) string {
+	var values any = row
+	if len(t.keys) > 0 {
+		key := make([]any, 0, len(t.keys))
+		for _, i := range t.keys {
+			if i < len(row) {
+				key = append(key, row[i])
+			}
+		}
+		values = key
+	}
+	b, _ := json.Marshal(values)
+	return string(b)
+}
+
+// decodeRow decodes a row, which the JSON protocol sends either as a
+// product value or as a string holding one.
+func decodeRow(raw json.RawMessage) ([]any, error) {
+	var encoded string
+	if err := json.Unmarshal(raw, &encoded); err == nil {
+		raw = json.RawMessage(encoded)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var row []any
+	if err := dec.Decode(&row); err != nil {
+		return nil, fmt.Errorf("failed to decode row: %w", err)
+	}
+	return row, nil
+}
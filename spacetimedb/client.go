@@ -1,9 +1,14 @@
 package spacetimedb
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
+	neturl "net/url"
+	"strings"
 	"time"
 
+	"github.com/briheet/spacetime-goclient/metrics"
 	httpClient "github.com/briheet/spacetime-goclient/transport/http"
 	websocketsClient "github.com/briheet/spacetime-goclient/transport/websockets"
 )
@@ -17,9 +22,20 @@ type DBClient interface {
 
 	// Database Methods
 	Database
+	Names
+
+	// Log Methods
+	Logs
 
 	// Decoded Client
 	DecodedClient
+	Connections
+
+	// Energy Methods
+	Energy
+
+	// Module Schema
+	Schemas
 }
 
 var _ DBClient = (*Client)(nil)
@@ -33,50 +49,179 @@ type Client struct {
 	// Websockets for sub
 	WebsocketClient *websocketsClient.Client
 
-	// Identity and Token
+	// Identity and Token, used when a call is given an empty token
 	Identity string
 	Token    string
+
+	// Protocol is the default websocket subprotocol
+	Protocol string
+	// Logger is optional
+	Logger *slog.Logger
+	// Budget optionally guards the energy spent by reducer calls
+	Budget *EnergyBudget
+	// ValidateArgs checks reducer calls against the module schema
+	ValidateArgs bool
+
+	recorder metrics.Recorder
+	energy   *energyMeter
+	schemas  *schemaCache
+}
+
+// recordCall records the outcome of a reducer call made over a Connection.
+// Calls made over HTTP are recorded by the metrics middleware.
+func (c *Client) recordCall(call *pendingCall, outcome string) {
+	if c.recorder != nil {
+		c.recorder.ReducerCall(call.reducer, outcome, time.Since(call.start))
+	}
+}
+
+// bearer returns the Authorization header value for token, falling back to
+// the client's own token.
+func (c *Client) bearer(token string) string {
+	if token == "" {
+		token = c.Token
+	}
+	return fmt.Sprintf("Bearer %s", token)
+}
+
+// log returns the client's logger, or one that discards everything.
+func (c *Client) log() *slog.Logger {
+	if c.Logger == nil {
+		return slog.New(discardHandler{})
+	}
+	return c.Logger
 }
 
-func Connect(url string, port string, dbName string) (DBClient, error) {
-	httpBase := fmt.Sprintf("http://%s:%s", url, port)
-	wsBase := fmt.Sprintf("ws://%s:%s", url, port)
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (d discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return d }
+func (d discardHandler) WithGroup(string) slog.Handler           { return d }
 
-	httpClient, err := httpClient.NewClient(
+// Connect creates a client configured by opts. Without options it talks to
+// DefaultURL. Connect performs no network I/O; use Ping to check the server
+// is reachable.
+func Connect(ctx context.Context, opts ...Option) (DBClient, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	cfg := &config{url: DefaultURL, protocol: DefaultProtocol}
+	for _, opt := range opts {
+		if err := opt(cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	httpBase, wsBase, err := baseURLs(cfg.url)
+	if err != nil {
+		return nil, err
+	}
+
+	httpOpts := []httpClient.Option{
 		httpClient.WithBaseURL(httpBase),
-		httpClient.WithTimeout(3*time.Second),
-	)
+		httpClient.WithTimeout(DefaultHTTPTimeout),
+	}
+	httpOpts = append(httpOpts, cfg.httpOpts...)
+	wsOpts := []websocketsClient.Option{
+		websocketsClient.WithBaseURL(wsBase),
+	}
+	wsOpts = append(wsOpts, cfg.wsOpts...)
+
+	if cfg.tls != nil {
+		httpOpts = append(httpOpts, httpClient.WithTLSConfig(cfg.tls))
+		wsOpts = append(wsOpts, websocketsClient.WithTLSConfig(cfg.tls))
+	}
+	if cfg.logger != nil {
+		httpOpts = append(httpOpts, httpClient.WithMiddleware(httpClient.LogRequests(cfg.logger)))
+	}
+	if cfg.recorder != nil {
+		httpOpts = append(httpOpts, httpClient.WithMiddleware(metrics.HTTPMiddleware(cfg.recorder)))
+		wsOpts = append(wsOpts, websocketsClient.WithMessageHook(metrics.WebsocketHook(cfg.recorder)))
+	}
+
+	httpClient, err := httpClient.NewClient(httpOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create HTTP client: %w", err)
 	}
 
-	websocketClient, err := websocketsClient.NewClient(
-		websocketsClient.WithBaseURL(wsBase),
-	)
+	websocketClient, err := websocketsClient.NewClient(wsOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create WebSocket client: %w", err)
 	}
 
 	return &Client{
-		DBName:          dbName,
+		HttpBaseURL:     httpBase,
+		WssBaseURL:      wsBase,
+		DBName:          cfg.dbName,
 		HTTPClient:      httpClient,
 		WebsocketClient: websocketClient,
+		Identity:        cfg.identity,
+		Token:           cfg.token,
+		Protocol:        cfg.protocol,
+		Logger:          cfg.logger,
+		Budget:          cfg.budget,
+		ValidateArgs:    cfg.validate,
+		recorder:        cfg.recorder,
+		energy:          &energyMeter{},
+		schemas:         &schemaCache{},
 	}, nil
 }
 
-func (c *Client) Disconnect() error {
-	// Clean up the Http and Websocket conn
+// baseURLs derives the REST and websocket base URLs from the server URL.
+// https and wss select TLS for both transports; a bare "host:port" means
+// plain http.
+func baseURLs(rawURL string) (string, string, error) {
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "http://" + rawURL
+	}
+
+	u, err := neturl.Parse(rawURL)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid server URL: %w", err)
+	}
+	if u.Host == "" {
+		return "", "", fmt.Errorf("invalid server URL %q: missing host", rawURL)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/")
+	u.RawQuery, u.Fragment = "", ""
+
+	httpURL, wsURL := *u, *u
+	switch u.Scheme {
+	case "http", "ws":
+		httpURL.Scheme, wsURL.Scheme = "http", "ws"
+	case "https", "wss":
+		httpURL.Scheme, wsURL.Scheme = "https", "wss"
+	default:
+		return "", "", fmt.Errorf("unsupported URL scheme %q", u.Scheme)
+	}
+
+	return httpURL.String(), wsURL.String(), nil
+}
 
-	// if c.WebsocketClient != nil {
-	// 	if err := c.WebsocketClient.Close(); err != nil {
-	// 		return fmt.Errorf("failed to close websocket connection: %w", err)
-	// 	}
-	// }
+// Disconnect closes every Connection opened with OpenConnection and the
+// idle HTTP connections. Websockets returned by WebsocketSubscribe belong
+// to the caller. The client can still be used afterwards; new calls simply
+// open new connections.
+func (c *Client) Disconnect() error {
+	if c.WebsocketClient != nil {
+		if err := c.WebsocketClient.Close(); err != nil {
+			return fmt.Errorf("failed to close websocket connections: %w", err)
+		}
+	}
+	if c.HTTPClient != nil && c.HTTPClient.HTTPClient != nil {
+		c.HTTPClient.HTTPClient.CloseIdleConnections()
+	}
 	return nil
 }
 
 func (c *Client) Ping() error {
-	resp, err := c.HTTPClient.Do("GET", "/v1/ping", nil, nil)
+	return c.ping(context.Background())
+}
+
+func (c *Client) ping(ctx context.Context) error {
+	resp, err := c.HTTPClient.DoContext(ctx, "GET", pingPath(), nil, nil)
 	if err != nil {
 		return fmt.Errorf("ping failed: %w", err)
 	}
@@ -0,0 +1,638 @@
+package spacetimedb_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/briheet/spacetime-goclient/spacetimedb"
+	"github.com/briheet/spacetime-goclient/spacetimedb/spacetimedbtest"
+	httpClient "github.com/briheet/spacetime-goclient/transport/http"
+)
+
+// fixture is a fake server holding the database chat, owned by owner, with
+// a person table and the reducers add and fail, and a client for it.
+type fixture struct {
+	srv    *spacetimedbtest.Server
+	db     *spacetimedbtest.Database
+	client spacetimedb.DBClient
+
+	owner      string
+	token      string
+	otherToken string
+	wasm       string
+}
+
+func newFixture(t *testing.T, opts ...spacetimedb.Option) *fixture {
+	t.Helper()
+	f := &fixture{srv: spacetimedbtest.NewServer()}
+	t.Cleanup(f.srv.Close)
+
+	f.owner, f.token = f.srv.NewIdentity()
+	_, f.otherToken = f.srv.NewIdentity()
+	f.db = f.srv.AddDatabaseOwnedBy("chat", f.owner)
+	f.db.AddTable("person", spacetimedbtest.Column{Name: "name", Type: "String"}).Insert("alice")
+	f.db.AddReducer("add", func(ctx *spacetimedbtest.ReducerContext, args json.RawMessage) error {
+		var name string
+		if err := json.Unmarshal(args, &[]any{&name}); err != nil {
+			var named struct{ Text string }
+			if err := json.Unmarshal(args, &named); err != nil {
+				return err
+			}
+			name = named.Text
+		}
+		return ctx.Insert("person", name)
+	}, spacetimedbtest.Column{Name: "name", Type: "String"})
+	f.db.AddReducer("fail", func(ctx *spacetimedbtest.ReducerContext, args json.RawMessage) error {
+		return errors.New("boom")
+	})
+	f.db.SetEnergyUsed(5)
+
+	f.wasm = filepath.Join(t.TempDir(), "module.wasm")
+	if err := os.WriteFile(f.wasm, []byte("\x00asm"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	client, err := f.srv.Connect("chat", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Disconnect() })
+	f.client = client
+	return f
+}
+
+func (f *fixture) people(t *testing.T) []string {
+	t.Helper()
+	var names []string
+	for _, row := range f.db.Table("person").Rows() {
+		names = append(names, row[0].(string))
+	}
+	return names
+}
+
+func hash(program string) string {
+	sum := sha256.Sum256([]byte(program))
+	return hex.EncodeToString(sum[:])
+}
+
+func equal[T any](t *testing.T, what string, got, want T) {
+	t.Helper()
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("%s = %v, want %v", what, got, want)
+	}
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClientRoutes(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, f *fixture, c spacetimedb.DBClient)
+	}{
+		{"Ping", func(t *testing.T, f *fixture, c spacetimedb.DBClient) {
+			must(t, c.Ping())
+		}},
+		{"CreateIdentity", func(t *testing.T, f *fixture, c spacetimedb.DBClient) {
+			id, token, err := c.CreateIdentity()
+			must(t, err)
+			if len(id) != 64 || token == "" {
+				t.Fatalf("CreateIdentity = %q, %q", id, token)
+			}
+			must(t, c.VerifyIdentityToken(id, token))
+		}},
+		{"CreateIdentityWebsocketToken", func(t *testing.T, f *fixture, c spacetimedb.DBClient) {
+			token, err := c.CreateIdentityWebsocketToken()
+			must(t, err)
+			if token == "" {
+				t.Error("empty websocket token")
+			}
+		}},
+		{"GetPublicKey", func(t *testing.T, f *fixture, c spacetimedb.DBClient) {
+			key, err := c.GetPublicKey()
+			must(t, err)
+			equal(t, "public key", key, spacetimedbtest.PublicKey)
+		}},
+		{"RegisterIdentityWithEmail", func(t *testing.T, f *fixture, c spacetimedb.DBClient) {
+			id, _, err := c.RegisterIdentityWithEmail("a@example.com")
+			must(t, err)
+			equal(t, "email", f.srv.Email(id), "a@example.com")
+		}},
+		{"GetDatabasesByIdentity", func(t *testing.T, f *fixture, c spacetimedb.DBClient) {
+			ids, err := c.GetDatabasesByIdentity(f.owner)
+			must(t, err)
+			equal(t, "databases", ids, []string{f.db.Identity()})
+		}},
+		{"PublishDatabase", func(t *testing.T, f *fixture, c spacetimedb.DBClient) {
+			id, op, err := c.PublishDatabase(f.wasm, f.token)
+			must(t, err)
+			equal(t, "op", op, "created")
+			if f.srv.Database(id) == nil {
+				t.Errorf("database %s was not created", id)
+			}
+		}},
+		{"PublishNamedDatabase", func(t *testing.T, f *fixture, c spacetimedb.DBClient) {
+			id, op, domain, err := c.PublishNamedDatabase("app", f.wasm, f.token, false)
+			must(t, err)
+			equal(t, "op", op, "created")
+			equal(t, "domain", *domain, "app")
+			_, op, _, err = c.PublishNamedDatabase("app", f.wasm, f.token, true)
+			must(t, err)
+			equal(t, "op", op, "updated")
+			_, _, _, program, err := c.GetDatabaseInfo(id)
+			must(t, err)
+			equal(t, "program", program, hash("\x00asm"))
+		}},
+		{"GetDatabaseInfo", func(t *testing.T, f *fixture, c spacetimedb.DBClient) {
+			id, owner, host, program, err := c.GetDatabaseInfo("chat")
+			must(t, err)
+			equal(t, "info", []string{id, owner, host, program}, []string{f.db.Identity(), f.owner, "Wasm", hash("")})
+		}},
+		{"DeleteDatabase", func(t *testing.T, f *fixture, c spacetimedb.DBClient) {
+			must(t, c.DeleteDatabase("chat", f.token))
+			if f.srv.Database("chat") != nil {
+				t.Error("database still exists")
+			}
+		}},
+		{"GetDatabaseNames", func(t *testing.T, f *fixture, c spacetimedb.DBClient) {
+			names, err := c.GetDatabaseNames("chat")
+			must(t, err)
+			equal(t, "names", names, []string{"chat"})
+		}},
+		{"AddDatabaseName", func(t *testing.T, f *fixture, c spacetimedb.DBClient) {
+			must(t, c.AddDatabaseName("chat", "lobby", f.token))
+			equal(t, "names", f.db.Names(), []string{"chat", "lobby"})
+		}},
+		{"GetDatabaseIdentity", func(t *testing.T, f *fixture, c spacetimedb.DBClient) {
+			id, err := c.GetDatabaseIdentity("chat")
+			must(t, err)
+			equal(t, "identity", id, f.db.Identity())
+		}},
+		{"SetDatabaseNames", func(t *testing.T, f *fixture, c spacetimedb.DBClient) {
+			must(t, c.SetDatabaseNames("chat", []string{"a", "b"}, f.token))
+			equal(t, "names", f.db.Names(), []string{"a", "b"})
+		}},
+		{"ReplaceDatabaseName", func(t *testing.T, f *fixture, c spacetimedb.DBClient) {
+			must(t, c.ReplaceDatabaseName("chat", "chat", "lobby", f.token))
+			equal(t, "names", f.db.Names(), []string{"lobby"})
+		}},
+		{"RemoveDatabaseName", func(t *testing.T, f *fixture, c spacetimedb.DBClient) {
+			must(t, c.AddDatabaseName("chat", "lobby", f.token))
+			must(t, c.RemoveDatabaseName("chat", "chat", f.token))
+			equal(t, "names", f.db.Names(), []string{"lobby"})
+		}},
+		{"LookupDatabaseName", func(t *testing.T, f *fixture, c spacetimedb.DBClient) {
+			info, err := c.LookupDatabaseName("chat")
+			must(t, err)
+			equal(t, "info", *info, spacetimedb.NameInfo{Name: "chat", Database: f.db.Identity(), Owner: f.owner, Names: []string{"chat"}})
+		}},
+		{"GetDatabaseInventory", func(t *testing.T, f *fixture, c spacetimedb.DBClient) {
+			inventory, err := c.GetDatabaseInventory(f.owner)
+			must(t, err)
+			equal(t, "inventory", inventory, []spacetimedb.DatabaseNames{{Database: f.db.Identity(), Names: []string{"chat"}}})
+		}},
+		{"RunSQLQuery", func(t *testing.T, f *fixture, c spacetimedb.DBClient) {
+			res, err := c.RunSQLQuery("SELECT * FROM person", f.token, "chat")
+			must(t, err)
+			if len(res) != 1 {
+				t.Fatalf("got %d results, want 1", len(res))
+			}
+			equal(t, "rows", res[0].Rows, []any{[]any{"alice"}})
+			equal(t, "raw rows", string(res[0].RawRows), `[["alice"]]`)
+		}},
+		{"RunSQLQueryContext", func(t *testing.T, f *fixture, c spacetimedb.DBClient) {
+			res, err := c.RunSQLQueryContext(context.Background(), "SELECT name FROM person; SELECT * FROM person", f.token, "chat")
+			must(t, err)
+			equal(t, "results", len(res), 2)
+		}},
+		{"CallReducer", func(t *testing.T, f *fixture, c spacetimedb.DBClient) {
+			must(t, c.CallReducer(context.Background(), "add", "chat", f.token, []any{"bob"}))
+			equal(t, "people", f.people(t), []string{"alice", "bob"})
+		}},
+		{"CallReducerWithResult", func(t *testing.T, f *fixture, c spacetimedb.DBClient) {
+			res, err := c.CallReducerWithResult(context.Background(), "add", "chat", f.token, []any{"bob"})
+			must(t, err)
+			equal(t, "energy used", res.EnergyUsed, uint64(5))
+			equal(t, "energy spent", c.EnergySpent(), uint64(5))
+		}},
+		{"SendMessageDatabase", func(t *testing.T, f *fixture, c spacetimedb.DBClient) {
+			must(t, c.SendMessageDatabase("add", "chat", f.token, "carol"))
+			equal(t, "people", f.people(t), []string{"alice", "carol"})
+		}},
+		{"GetEnergyBalance", func(t *testing.T, f *fixture, c spacetimedb.DBClient) {
+			f.srv.SetEnergyBalance(f.owner, 1000)
+			balance, err := c.GetEnergyBalance(f.owner)
+			must(t, err)
+			equal(t, "balance", balance.String(), "1000")
+		}},
+		{"GetDatabaseSchema", func(t *testing.T, f *fixture, c spacetimedb.DBClient) {
+			schema, err := c.GetDatabaseSchema("chat")
+			must(t, err)
+			equal(t, "tables", len(schema.Tables), 1)
+			equal(t, "table", schema.Tables[0].Name, "person")
+			rd, ok := schema.Reducer("add")
+			if !ok {
+				t.Fatal("no reducer add")
+			}
+			equal(t, "signature", rd.Signature(), "add(name: String)")
+		}},
+		{"GetDatabaseLogs", func(t *testing.T, f *fixture, c spacetimedb.DBClient) {
+			f.db.Log(spacetimedb.LogLevelInfo, "hello")
+			body, err := c.GetDatabaseLogs("chat", f.token, 0, false)
+			must(t, err)
+			defer body.Close()
+			var rec spacetimedb.LogRecord
+			must(t, json.NewDecoder(body).Decode(&rec))
+			equal(t, "message", rec.Message, "hello")
+		}},
+		{"WebsocketSubscribe", func(t *testing.T, f *fixture, c spacetimedb.DBClient) {
+			conn, err := c.WebsocketSubscribe("chat", f.token, "")
+			must(t, err)
+			defer conn.Close()
+			_, data, err := c.ReadWebsocketMessage(conn)
+			must(t, err)
+			if !strings.Contains(string(data), `"IdentityToken"`) {
+				t.Errorf("first message = %s, want IdentityToken", data)
+			}
+			if c.WebsocketStats().Messages == 0 {
+				t.Error("websocket stats counted no message")
+			}
+		}},
+		{"Disconnect", func(t *testing.T, f *fixture, c spacetimedb.DBClient) {
+			must(t, c.Disconnect())
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			tt.run(t, f, f.client)
+		})
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		// method, path and status, if set, script a failure. {owner} in
+		// path stands for the database owner.
+		method, path string
+		status       int
+		run          func(f *fixture, c spacetimedb.DBClient) error
+		target       error
+		want         string
+	}{
+		{
+			name: "Ping 503", method: "GET", path: "/v1/ping", status: 503,
+			run:  func(f *fixture, c spacetimedb.DBClient) error { return c.Ping() },
+			want: "ping failed: status code 503",
+		},
+		{
+			name: "CreateIdentity 500", method: "POST", path: "/v1/identity", status: 500,
+			run:  func(f *fixture, c spacetimedb.DBClient) error { _, _, err := c.CreateIdentity(); return err },
+			want: "create identity failed: status code 500",
+		},
+		{
+			name: "GetPublicKey 404", method: "GET", path: "/v1/identity/public-key", status: 404,
+			run:  func(f *fixture, c spacetimedb.DBClient) error { _, err := c.GetPublicKey(); return err },
+			want: "status code 404",
+		},
+		{
+			name: "VerifyIdentityToken wrong identity",
+			run:  func(f *fixture, c spacetimedb.DBClient) error { return c.VerifyIdentityToken(f.owner, f.otherToken) },
+			want: "does not match identity (400)",
+		},
+		{
+			name: "VerifyIdentityToken unknown token",
+			run:  func(f *fixture, c spacetimedb.DBClient) error { return c.VerifyIdentityToken(f.owner, "forged") },
+			want: "invalid or missing token (401)",
+		},
+		{
+			name: "GetDatabasesByIdentity 500", method: "GET", path: "/v1/identity/{owner}/databases", status: 500,
+			run: func(f *fixture, c spacetimedb.DBClient) error {
+				_, err := c.GetDatabasesByIdentity(f.owner)
+				return err
+			},
+			want: "get databases failed: status code 500",
+		},
+		{
+			name: "GetDatabaseInfo unknown",
+			run: func(f *fixture, c spacetimedb.DBClient) error {
+				_, _, _, _, err := c.GetDatabaseInfo("nope")
+				return err
+			},
+			target: spacetimedb.ErrDatabaseNotFound,
+		},
+		{
+			name: "GetDatabaseInfo 500", method: "GET", path: "/v1/database/chat", status: 500,
+			run: func(f *fixture, c spacetimedb.DBClient) error {
+				_, _, _, _, err := c.GetDatabaseInfo("chat")
+				return err
+			},
+			want: "unexpected status code 500: scripted",
+		},
+		{
+			name: "PublishDatabase 500", method: "POST", path: "/v1/database", status: 500,
+			run: func(f *fixture, c spacetimedb.DBClient) error {
+				_, _, err := c.PublishDatabase(f.wasm, f.token)
+				return err
+			},
+			want: "unexpected status code 500: scripted",
+		},
+		{
+			name: "PublishDatabase without token",
+			run:  func(f *fixture, c spacetimedb.DBClient) error { _, _, err := c.PublishDatabase(f.wasm, ""); return err },
+			want: "unexpected status code 401",
+		},
+		{
+			name: "PublishNamedDatabase not owner",
+			run: func(f *fixture, c spacetimedb.DBClient) error {
+				_, _, _, err := c.PublishNamedDatabase("chat", f.wasm, f.otherToken, false)
+				return err
+			},
+			want: "permission denied to publish database: chat",
+		},
+		{
+			name: "PublishNamedDatabase 502", method: "POST", path: "/v1/database/chat", status: 502,
+			run: func(f *fixture, c spacetimedb.DBClient) error {
+				_, _, _, err := c.PublishNamedDatabase("chat", f.wasm, f.token, false)
+				return err
+			},
+			want: "unexpected status code 502",
+		},
+		{
+			name: "DeleteDatabase not owner",
+			run:  func(f *fixture, c spacetimedb.DBClient) error { return c.DeleteDatabase("chat", f.otherToken) },
+			want: "unexpected status code 401: identity does not own database",
+		},
+		{
+			name:   "GetDatabaseNames unknown",
+			run:    func(f *fixture, c spacetimedb.DBClient) error { _, err := c.GetDatabaseNames("nope"); return err },
+			target: spacetimedb.ErrDatabaseNotFound,
+		},
+		{
+			name: "GetDatabaseNames 503", method: "GET", path: "/v1/database/chat/names", status: 503,
+			run:  func(f *fixture, c spacetimedb.DBClient) error { _, err := c.GetDatabaseNames("chat"); return err },
+			want: "unexpected status code 503",
+		},
+		{
+			name: "AddDatabaseName not owner",
+			run:  func(f *fixture, c spacetimedb.DBClient) error { return c.AddDatabaseName("chat", "x", f.otherToken) },
+			want: "failed to set name, status 401",
+		},
+		{
+			name: "AddDatabaseName taken",
+			run: func(f *fixture, c spacetimedb.DBClient) error {
+				f.srv.AddDatabase("taken")
+				return c.AddDatabaseName("chat", "taken", f.token)
+			},
+			want: "permission denied for domain: taken",
+		},
+		{
+			name:   "SetDatabaseNames unknown",
+			run:    func(f *fixture, c spacetimedb.DBClient) error { return c.SetDatabaseNames("nope", nil, f.token) },
+			target: spacetimedb.ErrDatabaseNotFound,
+		},
+		{
+			name: "SetDatabaseNames taken",
+			run: func(f *fixture, c spacetimedb.DBClient) error {
+				f.srv.AddDatabase("taken")
+				return c.SetDatabaseNames("chat", []string{"taken"}, f.token)
+			},
+			want: "permission denied for domain: taken",
+		},
+		{
+			name: "SetDatabaseNames 500", method: "PUT", path: "/v1/database/chat/names", status: 500,
+			run:  func(f *fixture, c spacetimedb.DBClient) error { return c.SetDatabaseNames("chat", nil, f.token) },
+			want: "failed to set names, status 500",
+		},
+		{
+			name:   "RemoveDatabaseName missing",
+			run:    func(f *fixture, c spacetimedb.DBClient) error { return c.RemoveDatabaseName("chat", "x", f.token) },
+			target: spacetimedb.ErrNameNotFound,
+		},
+		{
+			name:   "LookupDatabaseName unknown",
+			run:    func(f *fixture, c spacetimedb.DBClient) error { _, err := c.LookupDatabaseName("nope"); return err },
+			target: spacetimedb.ErrDatabaseNotFound,
+		},
+		{
+			name:   "GetDatabaseIdentity unknown",
+			run:    func(f *fixture, c spacetimedb.DBClient) error { _, err := c.GetDatabaseIdentity("nope"); return err },
+			target: spacetimedb.ErrDatabaseNotFound,
+		},
+		{
+			name: "RunSQLQuery bad statement",
+			run: func(f *fixture, c spacetimedb.DBClient) error {
+				_, err := c.RunSQLQuery("DROP TABLE person", f.token, "chat")
+				return err
+			},
+			want: "SQL query failed: 400 Bad Request",
+		},
+		{
+			name: "RunSQLQuery without token",
+			run: func(f *fixture, c spacetimedb.DBClient) error {
+				_, err := c.RunSQLQuery("SELECT * FROM person", "", "chat")
+				return err
+			},
+			want: "SQL query failed: 401 Unauthorized",
+		},
+		{
+			name: "CallReducer unknown reducer",
+			run: func(f *fixture, c spacetimedb.DBClient) error {
+				return c.CallReducer(ctx, "nope", "chat", f.token, nil)
+			},
+			want: "unexpected status code 404",
+		},
+		{
+			name: "CallReducer failing reducer",
+			run: func(f *fixture, c spacetimedb.DBClient) error {
+				return c.CallReducer(ctx, "fail", "chat", f.token, nil)
+			},
+			want: "unexpected status code 530: boom",
+		},
+		{
+			name: "GetEnergyBalance 500", method: "GET", path: "/v1/energy/{owner}", status: 500,
+			run:  func(f *fixture, c spacetimedb.DBClient) error { _, err := c.GetEnergyBalance(f.owner); return err },
+			want: "unexpected status code 500: scripted",
+		},
+		{
+			name:   "GetDatabaseSchema unknown",
+			run:    func(f *fixture, c spacetimedb.DBClient) error { _, err := c.GetDatabaseSchema("nope"); return err },
+			target: spacetimedb.ErrDatabaseNotFound,
+		},
+		{
+			name: "GetDatabaseSchema 500", method: "GET", path: "/v1/database/chat/schema", status: 500,
+			run:  func(f *fixture, c spacetimedb.DBClient) error { _, err := c.GetDatabaseSchema("chat"); return err },
+			want: "unexpected status code 500",
+		},
+		{
+			name: "GetDatabaseLogs not owner",
+			run: func(f *fixture, c spacetimedb.DBClient) error {
+				_, err := c.GetDatabaseLogs("chat", f.otherToken, 0, false)
+				return err
+			},
+			want: "unexpected status code: 401 Unauthorized",
+		},
+		{
+			name: "StreamDatabaseLogs not owner",
+			run: func(f *fixture, c spacetimedb.DBClient) error {
+				_, _, err := c.StreamDatabaseLogs(ctx, "chat", f.otherToken, spacetimedb.LogStreamOptions{})
+				return err
+			},
+			want: "unexpected status code 401",
+		},
+		{
+			name: "WebsocketSubscribe unknown",
+			run: func(f *fixture, c spacetimedb.DBClient) error {
+				_, err := c.WebsocketSubscribe("nope", f.token, "")
+				return err
+			},
+			want: "WebSocket handshake failed: status 404",
+		},
+		{
+			name: "OpenConnection 503", method: "GET", path: "/v1/database/chat/subscribe", status: 503,
+			run: func(f *fixture, c spacetimedb.DBClient) error {
+				_, err := c.OpenConnection(ctx, "chat", f.token, "")
+				return err
+			},
+			want: "WebSocket handshake failed: status 503",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			if tt.status != 0 {
+				f.srv.Fail(tt.method, strings.ReplaceAll(tt.path, "{owner}", f.owner), tt.status, "scripted")
+			}
+			err := tt.run(f, f.client)
+			switch {
+			case err == nil:
+				t.Fatal("no error")
+			case tt.target != nil && !errors.Is(err, tt.target):
+				t.Errorf("error %v does not match %v", err, tt.target)
+			case !strings.Contains(err.Error(), tt.want):
+				t.Errorf("error %q, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// authRecorder records the Authorization header of every request by path.
+type authRecorder struct {
+	mu   sync.Mutex
+	auth map[string][]string
+}
+
+func (r *authRecorder) middleware(next http.RoundTripper) http.RoundTripper {
+	return httpClient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		r.mu.Lock()
+		r.auth[req.Method+" "+req.URL.Path] = append(r.auth[req.Method+" "+req.URL.Path], req.Header.Get("Authorization"))
+		r.mu.Unlock()
+		return next.RoundTrip(req)
+	})
+}
+
+func TestClientAuthHeaders(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name    string
+		request string
+		// run makes the request with token, which may be empty.
+		run func(f *fixture, c spacetimedb.DBClient, token string)
+		// anonymous routes send no Authorization header at all.
+		anonymous bool
+	}{
+		{"PublishDatabase", "POST /v1/database", func(f *fixture, c spacetimedb.DBClient, token string) {
+			c.PublishDatabase(f.wasm, token)
+		}, false},
+		{"PublishNamedDatabase", "POST /v1/database/chat", func(f *fixture, c spacetimedb.DBClient, token string) {
+			c.PublishNamedDatabase("chat", f.wasm, token, false)
+		}, false},
+		{"DeleteDatabase", "DELETE /v1/database/chat", func(f *fixture, c spacetimedb.DBClient, token string) {
+			c.DeleteDatabase("chat", token)
+		}, false},
+		{"AddDatabaseName", "POST /v1/database/chat/names", func(f *fixture, c spacetimedb.DBClient, token string) {
+			c.AddDatabaseName("chat", "lobby", token)
+		}, false},
+		{"SetDatabaseNames", "PUT /v1/database/chat/names", func(f *fixture, c spacetimedb.DBClient, token string) {
+			c.SetDatabaseNames("chat", []string{"chat"}, token)
+		}, false},
+		{"GetDatabaseLogs", "GET /v1/database/chat/logs", func(f *fixture, c spacetimedb.DBClient, token string) {
+			if body, err := c.GetDatabaseLogs("chat", token, 1, false); err == nil {
+				body.Close()
+			}
+		}, false},
+		{"StreamDatabaseLogs", "GET /v1/database/chat/logs", func(f *fixture, c spacetimedb.DBClient, token string) {
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			c.StreamDatabaseLogs(ctx, "chat", token, spacetimedb.LogStreamOptions{})
+		}, false},
+		{"RunSQLQuery", "POST /v1/database/chat/sql", func(f *fixture, c spacetimedb.DBClient, token string) {
+			c.RunSQLQuery("SELECT * FROM person", token, "chat")
+		}, false},
+		{"CallReducer", "POST /v1/database/chat/call/add", func(f *fixture, c spacetimedb.DBClient, token string) {
+			c.CallReducer(ctx, "add", "chat", token, []any{"bob"})
+		}, false},
+		{"VerifyIdentityToken", "", func(f *fixture, c spacetimedb.DBClient, token string) {
+			c.VerifyIdentityToken(f.owner, token)
+		}, false},
+		{"GetDatabaseInfo", "GET /v1/database/chat", func(f *fixture, c spacetimedb.DBClient, token string) {
+			c.GetDatabaseInfo("chat")
+		}, true},
+		{"GetDatabaseNames", "GET /v1/database/chat/names", func(f *fixture, c spacetimedb.DBClient, token string) {
+			c.GetDatabaseNames("chat")
+		}, true},
+		{"GetDatabaseSchema", "GET /v1/database/chat/schema", func(f *fixture, c spacetimedb.DBClient, token string) {
+			c.GetDatabaseSchema("chat")
+		}, true},
+		{"Ping", "GET /v1/ping", func(f *fixture, c spacetimedb.DBClient, token string) {
+			c.Ping()
+		}, true},
+	}
+	for _, tt := range tests {
+		for _, mode := range []string{"explicit token", "client token"} {
+			t.Run(tt.name+"/"+mode, func(t *testing.T) {
+				rec := &authRecorder{auth: map[string][]string{}}
+				f := newFixture(t,
+					spacetimedb.WithToken("client-token"),
+					spacetimedb.WithHTTPOptions(httpClient.WithMiddleware(rec.middleware)))
+
+				token, want := "call-token", "Bearer call-token"
+				if mode == "client token" {
+					token, want = "", "Bearer client-token"
+				}
+				if tt.anonymous {
+					want = ""
+				}
+				request := tt.request
+				if request == "" {
+					request = "GET /v1/identity/" + f.owner + "/verify"
+				}
+
+				tt.run(f, f.client, token)
+				rec.mu.Lock()
+				defer rec.mu.Unlock()
+				got := rec.auth[request]
+				if len(got) != 1 {
+					t.Fatalf("%s was sent %d times, want once (sent: %v)", request, len(got), rec.auth)
+				}
+				equal(t, "Authorization", got[0], want)
+			})
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package spacetimedb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/briheet/spacetime-goclient/tracing"
+	websocketsClient "github.com/briheet/spacetime-goclient/transport/websockets"
+	"github.com/gorilla/websocket"
+)
+
+type Connections interface {
+	OpenConnection(ctx context.Context, dbNameOrIden, token, protocol string) (*Connection, error)
+}
+
+// Connection is a subscription websocket that may be shared between
+// goroutines. Reads happen on a single goroutine and are delivered on
+// Messages; Subscribe, CallReducer and OneOffQuery go through a bounded
+// write queue, see websocketsClient.WithQueueSizes.
+//
+// The helpers encode messages for the JSON protocol. With any other
+// protocol, use Send directly.
+type Connection struct {
+	*websocketsClient.Conn
+
+	client    *Client
+	requestID atomic.Uint32
+	database  string
+
+	mu sync.Mutex
+	// self is the connection id the server assigned, from IdentityToken.
+	self    string
+	tracer  tracing.Tracer
+	pending map[uint32]*pendingCall
+}
+
+// pendingCall is a reducer call waiting for its TransactionUpdate. hold is
+// the energy reserved for it under a refusing budget.
+type pendingCall struct {
+	reducer string
+	hold    uint64
+	start   time.Time
+	span    tracing.Span
+}
+
+// OpenConnection connects to a database's subscribe endpoint like
+// WebsocketSubscribe, but returns a Connection that is safe for concurrent use.
+func (c *Client) OpenConnection(ctx context.Context, dbNameOrIden, token, protocol string) (*Connection, error) {
+	if protocol == "" {
+		protocol = c.Protocol
+	}
+	if protocol == "" {
+		protocol = DefaultProtocol
+	}
+
+	headers := http.Header{}
+	headers.Set("Sec-WebSocket-Protocol", protocol)
+	if token != "" || c.Token != "" {
+		headers.Set("Authorization", c.bearer(token))
+	}
+
+	conn := &Connection{client: c, database: dbNameOrIden, pending: map[uint32]*pendingCall{}}
+	ws, resp, err := c.WebsocketClient.Dial(ctx, databasePath(dbNameOrIden, "subscribe"), headers, websocketsClient.Observe(conn.received))
+	if err != nil {
+		if resp != nil {
+			return nil, fmt.Errorf("WebSocket handshake failed: status %s", resp.Status)
+		}
+		return nil, fmt.Errorf("WebSocket connection error: %w", err)
+	}
+	conn.Conn = ws
+	go func() {
+		<-ws.Done()
+		conn.abandon(ws.Err())
+	}()
+	return conn, nil
+}
+
+// trace starts a span for every reducer call made from now on.
+func (conn *Connection) trace(t tracing.Tracer) {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	conn.tracer = t
+}
+
+// track records a reducer call about to be sent, so its outcome can end
+// its span, be recorded and its cost be charged to the client.
+func (conn *Connection) track(ctx context.Context, id uint32, reducer string, hold uint64) {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	call := &pendingCall{reducer: reducer, hold: hold, start: time.Now()}
+	if conn.tracer != nil {
+		_, call.span = conn.tracer.Start(ctx, "spacetimedb.Connection.CallReducer",
+			tracing.String(tracing.AttrOperation, "Connection.CallReducer"),
+			tracing.String(tracing.AttrDatabase, conn.database),
+			tracing.String(tracing.AttrReducer, reducer),
+		)
+	}
+	conn.pending[id] = call
+}
+
+// finish ends the tracked call id with its outcome, "ok", "failed" or
+// "error" as for metrics.Recorder, and charges what it cost.
+func (conn *Connection) finish(id uint32, outcome string, err error, quanta uint64) {
+	conn.mu.Lock()
+	call, ok := conn.pending[id]
+	delete(conn.pending, id)
+	conn.mu.Unlock()
+	if ok {
+		call.end(err)
+		conn.client.recordCall(call, outcome)
+		conn.client.charge(call.reducer, call.hold, quanta)
+	}
+}
+
+func (call *pendingCall) end(err error) {
+	if call.span == nil {
+		return
+	}
+	if err != nil {
+		call.span.RecordError(err)
+		call.span.SetAttributes(tracing.String(tracing.AttrStatus, "error"))
+	} else {
+		call.span.SetAttributes(tracing.String(tracing.AttrStatus, "ok"))
+	}
+	call.span.End()
+}
+
+// abandon ends every call still waiting when the connection closes.
+func (conn *Connection) abandon(err error) {
+	if err == nil {
+		err = websocketsClient.ErrClosed
+	}
+	conn.mu.Lock()
+	pending := conn.pending
+	conn.pending = map[uint32]*pendingCall{}
+	conn.mu.Unlock()
+	for _, call := range pending {
+		call.end(fmt.Errorf("connection closed before the reducer call completed: %w", err))
+		conn.client.recordCall(call, "error")
+		conn.client.charge(call.reducer, call.hold, 0)
+	}
+}
+
+// received watches incoming messages for the connection's own id and the
+// TransactionUpdates answering its tracked calls.
+func (conn *Connection) received(msg websocketsClient.Message) {
+	conn.mu.Lock()
+	idle := conn.self != "" && len(conn.pending) == 0
+	conn.mu.Unlock()
+	if idle {
+		return
+	}
+
+	var envelope struct {
+		IdentityToken *struct {
+			ConnectionID json.RawMessage `json:"connection_id"`
+		} `json:"IdentityToken"`
+		TransactionUpdate *struct {
+			Status             map[string]json.RawMessage `json:"status"`
+			CallerConnectionID json.RawMessage            `json:"caller_connection_id"`
+			ReducerCall        struct {
+				RequestID uint32 `json:"request_id"`
+			} `json:"reducer_call"`
+			EnergyQuantaUsed struct {
+				Quanta json.Number `json:"quanta"`
+			} `json:"energy_quanta_used"`
+		} `json:"TransactionUpdate"`
+	}
+	if msg.Type != websocket.TextMessage || json.Unmarshal(msg.Data, &envelope) != nil {
+		return
+	}
+
+	switch {
+	case envelope.IdentityToken != nil:
+		conn.mu.Lock()
+		conn.self = compactJSON(envelope.IdentityToken.ConnectionID)
+		conn.mu.Unlock()
+	case envelope.TransactionUpdate != nil:
+		tx := envelope.TransactionUpdate
+		conn.mu.Lock()
+		mine := conn.self == "" || conn.self == compactJSON(tx.CallerConnectionID)
+		conn.mu.Unlock()
+		if !mine {
+			return
+		}
+		quanta, _ := strconv.ParseUint(tx.EnergyQuantaUsed.Quanta.String(), 10, 64)
+		err := statusError(tx.Status)
+		outcome := "ok"
+		if err != nil {
+			outcome = "failed"
+		}
+		conn.finish(tx.ReducerCall.RequestID, outcome, err, quanta)
+	}
+}
+
+// statusError turns a TransactionUpdate status into the call's outcome.
+func statusError(status map[string]json.RawMessage) error {
+	if _, ok := status["Committed"]; ok {
+		return nil
+	}
+	if reason, ok := status["Failed"]; ok {
+		var msg string
+		if json.Unmarshal(reason, &msg) == nil {
+			return fmt.Errorf("reducer failed: %s", msg)
+		}
+		return fmt.Errorf("reducer failed: %s", reason)
+	}
+	if _, ok := status["OutOfEnergy"]; ok {
+		return fmt.Errorf("reducer ran out of energy")
+	}
+	return fmt.Errorf("unknown transaction status")
+}
+
+func compactJSON(data json.RawMessage) string {
+	var b bytes.Buffer
+	if json.Compact(&b, data) != nil {
+		return string(data)
+	}
+	return b.String()
+}
+
+func (conn *Connection) nextRequestID() uint32 {
+	return conn.requestID.Add(1)
+}
+
+// Subscribe replaces the connection's subscription with queries and returns
+// the request id echoed in the InitialSubscription or SubscriptionError reply.
+func (conn *Connection) Subscribe(ctx context.Context, queries ...string) (uint32, error) {
+	id := conn.nextRequestID()
+	msg := map[string]any{"Subscribe": map[string]any{
+		"query_strings": queries,
+		"request_id":    id,
+	}}
+	if err := conn.SendJSON(ctx, msg); err != nil {
+		return 0, fmt.Errorf("failed to send subscription: %w", err)
+	}
+	return id, nil
+}
+
+// CallReducer calls a reducer over the connection and returns the request id
+// echoed in the caller's TransactionUpdate. The energy that update reports
+// is charged to the client's budget, which may refuse the call up front
+// like CallReducerWithResult, and WithArgValidation checks the arguments
+// first as it does there. On a connection opened through WithTracing the
+// call's span lasts until the update arrives, and with WithMetrics the
+// call is recorded once it does.
+func (conn *Connection) CallReducer(ctx context.Context, reducerName string, args any) (uint32, error) {
+	args, err := conn.client.checkArgs(ctx, reducerName, conn.database, args)
+	if err != nil {
+		return 0, err
+	}
+	encoded, err := json.Marshal(args)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode reducer args: %w", err)
+	}
+
+	hold, err := conn.client.reserve()
+	if err != nil {
+		return 0, err
+	}
+	id := conn.nextRequestID()
+	conn.track(ctx, id, reducerName, hold)
+	msg := map[string]any{"CallReducer": map[string]any{
+		"reducer":    reducerName,
+		"args":       string(encoded),
+		"request_id": id,
+		"flags":      0,
+	}}
+	if err := conn.SendJSON(ctx, msg); err != nil {
+		err = fmt.Errorf("failed to send reducer call: %w", err)
+		conn.finish(id, "error", err, 0)
+		return 0, err
+	}
+	return id, nil
+}
+
+// OneOffQuery runs a query outside the subscription and returns the message
+// id echoed in the OneOffQueryResponse.
+func (conn *Connection) OneOffQuery(ctx context.Context, query string) (string, error) {
+	id := strconv.FormatUint(uint64(conn.nextRequestID()), 10)
+	msg := map[string]any{"OneOffQuery": map[string]any{
+		"message_id":   id,
+		"query_string": query,
+	}}
+	if err := conn.SendJSON(ctx, msg); err != nil {
+		return "", fmt.Errorf("failed to send query: %w", err)
+	}
+	return id, nil
+}
@@ -0,0 +1,79 @@
+package spacetimedb_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/briheet/spacetime-goclient/spacetimedb"
+)
+
+// await returns the next message on conn that contains all of want.
+func await(t *testing.T, conn *spacetimedb.Connection, want ...string) string {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case msg, ok := <-conn.Messages():
+			if !ok {
+				t.Fatalf("connection ended waiting for %q: %v", want, conn.Err())
+			}
+			data := string(msg.Data)
+			match := true
+			for _, w := range want {
+				match = match && strings.Contains(data, w)
+			}
+			if match {
+				return data
+			}
+		case <-timeout:
+			t.Fatalf("no message with %q", want)
+		}
+	}
+}
+
+func TestConnection(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		// run acts on a connection subscribed to person and returns the
+		// substrings of the message it should cause.
+		run func(t *testing.T, f *fixture, conn *spacetimedb.Connection) []string
+	}{
+		{"reducer call over the connection", func(t *testing.T, f *fixture, conn *spacetimedb.Connection) []string {
+			_, err := conn.CallReducer(ctx, "add", []any{"bob"})
+			must(t, err)
+			return []string{`"TransactionUpdate"`, `"Committed"`, `bob`}
+		}},
+		{"failed reducer call", func(t *testing.T, f *fixture, conn *spacetimedb.Connection) []string {
+			_, err := conn.CallReducer(ctx, "fail", []any{})
+			must(t, err)
+			return []string{`"TransactionUpdate"`, `"Failed":"boom"`}
+		}},
+		{"reducer call over HTTP", func(t *testing.T, f *fixture, conn *spacetimedb.Connection) []string {
+			must(t, f.client.CallReducer(ctx, "add", "chat", f.otherToken, []any{"carol"}))
+			return []string{`"TransactionUpdate"`, `carol`}
+		}},
+		{"one-off query", func(t *testing.T, f *fixture, conn *spacetimedb.Connection) []string {
+			id, err := conn.OneOffQuery(ctx, "SELECT * FROM person")
+			must(t, err)
+			return []string{`"OneOffQueryResponse"`, `"message_id":"` + id + `"`, `alice`}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			conn, err := f.client.OpenConnection(ctx, "chat", f.token, "")
+			must(t, err)
+			defer conn.Close()
+
+			await(t, conn, `"IdentityToken"`)
+			_, err = conn.Subscribe(ctx, "SELECT * FROM person")
+			must(t, err)
+			await(t, conn, `"InitialSubscription"`, `alice`)
+
+			await(t, conn, tt.run(t, f, conn)...)
+		})
+	}
+}
@@ -2,7 +2,9 @@ package spacetimedb
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -10,9 +12,14 @@ import (
 	"os"
 	"strconv"
 
+	websocketsClient "github.com/briheet/spacetime-goclient/transport/websockets"
 	"github.com/gorilla/websocket"
 )
 
+// ErrDatabaseNotFound is wrapped by lookups of a database name or identity
+// the server does not know.
+var ErrDatabaseNotFound = errors.New("database not found")
+
 type Database interface {
 	PublishDatabase(wasmFile string, token string) (string, string, error)
 	PublishNamedDatabase(nameOrIdentity string, wasmFile string, token string, clear bool) (string, string, *string, error)
@@ -22,27 +29,38 @@ type Database interface {
 	AddDatabaseName(nameOrIdentity, newName, token string) error
 	GetDatabaseIdentity(nameOrIdentity string) (string, error)
 	WebsocketSubscribe(dbNameOrIden, token, protocol string) (*websocket.Conn, error)
+	ReadWebsocketMessage(conn *websocket.Conn) (int, []byte, error)
+	WebsocketStats() websocketsClient.Stats
 	GetDatabaseLogs(dbNameOrIden string, token string, numLines int, follow bool) (io.ReadCloser, error)
 	RunSQLQuery(query, token, dbName string) ([]SQLResult, error)
+	RunSQLQueryContext(ctx context.Context, query, token, dbName string) ([]SQLResult, error)
 }
 
 func (c *Client) PublishDatabase(wasmFile string, token string) (string, string, error) {
+	return c.publishDatabase(context.Background(), wasmFile, token)
+}
+
+func (c *Client) publishDatabase(ctx context.Context, wasmFile string, token string) (string, string, error) {
 	// Need to read the file
 	data, err := os.ReadFile(wasmFile)
 	if err != nil {
 		return "", "", fmt.Errorf("reading wasm: %w", err)
 	}
 
-	url := fmt.Sprintf("%s/v1/database", c.HttpBaseURL)
-
 	headers := map[string]string{
-		"Authorization": fmt.Sprintf("Bearer %s", token),
+		"Authorization": c.bearer(token),
 	}
 
-	resp, err := c.HTTPClient.Do("POST", url, headers, bytes.NewReader(data))
+	resp, err := c.HTTPClient.DoContext(ctx, "POST", databasePath(), headers, bytes.NewReader(data))
 	if err != nil {
 		return "", "", fmt.Errorf("failed to publish data: %w", err)
 	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", "", fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(body))
+	}
 
 	var result struct {
 		Success struct {
@@ -55,10 +73,15 @@ func (c *Client) PublishDatabase(wasmFile string, token string) (string, string,
 		return "", "", fmt.Errorf("failed to parse publish response: %w", err)
 	}
 
+	c.schemas.reset()
 	return result.Success.DatabaseIdentity, result.Success.Op, nil
 }
 
 func (c *Client) PublishNamedDatabase(nameOrIdentity string, wasmFile string, token string, clear bool) (string, string, *string, error) {
+	return c.publishNamedDatabase(context.Background(), nameOrIdentity, wasmFile, token, clear)
+}
+
+func (c *Client) publishNamedDatabase(ctx context.Context, nameOrIdentity string, wasmFile string, token string, clear bool) (string, string, *string, error) {
 	// Read the Wasm binary
 	data, err := os.ReadFile(wasmFile)
 	if err != nil {
@@ -70,18 +93,15 @@ func (c *Client) PublishNamedDatabase(nameOrIdentity string, wasmFile string, to
 		query.Set("clear", "true")
 	}
 
-	fullPath := fmt.Sprintf("/v1/database/%s", nameOrIdentity)
-	if q := query.Encode(); q != "" {
-		fullPath += "?" + q
-	}
+	fullPath := withQuery(databasePath(nameOrIdentity), query)
 
 	// Set headers
 	headers := map[string]string{
-		"Authorization": fmt.Sprintf("Bearer %s", token),
+		"Authorization": c.bearer(token),
 	}
 
 	// Make the request
-	resp, err := c.HTTPClient.Do("POST", fullPath, headers, bytes.NewReader(data))
+	resp, err := c.HTTPClient.DoContext(ctx, "POST", fullPath, headers, bytes.NewReader(data))
 	if err != nil {
 		return "", "", nil, fmt.Errorf("failed to publish named database: %w", err)
 	}
@@ -117,19 +137,27 @@ func (c *Client) PublishNamedDatabase(nameOrIdentity string, wasmFile string, to
 	if err := json.Unmarshal(body, &result); err != nil {
 		return "", "", nil, fmt.Errorf("failed to parse publish response: %w", err)
 	}
+	c.schemas.reset()
 
 	return result.Success.DatabaseIdentity, result.Success.Op, result.Success.Domain, nil
 }
 
 func (c *Client) GetDatabaseInfo(nameOrIdentity string) (string, string, string, string, error) {
-	endpoint := fmt.Sprintf("/v1/database/%s", url.PathEscape(nameOrIdentity))
+	return c.getDatabaseInfo(context.Background(), nameOrIdentity)
+}
+
+func (c *Client) getDatabaseInfo(ctx context.Context, nameOrIdentity string) (string, string, string, string, error) {
+	endpoint := databasePath(nameOrIdentity)
 
-	resp, err := c.HTTPClient.Do("GET", endpoint, nil, nil)
+	resp, err := c.HTTPClient.DoContext(ctx, "GET", endpoint, nil, nil)
 	if err != nil {
 		return "", "", "", "", fmt.Errorf("failed to get database info: %w", err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotFound {
+		return "", "", "", "", fmt.Errorf("%w: %s", ErrDatabaseNotFound, nameOrIdentity)
+	}
 	if resp.StatusCode != 200 {
 		body, _ := io.ReadAll(resp.Body)
 		return "", "", "", "", fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(body))
@@ -161,16 +189,20 @@ func (c *Client) GetDatabaseInfo(nameOrIdentity string) (string, string, string,
 }
 
 func (c *Client) DeleteDatabase(nameOrIdentity string, token string) error {
+	return c.deleteDatabase(context.Background(), nameOrIdentity, token)
+}
+
+func (c *Client) deleteDatabase(ctx context.Context, nameOrIdentity string, token string) error {
 
-	endpoint := fmt.Sprintf("/v1/database/%s", url.PathEscape(nameOrIdentity))
+	endpoint := databasePath(nameOrIdentity)
 
 	// Build up the header
 	headers := map[string]string{
 		"Content-Type":  "application/json",
-		"Authorization": fmt.Sprintf("Bearer %s", token),
+		"Authorization": c.bearer(token),
 	}
 
-	resp, err := c.HTTPClient.Do("DELETE", endpoint, headers, nil)
+	resp, err := c.HTTPClient.DoContext(ctx, "DELETE", endpoint, headers, nil)
 	if err != nil {
 		return err
 	}
@@ -189,14 +221,21 @@ func (c *Client) DeleteDatabase(nameOrIdentity string, token string) error {
 }
 
 func (c *Client) GetDatabaseNames(nameOrIdentity string) ([]string, error) {
-	endpoint := fmt.Sprintf("/v1/database/%s/names", url.PathEscape(nameOrIdentity))
+	return c.getDatabaseNames(context.Background(), nameOrIdentity)
+}
+
+func (c *Client) getDatabaseNames(ctx context.Context, nameOrIdentity string) ([]string, error) {
+	endpoint := databasePath(nameOrIdentity, "names")
 
-	resp, err := c.HTTPClient.Do("GET", endpoint, nil, nil)
+	resp, err := c.HTTPClient.DoContext(ctx, "GET", endpoint, nil, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to make request: %w", err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s", ErrDatabaseNotFound, nameOrIdentity)
+	}
 	if resp.StatusCode != 200 {
 		body, _ := io.ReadAll(resp.Body)
 		return nil, fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(body))
@@ -214,11 +253,15 @@ func (c *Client) GetDatabaseNames(nameOrIdentity string) ([]string, error) {
 }
 
 func (c *Client) AddDatabaseName(nameOrIdentity, newName, token string) error {
-	endpoint := fmt.Sprintf("/v1/database/%s/names", url.PathEscape(nameOrIdentity))
+	return c.addDatabaseName(context.Background(), nameOrIdentity, newName, token)
+}
+
+func (c *Client) addDatabaseName(ctx context.Context, nameOrIdentity, newName, token string) error {
+	endpoint := databasePath(nameOrIdentity, "names")
 
 	headers := map[string]string{
 		"Content-Type":  "application/json",
-		"Authorization": fmt.Sprintf("Bearer %s", token),
+		"Authorization": c.bearer(token),
 	}
 
 	body, err := json.Marshal(newName)
@@ -226,7 +269,7 @@ func (c *Client) AddDatabaseName(nameOrIdentity, newName, token string) error {
 		return fmt.Errorf("failed to marshal name: %w", err)
 	}
 
-	resp, err := c.HTTPClient.Do("POST", endpoint, headers, bytes.NewReader(body))
+	resp, err := c.HTTPClient.DoContext(ctx, "POST", endpoint, headers, bytes.NewReader(body))
 	if err != nil {
 		return fmt.Errorf("request failed: %w", err)
 	}
@@ -259,14 +302,21 @@ func (c *Client) AddDatabaseName(nameOrIdentity, newName, token string) error {
 }
 
 func (c *Client) GetDatabaseIdentity(nameOrIdentity string) (string, error) {
-	endpoint := fmt.Sprintf("/v1/database/%s/identity", url.PathEscape(nameOrIdentity))
+	return c.getDatabaseIdentity(context.Background(), nameOrIdentity)
+}
+
+func (c *Client) getDatabaseIdentity(ctx context.Context, nameOrIdentity string) (string, error) {
+	endpoint := databasePath(nameOrIdentity, "identity")
 
-	resp, err := c.HTTPClient.Do("GET", endpoint, nil, nil)
+	resp, err := c.HTTPClient.DoContext(ctx, "GET", endpoint, nil, nil)
 	if err != nil {
 		return "", fmt.Errorf("failed to get database identity: %w", err)
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotFound {
+		return "", fmt.Errorf("%w: %s", ErrDatabaseNotFound, nameOrIdentity)
+	}
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		return "", fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(body))
@@ -281,10 +331,16 @@ func (c *Client) GetDatabaseIdentity(nameOrIdentity string) (string, error) {
 	return string(body), nil
 }
 
+// DefaultProtocol is the websocket subprotocol used when none is configured.
+const DefaultProtocol = "v1.json.spacetimedb"
+
 func (c *Client) WebsocketSubscribe(dbNameOrIden, token, protocol string) (*websocket.Conn, error) {
 
 	if protocol == "" {
-		protocol = "v1.json.spacetimedb" // default to JSON protocol
+		protocol = c.Protocol
+	}
+	if protocol == "" {
+		protocol = DefaultProtocol // default to JSON protocol
 	}
 
 	// Prepare headers
@@ -292,14 +348,13 @@ func (c *Client) WebsocketSubscribe(dbNameOrIden, token, protocol string) (*webs
 	headers.Set("Sec-WebSocket-Protocol", protocol)
 	// headers.Set("Sec-WebSocket-Version", "13")
 
-	if token != "" {
-		headers.Set("Authorization", "Bearer "+token)
+	if token != "" || c.Token != "" {
+		headers.Set("Authorization", c.bearer(token))
 	}
 
-	endpoint := fmt.Sprintf("/v1/database/%s/subscribe", dbNameOrIden)
-	fullURL := c.WssBaseURL + endpoint
+	endpoint := databasePath(dbNameOrIden, "subscribe")
 
-	conn, resp, err := c.WebsocketClient.Connect(fullURL, headers)
+	conn, resp, err := c.WebsocketClient.Connect(endpoint, headers)
 	if err != nil {
 		if resp != nil {
 			return nil, fmt.Errorf("WebSocket handshake failed: status %s", resp.Status)
@@ -311,9 +366,25 @@ func (c *Client) WebsocketSubscribe(dbNameOrIden, token, protocol string) (*webs
 
 }
 
+// ReadWebsocketMessage reads the next message from a subscription and
+// transparently decompresses it.
+func (c *Client) ReadWebsocketMessage(conn *websocket.Conn) (int, []byte, error) {
+	return c.WebsocketClient.ReadMessage(conn)
+}
+
+// WebsocketStats reports websocket bytes on the wire versus decoded.
+func (c *Client) WebsocketStats() websocketsClient.Stats {
+	return c.WebsocketClient.Stats()
+}
+
+// GetDatabaseLogs returns the raw log body, one JSON record per line. The
+// request is not subject to the client timeout, so a followed body stays
+// open until it is closed.
 func (c *Client) GetDatabaseLogs(dbNameOrIden string, token string, numLines int, follow bool) (io.ReadCloser, error) {
-	endpoint := fmt.Sprintf("%s/v1/database/%s/logs", c.HttpBaseURL, dbNameOrIden)
+	return c.getDatabaseLogs(context.Background(), dbNameOrIden, token, numLines, follow)
+}
 
+func (c *Client) getDatabaseLogs(ctx context.Context, dbNameOrIden string, token string, numLines int, follow bool) (io.ReadCloser, error) {
 	// Build query params
 	params := url.Values{}
 	if numLines > 0 {
@@ -322,15 +393,13 @@ func (c *Client) GetDatabaseLogs(dbNameOrIden string, token string, numLines int
 	if follow {
 		params.Set("follow", "true")
 	}
-	if len(params) > 0 {
-		endpoint += "?" + params.Encode()
-	}
+	endpoint := withQuery(databasePath(dbNameOrIden, "logs"), params)
 
 	headers := map[string]string{
-		"Authorization": fmt.Sprintf("Bearer %s", token),
+		"Authorization": c.bearer(token),
 	}
 
-	resp, err := c.HTTPClient.Do("GET", endpoint, headers, nil)
+	resp, err := c.HTTPClient.DoStream(ctx, "GET", endpoint, headers, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to request logs: %w", err)
 	}
@@ -344,25 +413,51 @@ func (c *Client) GetDatabaseLogs(dbNameOrIden string, token string, numLines int
 	return resp.Body, nil
 }
 
+// SQLResult is one statement's result. Rows are decoded the way
+// encoding/json decodes any value, so numbers are float64. RawRows keeps the
+// rows as the server sent them, for callers that need 64- and 128-bit
+// integers exactly; format.SQLRows decodes them that way.
 type SQLResult struct {
-	Schema any           `json:"schema"`
-	Rows   []interface{} `json:"rows"`
+	Schema  any             `json:"schema"`
+	Rows    []interface{}   `json:"rows"`
+	RawRows json.RawMessage `json:"-"`
+}
+
+func (r *SQLResult) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Schema any             `json:"schema"`
+		Rows   json.RawMessage `json:"rows"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*r = SQLResult{Schema: raw.Schema, RawRows: raw.Rows}
+	if len(raw.Rows) == 0 {
+		return nil
+	}
+	return json.Unmarshal(raw.Rows, &r.Rows)
 }
 
 func (c *Client) RunSQLQuery(query, token, dbName string) ([]SQLResult, error) {
+	return c.RunSQLQueryContext(context.Background(), query, token, dbName)
+}
+
+// RunSQLQueryContext is RunSQLQuery with a context, so a long query can be
+// abandoned.
+func (c *Client) RunSQLQueryContext(ctx context.Context, query, token, dbName string) ([]SQLResult, error) {
 	if query == "" {
 		return nil, fmt.Errorf("query cannot be empty")
 	}
 
-	endpoint := fmt.Sprintf("/v1/database/%s/sql", dbName)
+	endpoint := databasePath(dbName, "sql")
 	body := bytes.NewBufferString(query)
 
 	headers := map[string]string{
 		"Content-Type":  "text/plain", // Spacetime expects raw SQL
-		"Authorization": fmt.Sprintf("Bearer %s", token),
+		"Authorization": c.bearer(token),
 	}
 
-	resp, err := c.HTTPClient.Do("POST", endpoint, headers, body)
+	resp, err := c.HTTPClient.DoContext(ctx, "POST", endpoint, headers, body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to run SQL query: %w", err)
 	}
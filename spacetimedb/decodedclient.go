@@ -1,50 +1,40 @@
 package spacetimedb
 
 import (
-	"bytes"
-	"encoding/json"
-	"fmt"
-	"io"
+	"context"
+
+	httpClient "github.com/briheet/spacetime-goclient/transport/http"
 )
 
 type DecodedClient interface {
 	SendMessageDatabase(string, string, string, string) error
+	CallReducer(ctx context.Context, reducerName, dbID, token string, args any) error
+}
+
+// Idempotent marks calls made with ctx as safe to retry, so a client
+// configured with a retry policy will retry them on gateway errors even
+// though reducer calls are POST requests.
+func Idempotent(ctx context.Context) context.Context {
+	return httpClient.WithIdempotent(ctx)
 }
 
 func (c *Client) SendMessageDatabase(reducerName string, dbID string, token string, text string) error {
+	return c.sendMessageDatabase(context.Background(), reducerName, dbID, token, text)
+}
+
+func (c *Client) sendMessageDatabase(ctx context.Context, reducerName string, dbID string, token string, text string) error {
 
 	// Setup the data that needs to be sent
 	payload := map[string]interface{}{
 		"text": text,
 	}
-	jsonData, err := json.Marshal(payload)
-	if err != nil {
-		return err
-	}
-
-	// Build up the header
-	headers := map[string]string{
-		"Content-Type":  "application/json",
-		"Authorization": fmt.Sprintf("Bearer %s", token),
-	}
 
-	// Buildup the url
-	url := fmt.Sprintf("/v1/database/%s/call/%s", dbID, reducerName)
-	resp, err := c.HTTPClient.Do("POST", url, headers, bytes.NewReader(jsonData))
-	if err != nil {
-		return err
-	}
-
-	defer resp.Body.Close()
-
-	// Read response body (always)
-	bodyBytes, _ := io.ReadAll(resp.Body)
-	bodyText := string(bodyBytes)
-
-	if resp.StatusCode != 200 {
-		return fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, bodyText)
-	}
-
-	return nil
+	return c.CallReducer(ctx, reducerName, dbID, token, payload)
+}
 
+// CallReducer invokes a reducer with args encoded as JSON, usually a slice
+// of positional arguments or a map of named ones.
+func (c *Client) CallReducer(ctx context.Context, reducerName, dbID, token string, args any) error {
+	_, err := c.CallReducerWithResult(ctx, reducerName, dbID, token, args)
+	return err
 }
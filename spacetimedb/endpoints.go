@@ -0,0 +1,47 @@
+package spacetimedb
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Endpoint resolution. Every REST and websocket route is built here so path
+// segments are escaped the same way everywhere, and the transports only ever
+// receive paths relative to their base URL.
+
+// route joins segments under /v1, escaping each one.
+func route(segments ...string) string {
+	var sb strings.Builder
+	sb.WriteString("/v1")
+	for _, seg := range segments {
+		sb.WriteByte('/')
+		sb.WriteString(url.PathEscape(seg))
+	}
+	return sb.String()
+}
+
+// withQuery appends the encoded query to path, if there is one.
+func withQuery(path string, query url.Values) string {
+	if q := query.Encode(); q != "" {
+		return path + "?" + q
+	}
+	return path
+}
+
+func pingPath() string { return route("ping") }
+
+func identityPath(segments ...string) string {
+	return route(append([]string{"identity"}, segments...)...)
+}
+
+func energyPath(identity string) string { return route("energy", identity) }
+
+func databasePath(segments ...string) string {
+	return route(append([]string{"database"}, segments...)...)
+}
+
+// HTTPURL returns the absolute REST URL of path.
+func (c *Client) HTTPURL(path string) string { return c.HttpBaseURL + path }
+
+// WebsocketURL returns the absolute websocket URL of path.
+func (c *Client) WebsocketURL(path string) string { return c.WssBaseURL + path }
@@ -0,0 +1,247 @@
+package spacetimedb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type Energy interface {
+	GetEnergyBalance(identity string) (*big.Int, error)
+	CallReducerWithResult(ctx context.Context, reducerName, dbID, token string, args any) (ReducerResult, error)
+	EnergySpent() uint64
+}
+
+// ErrEnergyBudgetExceeded is returned by reducer calls once a client with a
+// refusing EnergyBudget has spent its limit.
+var ErrEnergyBudgetExceeded = errors.New("energy budget exceeded")
+
+// ErrEnergyBudgetWarning is reported once spending reaches an
+// EnergyBudget's WarnAt.
+var ErrEnergyBudgetWarning = errors.New("energy budget warning threshold reached")
+
+// Response headers carrying the cost of a reducer call.
+const (
+	energyUsedHeader        = "Spacetime-Energy-Used"
+	executionDurationHeader = "Spacetime-Execution-Duration-Micros"
+)
+
+// ReducerResult is what the server reports about a reducer call.
+type ReducerResult struct {
+	// EnergyUsed is the energy in quanta charged for the call.
+	EnergyUsed uint64
+	// Duration is the host execution time.
+	Duration time.Duration
+	// Warning is set when this call took spending past the budget's WarnAt
+	// or Limit. It wraps ErrEnergyBudgetWarning or ErrEnergyBudgetExceeded.
+	Warning error
+}
+
+// EnergyBudget caps the energy reducer calls made through one client may
+// spend, as reported in their results.
+type EnergyBudget struct {
+	// Limit is the energy the client may spend. Zero means no limit.
+	Limit uint64
+	// WarnAt logs a warning once spending reaches it. Zero disables it.
+	WarnAt uint64
+	// Refuse fails calls with ErrEnergyBudgetExceeded once Limit is reached.
+	// Otherwise crossing the limit is only reported.
+	Refuse bool
+	// OnWarning, if set, is called with the same error as ReducerResult's
+	// Warning each time a threshold is crossed, including by calls made over
+	// a Connection. It may run on a connection's read goroutine, so it must
+	// not block.
+	OnWarning func(error)
+}
+
+// energyMeter tracks the energy spent against the client's budget. Calls in
+// flight under a refusing budget hold a reservation, so concurrent calls
+// cannot all start just below the limit.
+type energyMeter struct {
+	mu       sync.Mutex
+	spent    uint64
+	reserved uint64
+	// largest is the most any single call has cost, used as the size of a
+	// reservation since a call's cost is only known once it returns.
+	largest uint64
+	warned  bool
+	over    bool
+}
+
+// GetEnergyBalance returns the energy balance of identity in quanta. It may
+// be negative once an identity has overspent.
+func (c *Client) GetEnergyBalance(identity string) (*big.Int, error) {
+	return c.getEnergyBalance(context.Background(), identity)
+}
+
+func (c *Client) getEnergyBalance(ctx context.Context, identity string) (*big.Int, error) {
+	resp, err := c.HTTPClient.DoContext(ctx, "GET", energyPath(identity), nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get energy balance: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(body))
+	}
+
+	// The balance is an i128, sent as a string or a plain number.
+	var result struct {
+		Balance json.RawMessage `json:"balance"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	balance, ok := new(big.Int).SetString(string(bytes.Trim(result.Balance, `"`)), 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid energy balance %s", result.Balance)
+	}
+	return balance, nil
+}
+
+// EnergySpent returns the energy spent by reducer calls made through this
+// client so far, over HTTP and over its Connections.
+func (c *Client) EnergySpent() uint64 {
+	c.energy.mu.Lock()
+	defer c.energy.mu.Unlock()
+	return c.energy.spent
+}
+
+// CallReducerWithResult calls a reducer like CallReducer and returns the
+// energy and time it took. Failed calls are charged too, so their result is
+// returned along with the error.
+func (c *Client) CallReducerWithResult(ctx context.Context, reducerName, dbID, token string, args any) (ReducerResult, error) {
+	hold, err := c.reserve()
+	if err != nil {
+		return ReducerResult{}, err
+	}
+	result, err := c.callReducer(ctx, reducerName, dbID, token, args)
+	result.Warning = c.charge(reducerName, hold, result.EnergyUsed)
+	return result, err
+}
+
+func (c *Client) callReducer(ctx context.Context, reducerName, dbID, token string, args any) (ReducerResult, error) {
+	args, err := c.checkArgs(ctx, reducerName, dbID, args)
+	if err != nil {
+		return ReducerResult{}, err
+	}
+
+	jsonData, err := json.Marshal(args)
+	if err != nil {
+		return ReducerResult{}, err
+	}
+
+	headers := map[string]string{
+		"Content-Type":  "application/json",
+		"Authorization": c.bearer(token),
+	}
+
+	endpoint := databasePath(dbID, "call", reducerName)
+	resp, err := c.HTTPClient.DoContext(ctx, "POST", endpoint, headers, bytes.NewReader(jsonData))
+	if err != nil {
+		return ReducerResult{}, err
+	}
+	defer resp.Body.Close()
+
+	result := reducerResult(resp.Header)
+	bodyBytes, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return result, fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(bodyBytes))
+	}
+	return result, nil
+}
+
+func reducerResult(h http.Header) ReducerResult {
+	var result ReducerResult
+	if v := h.Get(energyUsedHeader); v != "" {
+		result.EnergyUsed, _ = strconv.ParseUint(v, 10, 64)
+	}
+	if v := h.Get(executionDurationHeader); v != "" {
+		micros, _ := strconv.ParseInt(v, 10, 64)
+		result.Duration = time.Duration(micros) * time.Microsecond
+	}
+	return result
+}
+
+// TransactionEnergyUsed extracts the energy charged for the reducer call a
+// TransactionUpdate message reports, as received on a JSON protocol
+// websocket. ok is false for any other message.
+func TransactionEnergyUsed(msg []byte) (quanta uint64, ok bool) {
+	var update struct {
+		TransactionUpdate *struct {
+			EnergyQuantaUsed struct {
+				Quanta json.Number `json:"quanta"`
+			} `json:"energy_quanta_used"`
+		} `json:"TransactionUpdate"`
+	}
+	if err := json.Unmarshal(msg, &update); err != nil || update.TransactionUpdate == nil {
+		return 0, false
+	}
+	quanta, err := strconv.ParseUint(update.TransactionUpdate.EnergyQuantaUsed.Quanta.String(), 10, 64)
+	return quanta, err == nil
+}
+
+// reserve admits a call under a refusing budget, holding back as much as
+// the most expensive call so far until charge settles it. It fails once
+// the energy spent and held reaches the limit.
+func (c *Client) reserve() (uint64, error) {
+	b := c.Budget
+	if b == nil || !b.Refuse || b.Limit == 0 {
+		return 0, nil
+	}
+	c.energy.mu.Lock()
+	defer c.energy.mu.Unlock()
+	if c.energy.spent+c.energy.reserved >= b.Limit {
+		return 0, fmt.Errorf("%w: spent %d of %d quanta, %d held by calls in flight", ErrEnergyBudgetExceeded, c.energy.spent, b.Limit, c.energy.reserved)
+	}
+	hold := max(c.energy.largest, 1)
+	c.energy.reserved += hold
+	return hold, nil
+}
+
+// charge releases a call's reservation and adds what it cost. When that
+// crosses a threshold it logs, calls OnWarning and returns the warning.
+func (c *Client) charge(reducerName string, hold, quanta uint64) error {
+	c.energy.mu.Lock()
+	c.energy.reserved -= hold
+	c.energy.spent += quanta
+	c.energy.largest = max(c.energy.largest, quanta)
+	spent := c.energy.spent
+
+	var warn, over bool
+	if b := c.Budget; b != nil {
+		if b.WarnAt > 0 && spent >= b.WarnAt && !c.energy.warned {
+			c.energy.warned, warn = true, true
+		}
+		if b.Limit > 0 && spent >= b.Limit && !c.energy.over {
+			c.energy.over, over = true, true
+		}
+	}
+	c.energy.mu.Unlock()
+
+	var warning error
+	if warn {
+		c.log().Warn("energy budget warning threshold reached", "reducer", reducerName, "spent", spent, "warn_at", c.Budget.WarnAt)
+		warning = fmt.Errorf("%w: spent %d quanta, warning at %d", ErrEnergyBudgetWarning, spent, c.Budget.WarnAt)
+	}
+	if over {
+		c.log().Warn("energy budget limit reached", "reducer", reducerName, "spent", spent, "limit", c.Budget.Limit)
+		warning = fmt.Errorf("%w: spent %d of %d quanta", ErrEnergyBudgetExceeded, spent, c.Budget.Limit)
+	}
+	if warning != nil && c.Budget.OnWarning != nil {
+		c.Budget.OnWarning(warning)
+	}
+	return warning
+}
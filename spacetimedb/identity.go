@@ -1,6 +1,7 @@
 package spacetimedb
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -18,8 +19,12 @@ type Identity interface {
 }
 
 func (c *Client) CreateIdentity() (string, string, error) {
+	return c.createIdentity(context.Background())
+}
+
+func (c *Client) createIdentity(ctx context.Context) (string, string, error) {
 	// Make a request
-	resp, err := c.HTTPClient.Do("POST", "/v1/identity", nil, nil)
+	resp, err := c.HTTPClient.DoContext(ctx, "POST", identityPath(), nil, nil)
 	if err != nil {
 		return "", "", fmt.Errorf("failed to create identity: %w", err)
 	}
@@ -45,19 +50,23 @@ func (c *Client) CreateIdentity() (string, string, error) {
 }
 
 func (c *Client) CreateIdentityWebsocketToken() (string, error) {
+	return c.createIdentityWebsocketToken(context.Background())
+}
+
+func (c *Client) createIdentityWebsocketToken(ctx context.Context) (string, error) {
 	// First get a token which is needed for websocketToken call
-	_, token, err := c.CreateIdentity()
+	_, token, err := c.createIdentity(ctx)
 	if err != nil {
 		return "", fmt.Errorf("unable to generate token %w", err)
 	}
 
 	// Construct headers which would be added in the request
 	headers := map[string]string{
-		"Authorization": fmt.Sprintf("Bearer %s", token),
+		"Authorization": c.bearer(token),
 	}
 
 	// Make the request
-	resp, err := c.HTTPClient.Do("POST", "/v1/identity/websocket-token", headers, nil)
+	resp, err := c.HTTPClient.DoContext(ctx, "POST", identityPath("websocket-token"), headers, nil)
 	if err != nil {
 		return "", fmt.Errorf("websocket-token request failed: %w", err)
 	}
@@ -81,8 +90,12 @@ func (c *Client) CreateIdentityWebsocketToken() (string, error) {
 }
 
 func (c *Client) GetPublicKey() (string, error) {
+	return c.getPublicKey(context.Background())
+}
+
+func (c *Client) getPublicKey(ctx context.Context) (string, error) {
 	// Make a request
-	resp, err := c.HTTPClient.Do("GET", "/v1/identity/public-key", nil, nil)
+	resp, err := c.HTTPClient.DoContext(ctx, "GET", identityPath("public-key"), nil, nil)
 	if err != nil {
 		return "", fmt.Errorf("public key request failed: %w", err)
 	}
@@ -105,24 +118,25 @@ func (c *Client) GetPublicKey() (string, error) {
 
 // FIXME: Endpoint issue
 func (c *Client) RegisterIdentityWithEmail(email string) (string, string, error) {
-	identity, token, err := c.CreateIdentity()
+	return c.registerIdentityWithEmail(context.Background(), email)
+}
+
+func (c *Client) registerIdentityWithEmail(ctx context.Context, email string) (string, string, error) {
+	identity, token, err := c.createIdentity(ctx)
 	if err != nil {
 		return "", "", fmt.Errorf("failed to create identity: %w", err)
 	}
 
-	// Base path
-	basePath := fmt.Sprintf("/v1/identity/%s/set-email", identity)
-
 	// Attach email as query param
-	endpoint := fmt.Sprintf("%s?email=%s", basePath, email)
+	endpoint := withQuery(identityPath(identity, "set-email"), url.Values{"email": {email}})
 
-	fmt.Println(endpoint)
+	c.log().Debug("registering identity email", "endpoint", endpoint)
 
 	headers := map[string]string{
-		"Authorization": fmt.Sprintf("Bearer %s", token),
+		"Authorization": c.bearer(token),
 	}
 
-	resp, err := c.HTTPClient.Do("POST", endpoint, headers, nil)
+	resp, err := c.HTTPClient.DoContext(ctx, "POST", endpoint, headers, nil)
 	if err != nil {
 		return "", "", fmt.Errorf("failed to set email: %w", err)
 	}
@@ -136,10 +150,14 @@ func (c *Client) RegisterIdentityWithEmail(email string) (string, string, error)
 }
 
 func (c *Client) GetDatabasesByIdentity(identity string) ([]string, error) {
+	return c.getDatabasesByIdentity(context.Background(), identity)
+}
 
-	endpoint := fmt.Sprintf("/v1/identity/%s/databases", url.PathEscape(identity))
+func (c *Client) getDatabasesByIdentity(ctx context.Context, identity string) ([]string, error) {
 
-	resp, err := c.HTTPClient.Do("GET", endpoint, nil, nil)
+	endpoint := identityPath(identity, "databases")
+
+	resp, err := c.HTTPClient.DoContext(ctx, "GET", endpoint, nil, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get databases: %w", err)
 	}
@@ -162,16 +180,20 @@ func (c *Client) GetDatabasesByIdentity(identity string) ([]string, error) {
 }
 
 func (c *Client) VerifyIdentityToken(identity, token string) error {
+	return c.verifyIdentityToken(context.Background(), identity, token)
+}
+
+func (c *Client) verifyIdentityToken(ctx context.Context, identity, token string) error {
 	// Construct the endpoint URL
-	endpoint := fmt.Sprintf("/v1/identity/%s/verify", url.PathEscape(identity))
+	endpoint := identityPath(identity, "verify")
 
 	// Build the Authorization header using Bearer token
 	headers := map[string]string{
-		"Authorization": fmt.Sprintf("Bearer %s", token),
+		"Authorization": c.bearer(token),
 	}
 
 	// Perform the GET request
-	resp, err := c.HTTPClient.Do("GET", endpoint, headers, nil)
+	resp, err := c.HTTPClient.DoContext(ctx, "GET", endpoint, headers, nil)
 	if err != nil {
 		return fmt.Errorf("verify request failed: %w", err)
 	}
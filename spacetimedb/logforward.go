@@ -0,0 +1,84 @@
+package spacetimedb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// SlogLevel maps a module log level onto the closest slog level. Trace and
+// panic have no slog equivalent and sit one step below Debug and above Error.
+func (l LogLevel) SlogLevel() slog.Level {
+	switch l {
+	case LogLevelTrace:
+		return slog.LevelDebug - 4
+	case LogLevelDebug:
+		return slog.LevelDebug
+	case LogLevelInfo:
+		return slog.LevelInfo
+	case LogLevelWarn:
+		return slog.LevelWarn
+	case LogLevelError:
+		return slog.LevelError
+	default:
+		return slog.LevelError + 4
+	}
+}
+
+// ForwardDatabaseLogs tails a database's logs and writes every record to
+// handler, so module logs end up in the same pipeline as the rest of the
+// service. Any slog.Handler works, including bridges into OpenTelemetry.
+//
+// Each record carries the database name and identity, the module target and
+// the source file and line. It returns when the stream ends, or with
+// ctx.Err() once ctx is cancelled. Lines that cannot be parsed are skipped,
+// and the first is returned as ErrInvalidLogLine when the stream ends.
+func ForwardDatabaseLogs(ctx context.Context, client DBClient, handler slog.Handler, dbNameOrIden, token string, opts LogStreamOptions) error {
+	identity, err := client.GetDatabaseIdentity(dbNameOrIden)
+	if err != nil {
+		return fmt.Errorf("failed to resolve database identity: %w", err)
+	}
+	identity = strings.TrimSpace(identity)
+
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	records, errs, err := client.StreamDatabaseLogs(streamCtx, dbNameOrIden, token, opts)
+	if err != nil {
+		return err
+	}
+
+	for rec := range records {
+		level := rec.Level.SlogLevel()
+		if !handler.Enabled(ctx, level) {
+			continue
+		}
+
+		r := slog.NewRecord(rec.Timestamp, level, rec.Message, 0)
+		r.AddAttrs(
+			slog.String("database", dbNameOrIden),
+			slog.String("database_identity", identity),
+			slog.String("target", rec.Target),
+			slog.String("file", rec.Filename),
+			slog.Int("line", int(rec.LineNumber)),
+		)
+
+		if err := handler.Handle(ctx, r); err != nil {
+			return fmt.Errorf("failed to forward log record: %w", err)
+		}
+	}
+
+	// A skipped line is only reported when nothing ended the stream.
+	var streamErr error
+	for err := range errs {
+		if streamErr == nil || !errors.Is(err, ErrInvalidLogLine) {
+			streamErr = err
+		}
+	}
+	if streamErr != nil {
+		return streamErr
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,225 @@
+package spacetimedb
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Logs interface {
+	StreamDatabaseLogs(ctx context.Context, dbNameOrIden, token string, opts LogStreamOptions) (<-chan LogRecord, <-chan error, error)
+}
+
+// ErrInvalidLogLine is reported for a log line that could not be parsed. The
+// line is skipped and the stream goes on.
+var ErrInvalidLogLine = errors.New("invalid log line")
+
+// LogLevel is the severity of a module log record, ordered from least to
+// most severe.
+type LogLevel int
+
+const (
+	LogLevelTrace LogLevel = iota
+	LogLevelDebug
+	LogLevelInfo
+	LogLevelWarn
+	LogLevelError
+	LogLevelPanic
+)
+
+var logLevelNames = map[LogLevel]string{
+	LogLevelTrace: "trace",
+	LogLevelDebug: "debug",
+	LogLevelInfo:  "info",
+	LogLevelWarn:  "warn",
+	LogLevelError: "error",
+	LogLevelPanic: "panic",
+}
+
+func (l LogLevel) String() string {
+	if name, ok := logLevelNames[l]; ok {
+		return name
+	}
+	return fmt.Sprintf("LogLevel(%d)", int(l))
+}
+
+// ParseLogLevel parses a level name as emitted by the server, case-insensitively.
+func ParseLogLevel(s string) (LogLevel, error) {
+	for level, name := range logLevelNames {
+		if strings.EqualFold(s, name) {
+			return level, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level %q", s)
+}
+
+func (l *LogLevel) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("log level must be a string: %w", err)
+	}
+	level, err := ParseLogLevel(s)
+	if err != nil {
+		return err
+	}
+	*l = level
+	return nil
+}
+
+// LogRecord is a single parsed line of a module's log.
+type LogRecord struct {
+	Level      LogLevel
+	Timestamp  time.Time
+	Target     string
+	Filename   string
+	LineNumber uint32
+	Message    string
+}
+
+func (r *LogRecord) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Level      LogLevel        `json:"level"`
+		Ts         json.RawMessage `json:"ts"`
+		Target     string          `json:"target"`
+		Filename   string          `json:"filename"`
+		LineNumber uint32          `json:"line_number"`
+		Message    string          `json:"message"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	ts, err := parseLogTimestamp(raw.Ts)
+	if err != nil {
+		return err
+	}
+
+	*r = LogRecord{
+		Level:      raw.Level,
+		Timestamp:  ts,
+		Target:     raw.Target,
+		Filename:   raw.Filename,
+		LineNumber: raw.LineNumber,
+		Message:    raw.Message,
+	}
+	return nil
+}
+
+// parseLogTimestamp accepts microseconds since the epoch, which is what the
+// server sends, or an RFC 3339 string.
+func parseLogTimestamp(raw json.RawMessage) (time.Time, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return time.Time{}, nil
+	}
+
+	if raw[0] == '"' {
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return time.Time{}, fmt.Errorf("invalid log timestamp: %w", err)
+		}
+		ts, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid log timestamp: %w", err)
+		}
+		return ts, nil
+	}
+
+	micros, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid log timestamp: %w", err)
+	}
+	return time.UnixMicro(micros).UTC(), nil
+}
+
+// LogStreamOptions controls StreamDatabaseLogs.
+type LogStreamOptions struct {
+	// NumLines is the number of past lines to fetch, 0 lets the server decide.
+	NumLines int
+	// Follow keeps the stream open for new records until ctx is cancelled.
+	Follow bool
+	// MinLevel drops records below this level. The zero value keeps everything.
+	MinLevel LogLevel
+}
+
+// StreamDatabaseLogs fetches a database's logs and delivers each parsed record
+// on the returned channel. Both channels are closed when the stream ends, and
+// cancelling ctx ends it without an error. A line that cannot be parsed is
+// skipped and reported as ErrInvalidLogLine, unless an earlier report is
+// still unread; an error that ends the stream is always sent, last.
+func (c *Client) StreamDatabaseLogs(ctx context.Context, dbNameOrIden, token string, opts LogStreamOptions) (<-chan LogRecord, <-chan error, error) {
+	params := url.Values{}
+	if opts.NumLines > 0 {
+		params.Set("num_lines", strconv.Itoa(opts.NumLines))
+	}
+	if opts.Follow {
+		params.Set("follow", "true")
+	}
+	endpoint := withQuery(databasePath(dbNameOrIden, "logs"), params)
+
+	headers := map[string]string{
+		"Authorization": c.bearer(token),
+	}
+
+	resp, err := c.HTTPClient.DoStream(ctx, "GET", endpoint, headers, nil)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to request logs: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return nil, nil, fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(body))
+	}
+
+	records := make(chan LogRecord)
+	// One slot for a skipped line and one for the error ending the stream,
+	// so the reader never blocks on a consumer that drains errs last.
+	errs := make(chan error, 2)
+
+	go func() {
+		defer close(errs)
+		defer close(records)
+		defer resp.Body.Close()
+
+		scanner := bufio.NewScanner(resp.Body)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+		for scanner.Scan() {
+			line := scanner.Bytes()
+			if len(line) == 0 {
+				continue
+			}
+
+			var rec LogRecord
+			if err := json.Unmarshal(line, &rec); err != nil {
+				if len(errs) == 0 {
+					errs <- fmt.Errorf("%w %q: %w", ErrInvalidLogLine, line, err)
+				}
+				continue
+			}
+			if rec.Level < opts.MinLevel {
+				continue
+			}
+
+			select {
+			case records <- rec:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		if err := scanner.Err(); err != nil && ctx.Err() == nil {
+			errs <- fmt.Errorf("failed to read logs: %w", err)
+		}
+	}()
+
+	return records, errs, nil
+}
@@ -0,0 +1,159 @@
+package spacetimedb_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/briheet/spacetime-goclient/spacetimedb"
+)
+
+func TestStreamDatabaseLogs(t *testing.T) {
+	type line struct {
+		level   spacetimedb.LogLevel
+		message string
+	}
+	tests := []struct {
+		name string
+		opts spacetimedb.LogStreamOptions
+		// before is logged before the stream starts, after once it runs.
+		before, after []line
+		want          []string
+	}{
+		{
+			name:   "all",
+			before: []line{{spacetimedb.LogLevelInfo, "a"}, {spacetimedb.LogLevelDebug, "b"}},
+			want:   []string{"a", "b"},
+		},
+		{
+			name:   "last lines",
+			opts:   spacetimedb.LogStreamOptions{NumLines: 2},
+			before: []line{{spacetimedb.LogLevelInfo, "a"}, {spacetimedb.LogLevelInfo, "b"}, {spacetimedb.LogLevelInfo, "c"}},
+			want:   []string{"b", "c"},
+		},
+		{
+			name:   "min level",
+			opts:   spacetimedb.LogStreamOptions{MinLevel: spacetimedb.LogLevelWarn},
+			before: []line{{spacetimedb.LogLevelInfo, "a"}, {spacetimedb.LogLevelWarn, "b"}, {spacetimedb.LogLevelError, "c"}},
+			want:   []string{"b", "c"},
+		},
+		{
+			name:   "follow",
+			opts:   spacetimedb.LogStreamOptions{Follow: true},
+			before: []line{{spacetimedb.LogLevelInfo, "a"}},
+			after:  []line{{spacetimedb.LogLevelInfo, "b"}, {spacetimedb.LogLevelPanic, "c"}},
+			want:   []string{"a", "b", "c"},
+		},
+		{
+			name:   "follow at min level",
+			opts:   spacetimedb.LogStreamOptions{Follow: true, MinLevel: spacetimedb.LogLevelError},
+			before: []line{{spacetimedb.LogLevelError, "a"}},
+			after:  []line{{spacetimedb.LogLevelTrace, "b"}, {spacetimedb.LogLevelError, "c"}},
+			want:   []string{"a", "c"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t)
+			for _, l := range tt.before {
+				f.db.Log(l.level, l.message)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			records, errs, err := f.client.StreamDatabaseLogs(ctx, "chat", f.token, tt.opts)
+			must(t, err)
+			for _, l := range tt.after {
+				f.db.Log(l.level, l.message)
+			}
+
+			var got []string
+			for len(got) < len(tt.want) {
+				rec, ok := <-records
+				if !ok {
+					break
+				}
+				got = append(got, rec.Message)
+			}
+			equal(t, "messages", got, tt.want)
+
+			// A followed stream ends when ctx does, a plain one by itself,
+			// and neither with an error.
+			if tt.opts.Follow {
+				cancel()
+			}
+			for rec := range records {
+				t.Errorf("unexpected record %q", rec.Message)
+			}
+			if err := <-errs; err != nil {
+				t.Errorf("stream error: %v", err)
+			}
+		})
+	}
+}
+
+func TestGetDatabaseLogsFollow(t *testing.T) {
+	f := newFixture(t)
+	f.db.Log(spacetimedb.LogLevelInfo, "first")
+
+	body, err := f.client.GetDatabaseLogs("chat", f.token, 0, true)
+	must(t, err)
+	defer body.Close()
+	lines := bufio.NewScanner(body)
+
+	for _, want := range []string{"first", "second", "third"} {
+		if want != "first" {
+			f.db.Log(spacetimedb.LogLevelInfo, want)
+		}
+		if !lines.Scan() {
+			t.Fatalf("stream ended before %q: %v", want, lines.Err())
+		}
+		var rec spacetimedb.LogRecord
+		must(t, json.Unmarshal(lines.Bytes(), &rec))
+		equal(t, "message", rec.Message, want)
+	}
+}
+
+func TestStreamDatabaseLogsSkipsBadLines(t *testing.T) {
+	f := newFixture(t)
+	f.srv.Fail("GET", "/v1/database/chat/logs", 200,
+		`{"level":"info","ts":1,"message":"a"}`+"\nnot json\n"+`{"level":"info","ts":2,"message":"b"}`+"\n{}x\n")
+
+	records, errs, err := f.client.StreamDatabaseLogs(context.Background(), "chat", f.token, spacetimedb.LogStreamOptions{})
+	must(t, err)
+	var got []string
+	for rec := range records {
+		got = append(got, rec.Message)
+	}
+	equal(t, "messages", got, []string{"a", "b"})
+
+	// Only the first bad line is reported while nobody reads errs.
+	var reported []error
+	for err := range errs {
+		reported = append(reported, err)
+	}
+	if len(reported) != 1 || !errors.Is(reported[0], spacetimedb.ErrInvalidLogLine) {
+		t.Fatalf("errors = %v, want one ErrInvalidLogLine", reported)
+	}
+}
+
+func TestGetDatabaseLogsFollowOutlivesTimeout(t *testing.T) {
+	f := newFixture(t, spacetimedb.WithHTTPTimeout(50*time.Millisecond))
+
+	body, err := f.client.GetDatabaseLogs("chat", f.token, 0, true)
+	must(t, err)
+	defer body.Close()
+	time.Sleep(150 * time.Millisecond)
+	f.db.Log(spacetimedb.LogLevelInfo, "late")
+
+	lines := bufio.NewScanner(body)
+	if !lines.Scan() {
+		t.Fatalf("stream ended: %v", lines.Err())
+	}
+	var rec spacetimedb.LogRecord
+	must(t, json.Unmarshal(lines.Bytes(), &rec))
+	equal(t, "message", rec.Message, "late")
+}
@@ -0,0 +1,62 @@
+package spacetimedb_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/briheet/spacetime-goclient/metrics"
+	"github.com/briheet/spacetime-goclient/spacetimedb"
+)
+
+func TestMetricsExposition(t *testing.T) {
+	ctx := context.Background()
+	collector := metrics.NewCollector()
+	f := newFixture(t, spacetimedb.WithMetrics(collector))
+
+	must(t, f.client.Ping())
+	must(t, f.client.CallReducer(ctx, "add", "chat", f.token, []any{"bob"}))
+	if err := f.client.CallReducer(ctx, "fail", "chat", f.token, []any{}); err == nil {
+		t.Fatal("failing reducer call succeeded")
+	}
+
+	conn, err := f.client.OpenConnection(ctx, "chat", f.token, "")
+	must(t, err)
+	defer conn.Close()
+	await(t, conn, `"IdentityToken"`)
+	_, err = conn.CallReducer(ctx, "add", []any{"carol"})
+	must(t, err)
+	await(t, conn, `"TransactionUpdate"`, `"Committed"`)
+	_, err = conn.CallReducer(ctx, "fail", []any{})
+	must(t, err)
+	await(t, conn, `"TransactionUpdate"`, `"Failed"`)
+
+	rec := httptest.NewRecorder()
+	collector.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q, want the Prometheus text format", ct)
+	}
+	body := rec.Body.String()
+
+	for _, want := range []string{
+		"# TYPE spacetimedb_client_http_requests_total counter\n",
+		`spacetimedb_client_http_requests_total{method="GET",route="/v1/ping",code="200"} 1` + "\n",
+		`spacetimedb_client_http_requests_total{method="POST",route="/v1/database/:db/call/:reducer",code="200"} 1` + "\n",
+		`spacetimedb_client_http_requests_total{method="POST",route="/v1/database/:db/call/:reducer",code="530"} 1` + "\n",
+		`spacetimedb_client_http_request_duration_seconds_count{method="GET",route="/v1/ping"} 1` + "\n",
+		"# TYPE spacetimedb_client_reducer_calls_total counter\n",
+		// One call of each over HTTP and one over the connection.
+		`spacetimedb_client_reducer_calls_total{reducer="add",outcome="ok"} 2` + "\n",
+		`spacetimedb_client_reducer_calls_total{reducer="fail",outcome="failed"} 2` + "\n",
+		`spacetimedb_client_reducer_call_duration_seconds_bucket{reducer="add",le="+Inf"} 2` + "\n",
+		`spacetimedb_client_reducer_call_duration_seconds_count{reducer="fail"} 2` + "\n",
+		`spacetimedb_client_websocket_messages_total{direction="out",type="text"} 2` + "\n",
+		`spacetimedb_client_websocket_messages_total{direction="in",type="text"}`,
+		`spacetimedb_client_websocket_message_bytes_total{direction="out"}`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("exposition lacks %q:\n%s", want, body)
+		}
+	}
+}
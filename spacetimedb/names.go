@@ -0,0 +1,172 @@
+package spacetimedb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+)
+
+type Names interface {
+	SetDatabaseNames(nameOrIdentity string, names []string, token string) error
+	ReplaceDatabaseName(nameOrIdentity, oldName, newName, token string) error
+	RemoveDatabaseName(nameOrIdentity, name, token string) error
+	LookupDatabaseName(name string) (*NameInfo, error)
+	GetDatabaseInventory(owner string) ([]DatabaseNames, error)
+}
+
+// ErrNameNotFound is returned when removing or replacing a name the
+// database does not have.
+var ErrNameNotFound = errors.New("name not found")
+
+// NameInfo is what a name resolves to.
+type NameInfo struct {
+	Name     string
+	Database string
+	Owner    string
+	// Names are all the names of the database, Name among them.
+	Names []string
+}
+
+// DatabaseNames is one database in an owner's inventory.
+type DatabaseNames struct {
+	Database string
+	Names    []string
+}
+
+// SetDatabaseNames replaces every name of a database with names. Names the
+// database had before and names does not list are released.
+func (c *Client) SetDatabaseNames(nameOrIdentity string, names []string, token string) error {
+	return c.setDatabaseNames(context.Background(), nameOrIdentity, names, token)
+}
+
+func (c *Client) setDatabaseNames(ctx context.Context, nameOrIdentity string, names []string, token string) error {
+	if names == nil {
+		names = []string{}
+	}
+	body, err := json.Marshal(names)
+	if err != nil {
+		return fmt.Errorf("failed to marshal names: %w", err)
+	}
+
+	headers := map[string]string{
+		"Content-Type":  "application/json",
+		"Authorization": c.bearer(token),
+	}
+	resp, err := c.HTTPClient.DoContext(ctx, "PUT", databasePath(nameOrIdentity, "names"), headers, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	bodyBytes, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: %s", ErrDatabaseNotFound, nameOrIdentity)
+	}
+
+	var result map[string]json.RawMessage
+	if err := json.Unmarshal(bodyBytes, &result); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("failed to set names, status %d: %s", resp.StatusCode, string(bodyBytes))
+		}
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	if _, ok := result["Success"]; ok && resp.StatusCode == http.StatusOK {
+		// A released name may now lead to another module.
+		c.schemas.reset()
+		return nil
+	}
+	if val, ok := result["PermissionDenied"]; ok {
+		var denial struct {
+			Domain string `json:"domain"`
+		}
+		json.Unmarshal(val, &denial)
+		return fmt.Errorf("permission denied for domain: %s", denial.Domain)
+	}
+	return fmt.Errorf("failed to set names, status %d: %s", resp.StatusCode, string(bodyBytes))
+}
+
+// ReplaceDatabaseName renames oldName to newName, keeping the database's
+// other names. The names are read and written back, so concurrent changes
+// to the same database's names may be lost.
+func (c *Client) ReplaceDatabaseName(nameOrIdentity, oldName, newName, token string) error {
+	return c.replaceDatabaseName(context.Background(), nameOrIdentity, oldName, newName, token)
+}
+
+func (c *Client) replaceDatabaseName(ctx context.Context, nameOrIdentity, oldName, newName, token string) error {
+	names, err := c.getDatabaseNames(ctx, nameOrIdentity)
+	if err != nil {
+		return err
+	}
+	i := slices.Index(names, oldName)
+	if i < 0 {
+		return fmt.Errorf("%w: %s is not a name of %s", ErrNameNotFound, oldName, nameOrIdentity)
+	}
+	if slices.Contains(names, newName) {
+		names = slices.Delete(names, i, i+1)
+	} else {
+		names[i] = newName
+	}
+	return c.setDatabaseNames(ctx, nameOrIdentity, names, token)
+}
+
+// RemoveDatabaseName releases one name of a database, keeping the others.
+// Like ReplaceDatabaseName it reads and writes back the full list.
+func (c *Client) RemoveDatabaseName(nameOrIdentity, name, token string) error {
+	return c.removeDatabaseName(context.Background(), nameOrIdentity, name, token)
+}
+
+func (c *Client) removeDatabaseName(ctx context.Context, nameOrIdentity, name, token string) error {
+	names, err := c.getDatabaseNames(ctx, nameOrIdentity)
+	if err != nil {
+		return err
+	}
+	i := slices.Index(names, name)
+	if i < 0 {
+		return fmt.Errorf("%w: %s is not a name of %s", ErrNameNotFound, name, nameOrIdentity)
+	}
+	return c.setDatabaseNames(ctx, nameOrIdentity, slices.Delete(names, i, i+1), token)
+}
+
+// LookupDatabaseName resolves a name to its database and that database's
+// owner. It returns ErrDatabaseNotFound for a name nobody holds.
+func (c *Client) LookupDatabaseName(name string) (*NameInfo, error) {
+	return c.lookupDatabaseName(context.Background(), name)
+}
+
+func (c *Client) lookupDatabaseName(ctx context.Context, name string) (*NameInfo, error) {
+	identity, owner, _, _, err := c.getDatabaseInfo(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	names, err := c.getDatabaseNames(ctx, identity)
+	if err != nil {
+		return nil, err
+	}
+	return &NameInfo{Name: name, Database: identity, Owner: owner, Names: names}, nil
+}
+
+// GetDatabaseInventory lists every database owner has, with its names.
+func (c *Client) GetDatabaseInventory(owner string) ([]DatabaseNames, error) {
+	return c.getDatabaseInventory(context.Background(), owner)
+}
+
+func (c *Client) getDatabaseInventory(ctx context.Context, owner string) ([]DatabaseNames, error) {
+	identities, err := c.getDatabasesByIdentity(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+	inventory := make([]DatabaseNames, 0, len(identities))
+	for _, identity := range identities {
+		names, err := c.getDatabaseNames(ctx, identity)
+		if err != nil {
+			return nil, fmt.Errorf("names of %s: %w", identity, err)
+		}
+		inventory = append(inventory, DatabaseNames{Database: identity, Names: names})
+	}
+	return inventory, nil
+}
@@ -0,0 +1,285 @@
+package spacetimedb
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/briheet/spacetime-goclient/metrics"
+	httpClient "github.com/briheet/spacetime-goclient/transport/http"
+	websocketsClient "github.com/briheet/spacetime-goclient/transport/websockets"
+	"github.com/gorilla/websocket"
+)
+
+// DefaultURL is the server Connect uses when no URL is given, matching the
+// default of a local `spacetime start`.
+const DefaultURL = "http://localhost:3000"
+
+// DefaultHTTPTimeout bounds REST calls unless WithHTTPTimeout says otherwise.
+const DefaultHTTPTimeout = 3 * time.Second
+
+type config struct {
+	url      string
+	dbName   string
+	token    string
+	identity string
+	protocol string
+	logger   *slog.Logger
+	budget   *EnergyBudget
+	recorder metrics.Recorder
+	validate bool
+	tls      *tls.Config
+	httpOpts []httpClient.Option
+	wsOpts   []websocketsClient.Option
+}
+
+type Option func(*config) error
+
+// WithURL sets the server to connect to. It is either a full URL such as
+// "https://example.com/spacetime", whose scheme selects TLS and whose path
+// is kept as a prefix for every route, or a bare "host:port".
+func WithURL(url string) Option {
+	return func(c *config) error {
+		if url == "" {
+			return fmt.Errorf("URL cannot be empty")
+		}
+		c.url = url
+		return nil
+	}
+}
+
+// WithHost sets the server as a plain-text host and port.
+func WithHost(host, port string) Option {
+	return func(c *config) error {
+		if host == "" || port == "" {
+			return fmt.Errorf("host and port cannot be empty")
+		}
+		c.url = "http://" + net.JoinHostPort(host, port)
+		return nil
+	}
+}
+
+// WithDatabase sets the database name or identity the client works with.
+func WithDatabase(nameOrIdentity string) Option {
+	return func(c *config) error {
+		c.dbName = nameOrIdentity
+		return nil
+	}
+}
+
+// WithToken sets the token used by calls that are given an empty token.
+func WithToken(token string) Option {
+	return func(c *config) error {
+		c.token = token
+		return nil
+	}
+}
+
+// WithIdentity records the identity that owns the token set by WithToken.
+func WithIdentity(identity string) Option {
+	return func(c *config) error {
+		c.identity = identity
+		return nil
+	}
+}
+
+// WithProtocol sets the websocket subprotocol used when WebsocketSubscribe
+// is called with an empty protocol.
+func WithProtocol(protocol string) Option {
+	return func(c *config) error {
+		if protocol == "" {
+			return fmt.Errorf("protocol cannot be empty")
+		}
+		c.protocol = protocol
+		return nil
+	}
+}
+
+// WithLogger logs every HTTP request at debug level, and failures at warn.
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *config) error {
+		if logger == nil {
+			return fmt.Errorf("logger cannot be nil")
+		}
+		c.logger = logger
+		return nil
+	}
+}
+
+// WithMetrics records every HTTP request, websocket message and reducer
+// call with r, including calls made over a Connection, which are recorded
+// when their TransactionUpdate arrives. It replaces a hook set with
+// websocketsClient.WithMessageHook.
+func WithMetrics(r metrics.Recorder) Option {
+	return func(c *config) error {
+		if r == nil {
+			return fmt.Errorf("metrics recorder cannot be nil")
+		}
+		c.recorder = r
+		return nil
+	}
+}
+
+// WithEnergyBudget tracks the energy reducer calls report and warns, or
+// refuses further calls, once the budget's thresholds are crossed.
+func WithEnergyBudget(budget EnergyBudget) Option {
+	return func(c *config) error {
+		if budget.WarnAt > 0 && budget.Limit > 0 && budget.WarnAt > budget.Limit {
+			return fmt.Errorf("energy warning threshold %d is above the limit %d", budget.WarnAt, budget.Limit)
+		}
+		c.budget = &budget
+		return nil
+	}
+}
+
+// WithArgValidation checks reducer calls against the module schema before
+// sending them, so an unknown reducer or a wrong argument fails at the call
+// site with ErrInvalidArgs instead of on the server. Each database's schema
+// is fetched on its first call and kept until the client publishes a module.
+func WithArgValidation() Option {
+	return func(c *config) error {
+		c.validate = true
+		return nil
+	}
+}
+
+// WithHTTPTimeout bounds every REST call. Streams are not affected.
+func WithHTTPTimeout(timeout time.Duration) Option {
+	return WithHTTPOptions(httpClient.WithTimeout(timeout))
+}
+
+// WithHTTPClient sends REST calls through a copy of hc; TLS options and the
+// timeout are applied to the copy. Connect fails if TLS options are set and
+// hc's transport is not an *http.Transport.
+func WithHTTPClient(hc *http.Client) Option {
+	return WithHTTPOptions(httpClient.WithCustomHTTPClient(hc))
+}
+
+// WithDialTimeout bounds the websocket handshake.
+func WithDialTimeout(timeout time.Duration) Option {
+	return WithWebsocketOptions(websocketsClient.WithDialTimeout(timeout))
+}
+
+// WithDialer opens websocket connections through a copy of d, to which the
+// TLS and dial options are applied.
+func WithDialer(d *websocket.Dialer) Option {
+	return WithWebsocketOptions(websocketsClient.WithCustomDialer(d))
+}
+
+// WithCompression turns permessage-deflate on the websocket on or off.
+func WithCompression(enabled bool) Option {
+	return WithWebsocketOptions(func(c *websocketsClient.Client) error {
+		c.Dialer.EnableCompression = enabled
+		return nil
+	})
+}
+
+// WithMessageCompression asks the server to compress binary websocket
+// messages, which ReadWebsocketMessage then decompresses. Brotli needs a
+// decoder registered with websocketsClient.RegisterBrotli.
+func WithMessageCompression(c websocketsClient.Compression) Option {
+	return WithWebsocketOptions(websocketsClient.WithMessageCompression(c))
+}
+
+// WithKeepalive pings the server every interval on connections opened with
+// OpenConnection and ends them with websocketsClient.ErrDeadConnection when
+// a pong takes longer than pongTimeout.
+func WithKeepalive(interval, pongTimeout time.Duration) Option {
+	return WithWebsocketOptions(websocketsClient.WithKeepalive(interval, pongTimeout))
+}
+
+// WithIdleTimeout treats a websocket that has received nothing for d as dead.
+func WithIdleTimeout(d time.Duration) Option {
+	return WithWebsocketOptions(websocketsClient.WithIdleTimeout(d))
+}
+
+// WithHTTPOptions forwards options to the HTTP transport, e.g. retries or
+// middleware. They are applied in order after the defaults.
+func WithHTTPOptions(opts ...httpClient.Option) Option {
+	return func(c *config) error {
+		c.httpOpts = append(c.httpOpts, opts...)
+		return nil
+	}
+}
+
+// WithWebsocketOptions forwards options to the websocket transport.
+func WithWebsocketOptions(opts ...websocketsClient.Option) Option {
+	return func(c *config) error {
+		c.wsOpts = append(c.wsOpts, opts...)
+		return nil
+	}
+}
+
+// tlsConfig returns the TLS config being built, creating it on first use.
+func (c *config) tlsConfig() *tls.Config {
+	if c.tls == nil {
+		c.tls = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	return c.tls
+}
+
+// WithTLSConfig uses cfg for both https and wss connections. Options applied
+// after it, such as WithCACertFile, modify a copy of cfg.
+func WithTLSConfig(cfg *tls.Config) Option {
+	return func(c *config) error {
+		if cfg == nil {
+			return fmt.Errorf("TLS config cannot be nil")
+		}
+		c.tls = cfg.Clone()
+		return nil
+	}
+}
+
+// WithCACertFile trusts the PEM-encoded CA certificates in file, on top of
+// the system roots.
+func WithCACertFile(file string) Option {
+	return func(c *config) error {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("reading CA bundle: %w", err)
+		}
+
+		cfg := c.tlsConfig()
+		if cfg.RootCAs == nil {
+			pool, err := x509.SystemCertPool()
+			if err != nil {
+				pool = x509.NewCertPool()
+			}
+			cfg.RootCAs = pool
+		}
+		if !cfg.RootCAs.AppendCertsFromPEM(data) {
+			return fmt.Errorf("no certificates found in %s", file)
+		}
+		return nil
+	}
+}
+
+// WithClientCertificate presents the given certificate for mutual TLS.
+func WithClientCertificate(certFile, keyFile string) Option {
+	return func(c *config) error {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return fmt.Errorf("loading client certificate: %w", err)
+		}
+		cfg := c.tlsConfig()
+		cfg.Certificates = append(cfg.Certificates, cert)
+		return nil
+	}
+}
+
+// WithServerName overrides the name used to verify the server certificate,
+// for hosts reached through an address that is not on the certificate.
+func WithServerName(name string) Option {
+	return func(c *config) error {
+		if name == "" {
+			return fmt.Errorf("server name cannot be empty")
+		}
+		c.tlsConfig().ServerName = name
+		return nil
+	}
+}
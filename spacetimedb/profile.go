@@ -0,0 +1,241 @@
+package spacetimedb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/briheet/spacetime-goclient/internal/parse"
+)
+
+// Environment variables that locate the config file and pick a profile.
+const (
+	ConfigEnv  = "SPACETIME_CONFIG"
+	ProfileEnv = "SPACETIME_PROFILE"
+)
+
+// ErrNoProfile is returned when no profile is named and the config file
+// has no default_server.
+var ErrNoProfile = errors.New("no profile selected")
+
+// Config is a config file of named server profiles. It reads the spacetime
+// CLI's cli.toml as is, default_server, spacetimedb_token and the
+// [[server_configs]] entries, and adds keys of its own to each entry:
+//
+//	default_server = "local"
+//
+//	[[server_configs]]
+//	nickname = "local"
+//	host = "127.0.0.1:3000"
+//	protocol = "http"
+//
+//	[[server_configs]]
+//	nickname = "prod"
+//	url = "https://spacetime.example.com"
+//	database = "quickstart-chat"
+//	token = "..."
+//	ca_cert = "certs/internal-ca.pem"
+//
+// Keys it does not know, such as ecdsa_public_key, are ignored.
+type Config struct {
+	DefaultServer string `json:"default_server"`
+	// Token is the spacetime CLI's login token, used by profiles without
+	// one of their own.
+	Token    string    `json:"spacetimedb_token"`
+	Profiles []Profile `json:"server_configs"`
+
+	// Path is the file the config was loaded from. Certificate paths in
+	// profiles are relative to its directory.
+	Path string `json:"-"`
+}
+
+// Profile is one named server.
+type Profile struct {
+	Name string `json:"nickname"`
+	// Host and Protocol are the spacetime CLI's way of naming a server,
+	// "127.0.0.1:3000" and "http". URL, if set, takes precedence and may
+	// carry a path prefix.
+	Host     string `json:"host"`
+	Protocol string `json:"protocol"`
+	URL      string `json:"url"`
+
+	Database string `json:"database"`
+	Token    string `json:"token"`
+	Identity string `json:"identity"`
+
+	CACert     string `json:"ca_cert"`
+	ClientCert string `json:"client_cert"`
+	ClientKey  string `json:"client_key"`
+	ServerName string `json:"server_name"`
+}
+
+// DefaultConfigPath returns $SPACETIME_CONFIG, or the spacetime CLI's
+// config file, ~/.config/spacetime/cli.toml on every platform.
+func DefaultConfigPath() string {
+	if path := os.Getenv(ConfigEnv); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "spacetime", "cli.toml")
+}
+
+// LoadConfig reads a config file, DefaultConfigPath if path is empty. Files
+// ending in .yaml, .yml or .json are read as such, anything else as TOML.
+func LoadConfig(path string) (*Config, error) {
+	if path == "" {
+		path = DefaultConfigPath()
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config: %w", err)
+	}
+
+	var doc any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		doc, err = parse.YAML(data)
+	case ".json":
+		err = json.Unmarshal(data, &doc)
+	default:
+		doc, err = parse.TOML(data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config %s: %w", path, err)
+	}
+
+	encoded, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	var cfg Config
+	if err := json.Unmarshal(encoded, &cfg); err != nil {
+		return nil, fmt.Errorf("config %s: %w", path, err)
+	}
+	cfg.Path = path
+
+	seen := map[string]bool{}
+	for i, p := range cfg.Profiles {
+		if p.Name == "" {
+			return nil, fmt.Errorf("config %s: server %d has no nickname", path, i+1)
+		}
+		if seen[p.Name] {
+			return nil, fmt.Errorf("config %s: duplicate nickname %q", path, p.Name)
+		}
+		seen[p.Name] = true
+	}
+	return &cfg, nil
+}
+
+// Profile returns the named profile. An empty name picks $SPACETIME_PROFILE,
+// then the file's default_server. The result carries the config's token
+// when the profile has none, and certificate paths resolved against the
+// config file's directory.
+func (c *Config) Profile(name string) (*Profile, error) {
+	if name == "" {
+		name = os.Getenv(ProfileEnv)
+	}
+	if name == "" {
+		name = c.DefaultServer
+	}
+	if name == "" {
+		return nil, ErrNoProfile
+	}
+
+	for _, p := range c.Profiles {
+		if p.Name != name {
+			continue
+		}
+		if p.Token == "" {
+			p.Token = c.Token
+		}
+		for _, file := range []*string{&p.CACert, &p.ClientCert, &p.ClientKey} {
+			if *file != "" && !filepath.IsAbs(*file) && c.Path != "" {
+				*file = filepath.Join(filepath.Dir(c.Path), *file)
+			}
+		}
+		return &p, nil
+	}
+	return nil, fmt.Errorf("no profile named %q in %s", name, c.Path)
+}
+
+// ServerURL returns the profile's server as a URL.
+func (p *Profile) ServerURL() string {
+	if p.URL != "" {
+		return p.URL
+	}
+	if p.Host == "" {
+		return ""
+	}
+	protocol := p.Protocol
+	if protocol == "" {
+		protocol = "http"
+	}
+	return protocol + "://" + p.Host
+}
+
+// Options returns the options that connect to the profile's server. Options
+// passed to Connect after them take precedence.
+func (p *Profile) Options() []Option {
+	var opts []Option
+	if url := p.ServerURL(); url != "" {
+		opts = append(opts, WithURL(url))
+	}
+	if p.Database != "" {
+		opts = append(opts, WithDatabase(p.Database))
+	}
+	if p.Token != "" {
+		opts = append(opts, WithToken(p.Token))
+	}
+	if p.Identity != "" {
+		opts = append(opts, WithIdentity(p.Identity))
+	}
+	return append(opts, p.TLSOptions()...)
+}
+
+// TLSOptions returns just the profile's TLS settings.
+func (p *Profile) TLSOptions() []Option {
+	var opts []Option
+	if p.CACert != "" {
+		opts = append(opts, WithCACertFile(p.CACert))
+	}
+	if p.ClientCert != "" || p.ClientKey != "" {
+		opts = append(opts, WithClientCertificate(p.ClientCert, p.ClientKey))
+	}
+	if p.ServerName != "" {
+		opts = append(opts, WithServerName(p.ServerName))
+	}
+	return opts
+}
+
+// WithProfile connects with the named profile from the config file at
+// DefaultConfigPath. An empty name picks $SPACETIME_PROFILE, then the
+// file's default_server. Options after it override the profile's settings.
+func WithProfile(name string) Option {
+	return WithConfigFile("", name)
+}
+
+// WithConfigFile is WithProfile with an explicit config file path.
+func WithConfigFile(path, profile string) Option {
+	return func(c *config) error {
+		cfg, err := LoadConfig(path)
+		if err != nil {
+			return err
+		}
+		p, err := cfg.Profile(profile)
+		if err != nil {
+			return err
+		}
+		for _, opt := range p.Options() {
+			if err := opt(c); err != nil {
+				return fmt.Errorf("profile %s: %w", p.Name, err)
+			}
+		}
+		return nil
+	}
+}
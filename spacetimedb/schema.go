@@ -0,0 +1,423 @@
+package spacetimedb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type Schemas interface {
+	GetDatabaseSchema(nameOrIdentity string) (*ModuleSchema, error)
+}
+
+// ModuleSchema describes the tables and reducers a module exports, as
+// served by the schema endpoint in its v9 format.
+type ModuleSchema struct {
+	Tables   []TableDef
+	Reducers []ReducerDef
+}
+
+// TableDef is a table and its columns in declaration order.
+type TableDef struct {
+	Name    string
+	Columns []Field
+	// PrimaryKey holds the indexes of the primary key columns, if any.
+	PrimaryKey []int
+	Public     bool
+}
+
+// ReducerDef is a reducer and its parameters in call order.
+type ReducerDef struct {
+	Name   string
+	Params []Field
+	// Lifecycle is "Init", "OnConnect" or "OnDisconnect" for reducers the
+	// host calls itself, and empty for ordinary ones.
+	Lifecycle string
+}
+
+// Field is a named column, parameter, product element or sum variant.
+type Field struct {
+	Name string
+	Type *AlgebraicType
+}
+
+// Kinds of AlgebraicType.
+const (
+	KindProduct = "Product"
+	KindSum     = "Sum"
+	KindArray   = "Array"
+	KindRef     = "Ref"
+)
+
+// AlgebraicType is a SATS type. Kind is a primitive name such as "String",
+// "Bool" or "U32", or one of the composite kinds.
+type AlgebraicType struct {
+	Kind string
+	// Elements are the fields of a Product, Variants the cases of a Sum.
+	Elements []Field
+	Variants []Field
+	// Elem is the element type of an Array.
+	Elem *AlgebraicType
+	// Ref indexes the module typespace. Types returned by GetDatabaseSchema
+	// have every Ref resolved.
+	Ref int
+}
+
+// Special products the host uses for its built-in types.
+var specialTypes = map[string]string{
+	"__identity__":                          "Identity",
+	"__connection_id__":                     "ConnectionId",
+	"__timestamp_micros_since_unix_epoch__": "Timestamp",
+	"__time_duration_micros__":              "TimeDuration",
+}
+
+// Special returns "Identity", "ConnectionId", "Timestamp" or "TimeDuration"
+// for the host's built-in types, and "" otherwise.
+func (t *AlgebraicType) Special() string {
+	if t.Kind == KindProduct && len(t.Elements) == 1 {
+		return specialTypes[t.Elements[0].Name]
+	}
+	return ""
+}
+
+// Option returns the wrapped type if t is an Option, i.e. a sum of
+// "some" and "none".
+func (t *AlgebraicType) Option() (*AlgebraicType, bool) {
+	if t.Kind != KindSum || len(t.Variants) != 2 {
+		return nil, false
+	}
+	if t.Variants[0].Name != "some" || t.Variants[1].Name != "none" {
+		return nil, false
+	}
+	return t.Variants[0].Type, true
+}
+
+// String renders t the way it is written in a module, e.g. "String",
+// "Identity", "Vec<U8>", "Option<String>" or "(x: F32, y: F32)". A type
+// nested in itself, which resolved recursive types are, is shown as "...".
+func (t *AlgebraicType) String() string {
+	return t.string(map[*AlgebraicType]bool{})
+}
+
+func (t *AlgebraicType) string(outer map[*AlgebraicType]bool) string {
+	if t == nil {
+		return "?"
+	}
+	if special := t.Special(); special != "" {
+		return special
+	}
+	if outer[t] {
+		return "..."
+	}
+	outer[t] = true
+	defer delete(outer, t)
+
+	if inner, ok := t.Option(); ok {
+		return "Option<" + inner.string(outer) + ">"
+	}
+
+	switch t.Kind {
+	case KindArray:
+		return "Vec<" + t.Elem.string(outer) + ">"
+	case KindRef:
+		return fmt.Sprintf("&%d", t.Ref)
+	case KindProduct:
+		return "(" + fieldList(t.Elements, outer) + ")"
+	case KindSum:
+		return "enum { " + fieldList(t.Variants, outer) + " }"
+	default:
+		return t.Kind
+	}
+}
+
+// Equal reports whether t and u describe the same type, field names
+// included. Both should be resolved, as a Ref only equals the same Ref.
+// Recursive types are equal when their structure is.
+func (t *AlgebraicType) Equal(u *AlgebraicType) bool {
+	return equalTypes(t, u, map[[2]*AlgebraicType]bool{})
+}
+
+func equalTypes(t, u *AlgebraicType, seen map[[2]*AlgebraicType]bool) bool {
+	if t == u {
+		return true
+	}
+	if t == nil || u == nil || t.Kind != u.Kind {
+		return false
+	}
+	// A pair already being compared is assumed equal, so cycles end.
+	pair := [2]*AlgebraicType{t, u}
+	if seen[pair] {
+		return true
+	}
+	seen[pair] = true
+
+	switch t.Kind {
+	case KindProduct:
+		return equalFields(t.Elements, u.Elements, seen)
+	case KindSum:
+		return equalFields(t.Variants, u.Variants, seen)
+	case KindArray:
+		return equalTypes(t.Elem, u.Elem, seen)
+	case KindRef:
+		return t.Ref == u.Ref
+	}
+	return true
+}
+
+func equalFields(a, b []Field, seen map[[2]*AlgebraicType]bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Name != b[i].Name || !equalTypes(a[i].Type, b[i].Type, seen) {
+			return false
+		}
+	}
+	return true
+}
+
+func fieldList(fields []Field, outer map[*AlgebraicType]bool) string {
+	parts := make([]string, len(fields))
+	for i, f := range fields {
+		if f.Name == "" {
+			parts[i] = f.Type.string(outer)
+		} else {
+			parts[i] = f.Name + ": " + f.Type.string(outer)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// UnmarshalJSON decodes the SATS JSON form, e.g. {"String":[]},
+// {"Array":{"U8":[]}} or {"Product":{"elements":[...]}}.
+func (t *AlgebraicType) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("invalid algebraic type: %w", err)
+	}
+	if len(raw) != 1 {
+		return fmt.Errorf("invalid algebraic type: %s", data)
+	}
+
+	for kind, body := range raw {
+		*t = AlgebraicType{Kind: kind}
+		switch kind {
+		case KindProduct:
+			var p productJSON
+			if err := json.Unmarshal(body, &p); err != nil {
+				return err
+			}
+			t.Elements = p.fields()
+		case KindSum:
+			var s struct {
+				Variants []fieldJSON `json:"variants"`
+			}
+			if err := json.Unmarshal(body, &s); err != nil {
+				return err
+			}
+			t.Variants = productJSON{Elements: s.Variants}.fields()
+		case KindArray:
+			t.Elem = new(AlgebraicType)
+			if err := json.Unmarshal(body, t.Elem); err != nil {
+				return err
+			}
+		case KindRef:
+			if err := json.Unmarshal(body, &t.Ref); err != nil {
+				return fmt.Errorf("invalid type ref: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// fieldJSON is a named element, whose name is an Option<String>.
+type fieldJSON struct {
+	Name struct {
+		Some *string `json:"some"`
+	} `json:"name"`
+	AlgebraicType *AlgebraicType `json:"algebraic_type"`
+}
+
+type productJSON struct {
+	Elements []fieldJSON `json:"elements"`
+}
+
+func (p productJSON) fields() []Field {
+	out := make([]Field, len(p.Elements))
+	for i, e := range p.Elements {
+		if e.Name.Some != nil {
+			out[i].Name = *e.Name.Some
+		}
+		out[i].Type = e.AlgebraicType
+	}
+	return out
+}
+
+// Columns returns the result's columns in order, decoded from its schema.
+func (r SQLResult) Columns() ([]Field, error) {
+	data, err := json.Marshal(r.Schema)
+	if err != nil {
+		return nil, err
+	}
+	var p productJSON
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("invalid result schema: %w", err)
+	}
+	return p.fields(), nil
+}
+
+// Table returns the table called name.
+func (s *ModuleSchema) Table(name string) (*TableDef, bool) {
+	for i := range s.Tables {
+		if s.Tables[i].Name == name {
+			return &s.Tables[i], true
+		}
+	}
+	return nil, false
+}
+
+// Reducer returns the reducer called name.
+func (s *ModuleSchema) Reducer(name string) (*ReducerDef, bool) {
+	for i := range s.Reducers {
+		if s.Reducers[i].Name == name {
+			return &s.Reducers[i], true
+		}
+	}
+	return nil, false
+}
+
+// rawModuleDef is the subset of RawModuleDefV9 the client reads.
+type rawModuleDef struct {
+	Typespace struct {
+		Types []*AlgebraicType `json:"types"`
+	} `json:"typespace"`
+	Tables []struct {
+		Name           string                     `json:"name"`
+		ProductTypeRef int                        `json:"product_type_ref"`
+		PrimaryKey     []int                      `json:"primary_key"`
+		TableAccess    map[string]json.RawMessage `json:"table_access"`
+	} `json:"tables"`
+	Reducers []struct {
+		Name      string      `json:"name"`
+		Params    productJSON `json:"params"`
+		Lifecycle struct {
+			Some map[string]json.RawMessage `json:"some"`
+		} `json:"lifecycle"`
+	} `json:"reducers"`
+}
+
+// GetDatabaseSchema fetches the schema of the module a database runs.
+func (c *Client) GetDatabaseSchema(nameOrIdentity string) (*ModuleSchema, error) {
+	return c.getDatabaseSchema(context.Background(), nameOrIdentity)
+}
+
+func (c *Client) getDatabaseSchema(ctx context.Context, nameOrIdentity string) (*ModuleSchema, error) {
+	endpoint := withQuery(databasePath(nameOrIdentity, "schema"), url.Values{"version": {"9"}})
+	resp, err := c.HTTPClient.DoContext(ctx, "GET", endpoint, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get schema: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s", ErrDatabaseNotFound, nameOrIdentity)
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, string(body))
+	}
+
+	var raw rawModuleDef
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return nil, fmt.Errorf("failed to decode schema: %w", err)
+	}
+	return raw.resolve()
+}
+
+func (raw *rawModuleDef) resolve() (*ModuleSchema, error) {
+	types := raw.Typespace.Types
+	r := refResolver{types: types, done: map[*AlgebraicType]bool{}}
+	for _, t := range types {
+		if err := r.resolve(t); err != nil {
+			return nil, err
+		}
+	}
+
+	schema := &ModuleSchema{}
+	for _, t := range raw.Tables {
+		if t.ProductTypeRef < 0 || t.ProductTypeRef >= len(types) {
+			return nil, fmt.Errorf("table %s refers to unknown type %d", t.Name, t.ProductTypeRef)
+		}
+		_, public := t.TableAccess["Public"]
+		schema.Tables = append(schema.Tables, TableDef{
+			Name:       t.Name,
+			Columns:    types[t.ProductTypeRef].Elements,
+			PrimaryKey: t.PrimaryKey,
+			Public:     public,
+		})
+	}
+
+	for _, rd := range raw.Reducers {
+		params := rd.Params.fields()
+		for i := range params {
+			if err := r.swap(&params[i].Type); err != nil {
+				return nil, fmt.Errorf("reducer %s: %w", rd.Name, err)
+			}
+		}
+		def := ReducerDef{Name: rd.Name, Params: params}
+		for lifecycle := range rd.Lifecycle.Some {
+			def.Lifecycle = lifecycle
+		}
+		schema.Reducers = append(schema.Reducers, def)
+	}
+	return schema, nil
+}
+
+// refResolver replaces Ref types with the typespace entries they point to.
+// Entries are shared rather than copied, so recursive types stay finite.
+type refResolver struct {
+	types []*AlgebraicType
+	done  map[*AlgebraicType]bool
+}
+
+func (r refResolver) resolve(t *AlgebraicType) error {
+	if t == nil || r.done[t] {
+		return nil
+	}
+	r.done[t] = true
+
+	resolveField := func(fields []Field) error {
+		for i := range fields {
+			if err := r.swap(&fields[i].Type); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	switch t.Kind {
+	case KindProduct:
+		return resolveField(t.Elements)
+	case KindSum:
+		return resolveField(t.Variants)
+	case KindArray:
+		return r.swap(&t.Elem)
+	}
+	return nil
+}
+
+// swap resolves *t, replacing it if it is a Ref.
+func (r refResolver) swap(t **AlgebraicType) error {
+	if *t != nil && (*t).Kind == KindRef {
+		ref := (*t).Ref
+		if ref < 0 || ref >= len(r.types) {
+			return fmt.Errorf("unknown type ref %d", ref)
+		}
+		*t = r.types[ref]
+	}
+	return r.resolve(*t)
+}
@@ -0,0 +1,319 @@
+package spacetimedbtest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/briheet/spacetime-goclient/spacetimedb"
+)
+
+// Column describes a table column or reducer parameter. Type is a SATS type
+// name such as "String", "U32", "Bool", "Identity" or "Timestamp", a
+// composite written as "Vec<U8>" or "Option<String>", or the name of a type
+// registered with AddType or AddEnum.
+type Column struct {
+	Name string
+	Type string
+}
+
+// Table holds the rows of one table. Rows are positional, matching Columns.
+type Table struct {
+	db      *Database
+	id      int
+	Name    string
+	Columns []Column
+	// PrimaryKey lists the key columns the schema endpoint reports, so
+	// clients keying rows by it, such as spacetimedb.Cache, see updates.
+	PrimaryKey []int
+	rows       [][]any
+}
+
+// Insert appends a row. It is meant for seeding; rows inserted from a
+// reducer go through ReducerContext so subscribers see them.
+func (t *Table) Insert(row ...any) {
+	t.db.mu.Lock()
+	defer t.db.mu.Unlock()
+	t.rows = append(t.rows, row)
+}
+
+// Rows returns a copy of the table's rows.
+func (t *Table) Rows() [][]any {
+	t.db.mu.Lock()
+	defer t.db.mu.Unlock()
+	return slices.Clone(t.rows)
+}
+
+// ReducerFunc implements a reducer. args is the raw JSON body of the call.
+// Returning an error fails the call the way a reducer panic or Err does.
+type ReducerFunc func(ctx *ReducerContext, args json.RawMessage) error
+
+// ReducerContext gives a reducer access to its caller and the database.
+// Changes are only applied when the reducer returns nil.
+type ReducerContext struct {
+	Sender string
+	db     *Database
+	diffs  map[string]*tableDiff
+}
+
+type tableDiff struct {
+	inserts [][]any
+	deletes [][]any
+}
+
+func (ctx *ReducerContext) diff(table string) *tableDiff {
+	d, ok := ctx.diffs[table]
+	if !ok {
+		d = &tableDiff{}
+		ctx.diffs[table] = d
+	}
+	return d
+}
+
+// Insert adds a row to table.
+func (ctx *ReducerContext) Insert(table string, row ...any) error {
+	if _, ok := ctx.db.tables[table]; !ok {
+		return fmt.Errorf("no such table: %s", table)
+	}
+	d := ctx.diff(table)
+	d.inserts = append(d.inserts, row)
+	return nil
+}
+
+// Delete removes every row of table for which match returns true and
+// reports how many were removed.
+func (ctx *ReducerContext) Delete(table string, match func(row []any) bool) (int, error) {
+	t, ok := ctx.db.tables[table]
+	if !ok {
+		return 0, fmt.Errorf("no such table: %s", table)
+	}
+	d := ctx.diff(table)
+	n := 0
+	for _, row := range t.rows {
+		if match(row) {
+			d.deletes = append(d.deletes, row)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Rows returns the committed rows of table.
+func (ctx *ReducerContext) Rows(table string) [][]any {
+	if t, ok := ctx.db.tables[table]; ok {
+		return slices.Clone(t.rows)
+	}
+	return nil
+}
+
+// Log writes to the database's log, like the log macros in a module.
+func (ctx *ReducerContext) Log(level spacetimedb.LogLevel, message string) {
+	ctx.db.appendLogLocked(level, message)
+}
+
+// Database is a fake database with scriptable tables, reducers and logs.
+type Database struct {
+	srv      *Server
+	identity string
+	owner    string
+	program  string
+	names    []string
+
+	mu          sync.Mutex
+	tables      map[string]*Table
+	tableOrder  []string
+	types       map[string]namedType
+	typeOrder   []string
+	reducers    map[string]reducer
+	reducerList []string
+	energyUsed  uint64
+	logs        []logLine
+	logNotify   chan struct{}
+	subscribers map[*subscriber]struct{}
+}
+
+type logLine struct {
+	Level      string `json:"level"`
+	Ts         int64  `json:"ts"`
+	Target     string `json:"target"`
+	Filename   string `json:"filename"`
+	LineNumber uint32 `json:"line_number"`
+	Message    string `json:"message"`
+}
+
+func newDatabase(srv *Server, identity, owner string, program []byte) *Database {
+	return &Database{
+		srv:         srv,
+		identity:    identity,
+		owner:       owner,
+		program:     programHash(program),
+		tables:      map[string]*Table{},
+		types:       map[string]namedType{},
+		reducers:    map[string]reducer{},
+		logNotify:   make(chan struct{}),
+		subscribers: map[*subscriber]struct{}{},
+	}
+}
+
+func programHash(program []byte) string {
+	sum := sha256.Sum256(program)
+	return hex.EncodeToString(sum[:])
+}
+
+func (db *Database) Identity() string { return db.identity }
+
+func (db *Database) Owner() string { return db.owner }
+
+// Names returns the names registered for the database.
+func (db *Database) Names() []string {
+	db.srv.mu.Lock()
+	defer db.srv.mu.Unlock()
+	return slices.Clone(db.names)
+}
+
+// AddTable creates an empty table and returns it.
+func (db *Database) AddTable(name string, columns ...Column) *Table {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	t := &Table{db: db, id: len(db.tableOrder) + 4096, Name: name, Columns: columns}
+	db.tables[name] = t
+	db.tableOrder = append(db.tableOrder, name)
+	return t
+}
+
+// namedType is a type registered with AddType or AddEnum. For an enum,
+// fields are its variants.
+type namedType struct {
+	fields []Column
+	sum    bool
+}
+
+// AddType registers a named product type, a struct in the module, whose
+// name columns and reducer parameters can then use as their Type. Schemas
+// refer to it through a typespace Ref, as they do for a module's own types.
+func (db *Database) AddType(name string, fields ...Column) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if _, ok := db.types[name]; !ok {
+		db.typeOrder = append(db.typeOrder, name)
+	}
+	db.types[name] = namedType{fields: fields}
+}
+
+// AddEnum registers a named sum type, an enum in the module, like AddType.
+// A variant with an empty Type carries no payload.
+func (db *Database) AddEnum(name string, variants ...Column) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if _, ok := db.types[name]; !ok {
+		db.typeOrder = append(db.typeOrder, name)
+	}
+	db.types[name] = namedType{fields: variants, sum: true}
+}
+
+// Table returns the named table or nil.
+func (db *Database) Table(name string) *Table {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.tables[name]
+}
+
+type reducer struct {
+	fn     ReducerFunc
+	params []Column
+}
+
+// AddReducer registers fn under name. params describe its arguments in the
+// module schema; fn still receives them as raw JSON. Reducers named init,
+// client_connected or client_disconnected are listed as lifecycle reducers.
+func (db *Database) AddReducer(name string, fn ReducerFunc, params ...Column) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if _, ok := db.reducers[name]; !ok {
+		db.reducerList = append(db.reducerList, name)
+	}
+	db.reducers[name] = reducer{fn: fn, params: params}
+}
+
+// SetEnergyUsed sets the energy every reducer call reports and charges to
+// the database owner, failed calls included.
+func (db *Database) SetEnergyUsed(quanta uint64) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.energyUsed = quanta
+}
+
+// Log appends a record to the database's log.
+func (db *Database) Log(level spacetimedb.LogLevel, message string) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.appendLogLocked(level, message)
+}
+
+func (db *Database) appendLogLocked(level spacetimedb.LogLevel, message string) {
+	db.logs = append(db.logs, logLine{
+		Level:      level.String(),
+		Ts:         time.Now().UnixMicro(),
+		Target:     "spacetimedbtest",
+		Filename:   "src/lib.rs",
+		LineNumber: uint32(len(db.logs) + 1),
+		Message:    message,
+	})
+	close(db.logNotify)
+	db.logNotify = make(chan struct{})
+}
+
+// clearLocked drops every row, as publishing with clear=true does.
+func (db *Database) clearLocked() {
+	for _, t := range db.tables {
+		t.rows = nil
+	}
+}
+
+// callResult is the outcome of a reducer call.
+type callResult struct {
+	err     error
+	missing bool
+	diffs   map[string]*tableDiff
+	elapsed time.Duration
+	energy  uint64
+}
+
+// call runs a reducer and applies its changes on success.
+func (db *Database) call(sender, reducer string, args json.RawMessage) callResult {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	rd, ok := db.reducers[reducer]
+	if !ok {
+		return callResult{missing: true, err: fmt.Errorf("no such reducer: %s", reducer)}
+	}
+
+	db.srv.charge(db.owner, db.energyUsed)
+
+	start := time.Now()
+	ctx := &ReducerContext{Sender: sender, db: db, diffs: map[string]*tableDiff{}}
+	if err := rd.fn(ctx, args); err != nil {
+		return callResult{err: err, elapsed: time.Since(start), energy: db.energyUsed}
+	}
+
+	for name, d := range ctx.diffs {
+		t := db.tables[name]
+		t.rows = slices.DeleteFunc(t.rows, func(row []any) bool {
+			return slices.ContainsFunc(d.deletes, func(del []any) bool { return sameRow(row, del) })
+		})
+		t.rows = append(t.rows, d.inserts...)
+	}
+
+	return callResult{diffs: ctx.diffs, elapsed: time.Since(start), energy: db.energyUsed}
+}
+
+func sameRow(a, b []any) bool {
+	x, _ := json.Marshal(a)
+	y, _ := json.Marshal(b)
+	return string(x) == string(y)
+}
@@ -0,0 +1,372 @@
+package spacetimedbtest
+
+import (
+	"context"
+	"io"
+	"math/big"
+	"slices"
+	"sync"
+
+	"github.com/briheet/spacetime-goclient/spacetimedb"
+	websocketsClient "github.com/briheet/spacetime-goclient/transport/websockets"
+	"github.com/gorilla/websocket"
+)
+
+var _ spacetimedb.DBClient = (*MockClient)(nil)
+
+// Call is one recorded invocation of a MockClient method.
+type Call struct {
+	Method string
+	Args   []any
+}
+
+// MockClient implements spacetimedb.DBClient with a hook per method. Methods
+// whose hook is nil return zero values. Every call is recorded, hook or not.
+type MockClient struct {
+	DisconnectFunc                   func() error
+	PingFunc                         func() error
+	CreateIdentityFunc               func() (string, string, error)
+	CreateIdentityWebsocketTokenFunc func() (string, error)
+	GetPublicKeyFunc                 func() (string, error)
+	RegisterIdentityWithEmailFunc    func(email string) (string, string, error)
+	GetDatabasesByIdentityFunc       func(identity string) ([]string, error)
+	VerifyIdentityTokenFunc          func(identity, token string) error
+	PublishDatabaseFunc              func(wasmFile string, token string) (string, string, error)
+	PublishNamedDatabaseFunc         func(nameOrIdentity string, wasmFile string, token string, clear bool) (string, string, *string, error)
+	GetDatabaseInfoFunc              func(nameOrIdentity string) (string, string, string, string, error)
+	DeleteDatabaseFunc               func(nameOrIdentity, token string) error
+	GetDatabaseNamesFunc             func(nameOrIdentity string) ([]string, error)
+	AddDatabaseNameFunc              func(nameOrIdentity, newName, token string) error
+	GetDatabaseIdentityFunc          func(nameOrIdentity string) (string, error)
+	SetDatabaseNamesFunc             func(nameOrIdentity string, names []string, token string) error
+	ReplaceDatabaseNameFunc          func(nameOrIdentity, oldName, newName, token string) error
+	RemoveDatabaseNameFunc           func(nameOrIdentity, name, token string) error
+	LookupDatabaseNameFunc           func(name string) (*spacetimedb.NameInfo, error)
+	GetDatabaseInventoryFunc         func(owner string) ([]spacetimedb.DatabaseNames, error)
+	GetDatabaseSchemaFunc            func(nameOrIdentity string) (*spacetimedb.ModuleSchema, error)
+	WebsocketSubscribeFunc           func(dbNameOrIden, token, protocol string) (*websocket.Conn, error)
+	OpenConnectionFunc               func(ctx context.Context, dbNameOrIden, token, protocol string) (*spacetimedb.Connection, error)
+	ReadWebsocketMessageFunc         func(conn *websocket.Conn) (int, []byte, error)
+	WebsocketStatsFunc               func() websocketsClient.Stats
+	GetDatabaseLogsFunc              func(dbNameOrIden string, token string, numLines int, follow bool) (io.ReadCloser, error)
+	RunSQLQueryFunc                  func(query, token, dbName string) ([]spacetimedb.SQLResult, error)
+	RunSQLQueryContextFunc           func(ctx context.Context, query, token, dbName string) ([]spacetimedb.SQLResult, error)
+	StreamDatabaseLogsFunc           func(ctx context.Context, dbNameOrIden, token string, opts spacetimedb.LogStreamOptions) (<-chan spacetimedb.LogRecord, <-chan error, error)
+	SendMessageDatabaseFunc          func(reducerName string, dbID string, token string, text string) error
+	GetEnergyBalanceFunc             func(identity string) (*big.Int, error)
+	CallReducerWithResultFunc        func(ctx context.Context, reducerName, dbID, token string, args any) (spacetimedb.ReducerResult, error)
+	EnergySpentFunc                  func() uint64
+	CallReducerFunc                  func(ctx context.Context, reducerName, dbID, token string, args any) error
+
+	mu    sync.Mutex
+	calls []Call
+}
+
+func (m *MockClient) record(method string, args ...any) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, Call{Method: method, Args: args})
+}
+
+// Calls returns every recorded call in order.
+func (m *MockClient) Calls() []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return slices.Clone(m.calls)
+}
+
+// CallsTo returns the recorded calls of one method.
+func (m *MockClient) CallsTo(method string) []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []Call
+	for _, c := range m.calls {
+		if c.Method == method {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// Reset forgets the recorded calls. Hooks are kept.
+func (m *MockClient) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = nil
+}
+
+func (m *MockClient) Disconnect() error {
+	m.record("Disconnect")
+	if m.DisconnectFunc != nil {
+		return m.DisconnectFunc()
+	}
+	return nil
+}
+
+func (m *MockClient) Ping() error {
+	m.record("Ping")
+	if m.PingFunc != nil {
+		return m.PingFunc()
+	}
+	return nil
+}
+
+func (m *MockClient) CreateIdentity() (string, string, error) {
+	m.record("CreateIdentity")
+	if m.CreateIdentityFunc != nil {
+		return m.CreateIdentityFunc()
+	}
+	return "", "", nil
+}
+
+func (m *MockClient) CreateIdentityWebsocketToken() (string, error) {
+	m.record("CreateIdentityWebsocketToken")
+	if m.CreateIdentityWebsocketTokenFunc != nil {
+		return m.CreateIdentityWebsocketTokenFunc()
+	}
+	return "", nil
+}
+
+func (m *MockClient) GetPublicKey() (string, error) {
+	m.record("GetPublicKey")
+	if m.GetPublicKeyFunc != nil {
+		return m.GetPublicKeyFunc()
+	}
+	return "", nil
+}
+
+func (m *MockClient) RegisterIdentityWithEmail(email string) (string, string, error) {
+	m.record("RegisterIdentityWithEmail", email)
+	if m.RegisterIdentityWithEmailFunc != nil {
+		return m.RegisterIdentityWithEmailFunc(email)
+	}
+	return "", "", nil
+}
+
+func (m *MockClient) GetDatabasesByIdentity(identity string) ([]string, error) {
+	m.record("GetDatabasesByIdentity", identity)
+	if m.GetDatabasesByIdentityFunc != nil {
+		return m.GetDatabasesByIdentityFunc(identity)
+	}
+	return nil, nil
+}
+
+func (m *MockClient) VerifyIdentityToken(identity, token string) error {
+	m.record("VerifyIdentityToken", identity, token)
+	if m.VerifyIdentityTokenFunc != nil {
+		return m.VerifyIdentityTokenFunc(identity, token)
+	}
+	return nil
+}
+
+func (m *MockClient) PublishDatabase(wasmFile string, token string) (string, string, error) {
+	m.record("PublishDatabase", wasmFile, token)
+	if m.PublishDatabaseFunc != nil {
+		return m.PublishDatabaseFunc(wasmFile, token)
+	}
+	return "", "", nil
+}
+
+func (m *MockClient) PublishNamedDatabase(nameOrIdentity string, wasmFile string, token string, clear bool) (string, string, *string, error) {
+	m.record("PublishNamedDatabase", nameOrIdentity, wasmFile, token, clear)
+	if m.PublishNamedDatabaseFunc != nil {
+		return m.PublishNamedDatabaseFunc(nameOrIdentity, wasmFile, token, clear)
+	}
+	return "", "", nil, nil
+}
+
+func (m *MockClient) GetDatabaseInfo(nameOrIdentity string) (string, string, string, string, error) {
+	m.record("GetDatabaseInfo", nameOrIdentity)
+	if m.GetDatabaseInfoFunc != nil {
+		return m.GetDatabaseInfoFunc(nameOrIdentity)
+	}
+	return "", "", "", "", nil
+}
+
+func (m *MockClient) DeleteDatabase(nameOrIdentity, token string) error {
+	m.record("DeleteDatabase", nameOrIdentity, token)
+	if m.DeleteDatabaseFunc != nil {
+		return m.DeleteDatabaseFunc(nameOrIdentity, token)
+	}
+	return nil
+}
+
+func (m *MockClient) GetDatabaseNames(nameOrIdentity string) ([]string, error) {
+	m.record("GetDatabaseNames", nameOrIdentity)
+	if m.GetDatabaseNamesFunc != nil {
+		return m.GetDatabaseNamesFunc(nameOrIdentity)
+	}
+	return nil, nil
+}
+
+func (m *MockClient) AddDatabaseName(nameOrIdentity, newName, token string) error {
+	m.record("AddDatabaseName", nameOrIdentity, newName, token)
+	if m.AddDatabaseNameFunc != nil {
+		return m.AddDatabaseNameFunc(nameOrIdentity, newName, token)
+	}
+	return nil
+}
+
+func (m *MockClient) GetDatabaseIdentity(nameOrIdentity string) (string, error) {
+	m.record("GetDatabaseIdentity", nameOrIdentity)
+	if m.GetDatabaseIdentityFunc != nil {
+		return m.GetDatabaseIdentityFunc(nameOrIdentity)
+	}
+	return "", nil
+}
+
+func (m *MockClient) SetDatabaseNames(nameOrIdentity string, names []string, token string) error {
+	m.record("SetDatabaseNames", nameOrIdentity, names, token)
+	if m.SetDatabaseNamesFunc != nil {
+		return m.SetDatabaseNamesFunc(nameOrIdentity, names, token)
+	}
+	return nil
+}
+
+func (m *MockClient) ReplaceDatabaseName(nameOrIdentity, oldName, newName, token string) error {
+	m.record("ReplaceDatabaseName", nameOrIdentity, oldName, newName, token)
+	if m.ReplaceDatabaseNameFunc != nil {
+		return m.ReplaceDatabaseNameFunc(nameOrIdentity, oldName, newName, token)
+	}
+	return nil
+}
+
+func (m *MockClient) RemoveDatabaseName(nameOrIdentity, name, token string) error {
+	m.record("RemoveDatabaseName", nameOrIdentity, name, token)
+	if m.RemoveDatabaseNameFunc != nil {
+		return m.RemoveDatabaseNameFunc(nameOrIdentity, name, token)
+	}
+	return nil
+}
+
+func (m *MockClient) LookupDatabaseName(name string) (*spacetimedb.NameInfo, error) {
+	m.record("LookupDatabaseName", name)
+	if m.LookupDatabaseNameFunc != nil {
+		return m.LookupDatabaseNameFunc(name)
+	}
+	return nil, nil
+}
+
+func (m *MockClient) GetDatabaseInventory(owner string) ([]spacetimedb.DatabaseNames, error) {
+	m.record("GetDatabaseInventory", owner)
+	if m.GetDatabaseInventoryFunc != nil {
+		return m.GetDatabaseInventoryFunc(owner)
+	}
+	return nil, nil
+}
+
+func (m *MockClient) GetDatabaseSchema(nameOrIdentity string) (*spacetimedb.ModuleSchema, error) {
+	m.record("GetDatabaseSchema", nameOrIdentity)
+	if m.GetDatabaseSchemaFunc != nil {
+		return m.GetDatabaseSchemaFunc(nameOrIdentity)
+	}
+	return &spacetimedb.ModuleSchema{}, nil
+}
+
+func (m *MockClient) WebsocketSubscribe(dbNameOrIden, token, protocol string) (*websocket.Conn, error) {
+	m.record("WebsocketSubscribe", dbNameOrIden, token, protocol)
+	if m.WebsocketSubscribeFunc != nil {
+		return m.WebsocketSubscribeFunc(dbNameOrIden, token, protocol)
+	}
+	return nil, nil
+}
+
+func (m *MockClient) OpenConnection(ctx context.Context, dbNameOrIden, token, protocol string) (*spacetimedb.Connection, error) {
+	m.record("OpenConnection", ctx, dbNameOrIden, token, protocol)
+	if m.OpenConnectionFunc != nil {
+		return m.OpenConnectionFunc(ctx, dbNameOrIden, token, protocol)
+	}
+	return nil, nil
+}
+
+func (m *MockClient) ReadWebsocketMessage(conn *websocket.Conn) (int, []byte, error) {
+	m.record("ReadWebsocketMessage", conn)
+	if m.ReadWebsocketMessageFunc != nil {
+		return m.ReadWebsocketMessageFunc(conn)
+	}
+	return 0, nil, nil
+}
+
+func (m *MockClient) WebsocketStats() websocketsClient.Stats {
+	m.record("WebsocketStats")
+	if m.WebsocketStatsFunc != nil {
+		return m.WebsocketStatsFunc()
+	}
+	return websocketsClient.Stats{}
+}
+
+func (m *MockClient) GetDatabaseLogs(dbNameOrIden string, token string, numLines int, follow bool) (io.ReadCloser, error) {
+	m.record("GetDatabaseLogs", dbNameOrIden, token, numLines, follow)
+	if m.GetDatabaseLogsFunc != nil {
+		return m.GetDatabaseLogsFunc(dbNameOrIden, token, numLines, follow)
+	}
+	return nil, nil
+}
+
+func (m *MockClient) RunSQLQuery(query, token, dbName string) ([]spacetimedb.SQLResult, error) {
+	m.record("RunSQLQuery", query, token, dbName)
+	if m.RunSQLQueryFunc != nil {
+		return m.RunSQLQueryFunc(query, token, dbName)
+	}
+	return nil, nil
+}
+
+func (m *MockClient) RunSQLQueryContext(ctx context.Context, query, token, dbName string) ([]spacetimedb.SQLResult, error) {
+	m.record("RunSQLQueryContext", ctx, query, token, dbName)
+	if m.RunSQLQueryContextFunc != nil {
+		return m.RunSQLQueryContextFunc(ctx, query, token, dbName)
+	}
+	return nil, nil
+}
+
+func (m *MockClient) StreamDatabaseLogs(ctx context.Context, dbNameOrIden, token string, opts spacetimedb.LogStreamOptions) (<-chan spacetimedb.LogRecord, <-chan error, error) {
+	m.record("StreamDatabaseLogs", ctx, dbNameOrIden, token, opts)
+	if m.StreamDatabaseLogsFunc != nil {
+		return m.StreamDatabaseLogsFunc(ctx, dbNameOrIden, token, opts)
+	}
+	// An empty stream that has already ended, so ranging over it returns.
+	records, errs := make(chan spacetimedb.LogRecord), make(chan error)
+	close(records)
+	close(errs)
+	return records, errs, nil
+}
+
+func (m *MockClient) SendMessageDatabase(reducerName string, dbID string, token string, text string) error {
+	m.record("SendMessageDatabase", reducerName, dbID, token, text)
+	if m.SendMessageDatabaseFunc != nil {
+		return m.SendMessageDatabaseFunc(reducerName, dbID, token, text)
+	}
+	return nil
+}
+
+func (m *MockClient) CallReducer(ctx context.Context, reducerName, dbID, token string, args any) error {
+	m.record("CallReducer", ctx, reducerName, dbID, token, args)
+	if m.CallReducerFunc != nil {
+		return m.CallReducerFunc(ctx, reducerName, dbID, token, args)
+	}
+	return nil
+}
+
+func (m *MockClient) GetEnergyBalance(identity string) (*big.Int, error) {
+	m.record("GetEnergyBalance", identity)
+	if m.GetEnergyBalanceFunc != nil {
+		return m.GetEnergyBalanceFunc(identity)
+	}
+	return new(big.Int), nil
+}
+
+func (m *MockClient) CallReducerWithResult(ctx context.Context, reducerName, dbID, token string, args any) (spacetimedb.ReducerResult, error) {
+	m.record("CallReducerWithResult", ctx, reducerName, dbID, token, args)
+	if m.CallReducerWithResultFunc != nil {
+		return m.CallReducerWithResultFunc(ctx, reducerName, dbID, token, args)
+	}
+	return spacetimedb.ReducerResult{}, nil
+}
+
+func (m *MockClient) EnergySpent() uint64 {
+	m.record("EnergySpent")
+	if m.EnergySpentFunc != nil {
+		return m.EnergySpentFunc()
+	}
+	return 0
+}
@@ -0,0 +1,282 @@
+package spacetimedbtest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	httpClient "github.com/briheet/spacetime-goclient/transport/http"
+)
+
+// Interaction is one recorded request and its response.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+type RecordedRequest struct {
+	Method string `json:"method"`
+	// URL is the path and query, without scheme and host.
+	URL  string `json:"url"`
+	Body Body   `json:"body,omitempty"`
+}
+
+type RecordedResponse struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   Body        `json:"body,omitempty"`
+}
+
+// Body is stored as text when it is valid UTF-8 and as base64 otherwise, so
+// golden files stay readable while wasm uploads still round-trip.
+type Body []byte
+
+func (b Body) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(b) {
+		return json.Marshal(string(b))
+	}
+	return json.Marshal(map[string]string{"base64": base64.StdEncoding.EncodeToString(b)})
+}
+
+func (b *Body) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*b = Body(text)
+		return nil
+	}
+	var encoded struct {
+		Base64 string `json:"base64"`
+	}
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return fmt.Errorf("body must be a string or {\"base64\": ...}: %w", err)
+	}
+	raw, err := base64.StdEncoding.DecodeString(encoded.Base64)
+	if err != nil {
+		return err
+	}
+	*b = raw
+	return nil
+}
+
+// Recorder captures the exchanges made through its middleware so they can be
+// saved as a golden file and replayed with Replay. Request headers are not
+// recorded, and "token" fields in JSON response bodies, such as those of
+// CreateIdentity, are replaced with "redacted", so tokens never end up on
+// disk. Response bodies are captured as the client reads them, so streamed
+// bodies such as followed logs pass through unchanged; an interaction's body
+// is complete once the client has read it to the end or closed it.
+type Recorder struct {
+	path string
+
+	mu           sync.Mutex
+	interactions []Interaction
+}
+
+// NewRecorder records into the golden file at path once Save is called.
+func NewRecorder(path string) *Recorder {
+	return &Recorder{path: path}
+}
+
+// Middleware returns the middleware to install on the HTTP transport.
+func (r *Recorder) Middleware() httpClient.Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return httpClient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			reqBody, err := drain(&req.Body)
+			if err != nil {
+				return nil, err
+			}
+
+			resp, err := next.RoundTrip(req)
+			if err != nil {
+				return nil, err
+			}
+
+			header := resp.Header.Clone()
+			header.Del("Set-Cookie")
+			// Redaction can change the body's length.
+			header.Del("Content-Length")
+			r.mu.Lock()
+			i := len(r.interactions)
+			r.interactions = append(r.interactions, Interaction{
+				Request:  RecordedRequest{Method: req.Method, URL: req.URL.RequestURI(), Body: reqBody},
+				Response: RecordedResponse{Status: resp.StatusCode, Header: header},
+			})
+			r.mu.Unlock()
+
+			if resp.Body != nil && resp.Body != http.NoBody {
+				resp.Body = &teeBody{ReadCloser: resp.Body, done: func(data []byte) {
+					r.mu.Lock()
+					r.interactions[i].Response.Body = redactTokens(data)
+					r.mu.Unlock()
+				}}
+			}
+			return resp, nil
+		})
+	}
+}
+
+// teeBody copies what the client reads and hands it to done at EOF or Close.
+type teeBody struct {
+	io.ReadCloser
+	buf  bytes.Buffer
+	done func([]byte)
+	once sync.Once
+}
+
+func (b *teeBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.buf.Write(p[:n])
+	if err == io.EOF {
+		b.finish()
+	}
+	return n, err
+}
+
+func (b *teeBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.finish()
+	return err
+}
+
+func (b *teeBody) finish() {
+	b.once.Do(func() { b.done(bytes.Clone(b.buf.Bytes())) })
+}
+
+// redactTokens replaces the values of "token" fields in a JSON body, or in
+// each line of an NDJSON one. Other bodies are returned as they are.
+func redactTokens(data []byte) []byte {
+	if out, ok := redactJSON(data); ok {
+		return out
+	}
+	lines := bytes.Split(data, []byte("\n"))
+	changed := false
+	for i, line := range lines {
+		if out, ok := redactJSON(line); ok {
+			lines[i], changed = out, true
+		}
+	}
+	if !changed {
+		return data
+	}
+	return bytes.Join(lines, []byte("\n"))
+}
+
+// redactJSON reports whether data is JSON with a token to redact, and if so
+// returns it re-encoded with the token replaced.
+func redactJSON(data []byte) ([]byte, bool) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil || dec.More() {
+		return nil, false
+	}
+	if !redactValue(v) {
+		return nil, false
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return nil, false
+	}
+	return out, true
+}
+
+func redactValue(v any) bool {
+	changed := false
+	switch v := v.(type) {
+	case map[string]any:
+		for k, item := range v {
+			if strings.EqualFold(k, "token") || strings.HasSuffix(strings.ToLower(k), "_token") {
+				if _, ok := item.(string); ok {
+					v[k] = "redacted"
+					changed = true
+					continue
+				}
+			}
+			changed = redactValue(item) || changed
+		}
+	case []any:
+		for _, item := range v {
+			changed = redactValue(item) || changed
+		}
+	}
+	return changed
+}
+
+// Save writes the recorded interactions to the golden file.
+func (r *Recorder) Save() error {
+	r.mu.Lock()
+	data, err := json.MarshalIndent(r.interactions, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode interactions: %w", err)
+	}
+	return os.WriteFile(r.path, append(data, '\n'), 0o644)
+}
+
+// drain reads a body fully and replaces it with a replayable copy.
+func drain(body *io.ReadCloser) (Body, error) {
+	if *body == nil || *body == http.NoBody {
+		return nil, nil
+	}
+	data, err := io.ReadAll(*body)
+	(*body).Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read body: %w", err)
+	}
+	*body = io.NopCloser(bytes.NewReader(data))
+	return data, nil
+}
+
+// Replay returns a middleware that answers requests from the golden file at
+// path instead of sending them. Each recorded interaction is used once, in
+// the order requests arrive; a request with no matching interaction fails.
+func Replay(path string) (httpClient.Middleware, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read golden file: %w", err)
+	}
+	var interactions []Interaction
+	if err := json.Unmarshal(data, &interactions); err != nil {
+		return nil, fmt.Errorf("failed to parse golden file %s: %w", path, err)
+	}
+
+	var mu sync.Mutex
+	used := make([]bool, len(interactions))
+
+	return func(http.RoundTripper) http.RoundTripper {
+		return httpClient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			body, err := drain(&req.Body)
+			if err != nil {
+				return nil, err
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			for i, in := range interactions {
+				if used[i] || in.Request.Method != req.Method || in.Request.URL != req.URL.RequestURI() || !bytes.Equal(in.Request.Body, body) {
+					continue
+				}
+				used[i] = true
+				return &http.Response{
+					Status:        fmt.Sprintf("%d %s", in.Response.Status, http.StatusText(in.Response.Status)),
+					StatusCode:    in.Response.Status,
+					Proto:         "HTTP/1.1",
+					ProtoMajor:    1,
+					ProtoMinor:    1,
+					Header:        in.Response.Header.Clone(),
+					Body:          io.NopCloser(bytes.NewReader(in.Response.Body)),
+					ContentLength: int64(len(in.Response.Body)),
+					Request:       req,
+				}, nil
+			}
+			return nil, fmt.Errorf("no recorded interaction for %s %s", req.Method, req.URL.RequestURI())
+		})
+	}, nil
+}
@@ -0,0 +1,404 @@
+package spacetimedbtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+func (s *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /v1/ping", func(w http.ResponseWriter, r *http.Request) {})
+
+	mux.HandleFunc("POST /v1/identity", s.handleCreateIdentity)
+	mux.HandleFunc("POST /v1/identity/websocket-token", s.handleWebsocketToken)
+	mux.HandleFunc("GET /v1/identity/public-key", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pem-certificate-chain")
+		io.WriteString(w, PublicKey)
+	})
+	mux.HandleFunc("POST /v1/identity/{identity}/set-email", s.handleSetEmail)
+	mux.HandleFunc("GET /v1/identity/{identity}/databases", s.handleIdentityDatabases)
+	mux.HandleFunc("GET /v1/identity/{identity}/verify", s.handleVerify)
+
+	mux.HandleFunc("GET /v1/energy/{identity}", func(w http.ResponseWriter, r *http.Request) {
+		balance := s.EnergyBalance(r.PathValue("identity"))
+		writeJSON(w, map[string]string{"balance": strconv.FormatInt(balance, 10)})
+	})
+
+	mux.HandleFunc("POST /v1/database", s.handlePublish)
+	mux.HandleFunc("POST /v1/database/{name}", s.handlePublish)
+	mux.HandleFunc("GET /v1/database/{name}", s.handleInfo)
+	mux.HandleFunc("DELETE /v1/database/{name}", s.handleDelete)
+	mux.HandleFunc("GET /v1/database/{name}/names", s.handleGetNames)
+	mux.HandleFunc("POST /v1/database/{name}/names", s.handleAddName)
+	mux.HandleFunc("PUT /v1/database/{name}/names", s.handleSetNames)
+	mux.HandleFunc("GET /v1/database/{name}/identity", s.handleDatabaseIdentity)
+	mux.HandleFunc("GET /v1/database/{name}/subscribe", s.handleSubscribe)
+	mux.HandleFunc("GET /v1/database/{name}/logs", s.handleLogs)
+	mux.HandleFunc("GET /v1/database/{name}/schema", s.handleSchema)
+	mux.HandleFunc("POST /v1/database/{name}/sql", s.handleSQL)
+	mux.HandleFunc("POST /v1/database/{name}/call/{reducer}", s.handleCall)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if f := s.takeFailure(r); f != nil {
+			w.WriteHeader(f.status)
+			io.WriteString(w, f.body)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+type identityJSON struct {
+	Identity string `json:"__identity__"`
+}
+
+// database resolves the {name} path value, writing a 404 when it is unknown.
+func (s *Server) database(w http.ResponseWriter, r *http.Request) (*Database, bool) {
+	db := s.Database(r.PathValue("name"))
+	if db == nil {
+		http.Error(w, fmt.Sprintf("`%s` not found", r.PathValue("name")), http.StatusNotFound)
+		return nil, false
+	}
+	return db, true
+}
+
+// owner checks that the caller owns db, writing a 401 otherwise.
+func (s *Server) owner(w http.ResponseWriter, r *http.Request, db *Database) bool {
+	identity, ok := s.authIdentity(r)
+	if !ok {
+		http.Error(w, "missing or invalid token", http.StatusUnauthorized)
+		return false
+	}
+	if identity != db.owner {
+		http.Error(w, "identity does not own database", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+func (s *Server) handleCreateIdentity(w http.ResponseWriter, r *http.Request) {
+	identity, token := s.NewIdentity()
+	writeJSON(w, map[string]string{"identity": identity, "token": token})
+}
+
+func (s *Server) handleWebsocketToken(w http.ResponseWriter, r *http.Request) {
+	identity, ok := s.authIdentity(r)
+	if !ok {
+		http.Error(w, "missing or invalid token", http.StatusUnauthorized)
+		return
+	}
+	s.mu.Lock()
+	s.nextID++
+	token := fmt.Sprintf("ws-token-%d", s.nextID)
+	s.tokens[token] = identity
+	s.mu.Unlock()
+	writeJSON(w, map[string]string{"token": token})
+}
+
+func (s *Server) handleSetEmail(w http.ResponseWriter, r *http.Request) {
+	identity, ok := s.authIdentity(r)
+	if !ok || identity != r.PathValue("identity") {
+		http.Error(w, "token does not match identity", http.StatusUnauthorized)
+		return
+	}
+	s.mu.Lock()
+	s.emails[identity] = r.URL.Query().Get("email")
+	s.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleIdentityDatabases(w http.ResponseWriter, r *http.Request) {
+	owner := r.PathValue("identity")
+	s.mu.Lock()
+	addresses := []string{}
+	for id, db := range s.databases {
+		if db.owner == owner {
+			addresses = append(addresses, id)
+		}
+	}
+	s.mu.Unlock()
+	slices.Sort(addresses)
+	writeJSON(w, map[string][]string{"addresses": addresses})
+}
+
+func (s *Server) handleVerify(w http.ResponseWriter, r *http.Request) {
+	identity, ok := s.authIdentity(r)
+	switch {
+	case !ok:
+		w.WriteHeader(http.StatusUnauthorized)
+	case identity != r.PathValue("identity"):
+		w.WriteHeader(http.StatusBadRequest)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (s *Server) handlePublish(w http.ResponseWriter, r *http.Request) {
+	caller, ok := s.authIdentity(r)
+	if !ok {
+		http.Error(w, "missing or invalid token", http.StatusUnauthorized)
+		return
+	}
+	program, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	name := r.PathValue("name")
+
+	s.mu.Lock()
+	db := s.lookupLocked(name)
+	op := "updated"
+	switch {
+	case db == nil:
+		db = s.newDatabaseLocked(caller, program)
+		op = "created"
+		if name != "" {
+			s.names[name] = db.identity
+			db.names = append(db.names, name)
+		}
+	case db.owner != caller:
+		s.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(map[string]any{"PermissionDenied": map[string]string{"name": name}})
+		return
+	default:
+		db.program = programHash(program)
+	}
+	s.mu.Unlock()
+
+	if r.URL.Query().Get("clear") == "true" {
+		db.mu.Lock()
+		db.clearLocked()
+		db.mu.Unlock()
+	}
+
+	success := map[string]any{
+		"database_identity": db.identity,
+		"op":                op,
+	}
+	if name != "" {
+		success["domain"] = name
+	}
+	writeJSON(w, map[string]any{"Success": success})
+}
+
+func (s *Server) handleInfo(w http.ResponseWriter, r *http.Request) {
+	db, ok := s.database(w, r)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	program := db.program
+	s.mu.Unlock()
+	writeJSON(w, map[string]any{
+		"database_identity": identityJSON{db.identity},
+		"owner_identity":    identityJSON{db.owner},
+		"host_type":         map[string][]any{"Wasm": {}},
+		"initial_program":   program,
+	})
+}
+
+func (s *Server) handleDelete(w http.ResponseWriter, r *http.Request) {
+	db, ok := s.database(w, r)
+	if !ok || !s.owner(w, r, db) {
+		return
+	}
+	s.mu.Lock()
+	delete(s.databases, db.identity)
+	for _, name := range db.names {
+		delete(s.names, name)
+	}
+	s.mu.Unlock()
+}
+
+func (s *Server) handleGetNames(w http.ResponseWriter, r *http.Request) {
+	db, ok := s.database(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, map[string][]string{"names": db.Names()})
+}
+
+func (s *Server) handleAddName(w http.ResponseWriter, r *http.Request) {
+	db, ok := s.database(w, r)
+	if !ok || !s.owner(w, r, db) {
+		return
+	}
+	var name string
+	if err := json.NewDecoder(r.Body).Decode(&name); err != nil {
+		http.Error(w, "body must be a JSON string", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if other, taken := s.names[name]; taken && other != db.identity {
+		writeJSON(w, map[string]any{"PermissionDenied": map[string]string{"domain": name}})
+		return
+	}
+	if !slices.Contains(db.names, name) {
+		s.names[name] = db.identity
+		db.names = append(db.names, name)
+	}
+	writeJSON(w, map[string]any{"Success": map[string]string{"domain": name, "database_result": db.identity}})
+}
+
+func (s *Server) handleSetNames(w http.ResponseWriter, r *http.Request) {
+	db, ok := s.database(w, r)
+	if !ok || !s.owner(w, r, db) {
+		return
+	}
+	var names []string
+	if err := json.NewDecoder(r.Body).Decode(&names); err != nil {
+		http.Error(w, "body must be a JSON list of names", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, name := range names {
+		if other, taken := s.names[name]; taken && other != db.identity {
+			writeJSON(w, map[string]any{"PermissionDenied": map[string]string{"domain": name}})
+			return
+		}
+	}
+	for _, name := range db.names {
+		delete(s.names, name)
+	}
+	db.names = nil
+	for _, name := range names {
+		if !slices.Contains(db.names, name) {
+			s.names[name] = db.identity
+			db.names = append(db.names, name)
+		}
+	}
+	writeJSON(w, map[string]any{"Success": map[string]any{"domains": db.names, "database_result": db.identity}})
+}
+
+func (s *Server) handleDatabaseIdentity(w http.ResponseWriter, r *http.Request) {
+	db, ok := s.database(w, r)
+	if !ok {
+		return
+	}
+	io.WriteString(w, db.identity)
+}
+
+func (s *Server) handleLogs(w http.ResponseWriter, r *http.Request) {
+	db, ok := s.database(w, r)
+	if !ok || !s.owner(w, r, db) {
+		return
+	}
+	numLines, _ := strconv.Atoi(r.URL.Query().Get("num_lines"))
+	follow := r.URL.Query().Get("follow") == "true"
+
+	db.mu.Lock()
+	lines := db.logs
+	if numLines > 0 && len(lines) > numLines {
+		lines = lines[len(lines)-numLines:]
+	}
+	lines = slices.Clone(lines)
+	sent := len(db.logs)
+	notify := db.logNotify
+	db.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	enc := json.NewEncoder(w)
+	for _, line := range lines {
+		enc.Encode(line)
+	}
+	flusher, _ := w.(http.Flusher)
+	if flusher != nil {
+		flusher.Flush()
+	}
+
+	for follow {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-notify:
+		}
+
+		db.mu.Lock()
+		lines = slices.Clone(db.logs[sent:])
+		sent = len(db.logs)
+		notify = db.logNotify
+		db.mu.Unlock()
+
+		for _, line := range lines {
+			enc.Encode(line)
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
+
+func (s *Server) handleSQL(w http.ResponseWriter, r *http.Request) {
+	db, ok := s.database(w, r)
+	if !ok {
+		return
+	}
+	if _, ok := s.authIdentity(r); !ok {
+		http.Error(w, "missing or invalid token", http.StatusUnauthorized)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+
+	var results []sqlResult
+	for _, stmt := range strings.Split(string(body), ";") {
+		if strings.TrimSpace(stmt) == "" {
+			continue
+		}
+		res, err := db.query(stmt)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		results = append(results, res)
+	}
+	writeJSON(w, results)
+}
+
+func (s *Server) handleCall(w http.ResponseWriter, r *http.Request) {
+	db, ok := s.database(w, r)
+	if !ok {
+		return
+	}
+	caller, ok := s.authIdentity(r)
+	if !ok {
+		http.Error(w, "missing or invalid token", http.StatusUnauthorized)
+		return
+	}
+	args, _ := io.ReadAll(r.Body)
+
+	res := db.call(caller, r.PathValue("reducer"), args)
+	if !res.missing {
+		w.Header().Set("Spacetime-Energy-Used", strconv.FormatUint(res.energy, 10))
+		w.Header().Set("Spacetime-Execution-Duration-Micros", strconv.FormatInt(res.elapsed.Microseconds(), 10))
+	}
+	switch {
+	case res.missing:
+		http.Error(w, res.err.Error(), http.StatusNotFound)
+		return
+	case res.err != nil:
+		// SpacetimeDB reports a failed reducer with this non-standard code.
+		http.Error(w, res.err.Error(), 530)
+		return
+	}
+
+	db.broadcast(nil, caller, r.PathValue("reducer"), args, 0, res)
+}
@@ -0,0 +1,153 @@
+package spacetimedbtest
+
+import (
+	"net/http"
+	"strings"
+)
+
+// satsTypes encodes Column types in the SATS JSON form the host uses in
+// schemas. Types registered with AddType or AddEnum are written as Refs to
+// their typespace entries when refs is set, and inline otherwise.
+type satsTypes struct {
+	named map[string]namedType
+	refs  map[string]int
+}
+
+func (st satsTypes) typ(name string) any {
+	if inner, ok := strings.CutPrefix(name, "Vec<"); ok {
+		return map[string]any{"Array": st.typ(strings.TrimSuffix(inner, ">"))}
+	}
+	if inner, ok := strings.CutPrefix(name, "Option<"); ok {
+		return map[string]any{"Sum": map[string]any{"variants": []any{
+			st.element("some", strings.TrimSuffix(inner, ">")),
+			map[string]any{"name": map[string]string{"some": "none"}, "algebraic_type": unitType()},
+		}}}
+	}
+	if ref, ok := st.refs[name]; ok {
+		return map[string]any{"Ref": ref}
+	}
+	if t, ok := st.named[name]; ok {
+		return st.definition(t)
+	}
+
+	special := map[string][2]string{
+		"Identity":     {"__identity__", "U256"},
+		"ConnectionId": {"__connection_id__", "U128"},
+		"Timestamp":    {"__timestamp_micros_since_unix_epoch__", "I64"},
+		"TimeDuration": {"__time_duration_micros__", "I64"},
+	}
+	if s, ok := special[name]; ok {
+		return map[string]any{"Product": map[string]any{"elements": []any{st.element(s[0], s[1])}}}
+	}
+	return map[string][]any{name: {}}
+}
+
+// definition encodes the fields of a named type, or its variants if it
+// is an enum.
+func (st satsTypes) definition(t namedType) any {
+	if !t.sum {
+		return map[string]any{"Product": st.product(t.fields)}
+	}
+	variants := make([]any, 0, len(t.fields))
+	for _, v := range t.fields {
+		variant := map[string]any{"name": map[string]string{"some": v.Name}, "algebraic_type": unitType()}
+		if v.Type != "" {
+			variant["algebraic_type"] = st.typ(v.Type)
+		}
+		variants = append(variants, variant)
+	}
+	return map[string]any{"Sum": map[string]any{"variants": variants}}
+}
+
+func unitType() any {
+	return map[string]any{"Product": map[string]any{"elements": []any{}}}
+}
+
+func (st satsTypes) element(name, typeName string) map[string]any {
+	return map[string]any{
+		"name":           map[string]string{"some": name},
+		"algebraic_type": st.typ(typeName),
+	}
+}
+
+func (st satsTypes) product(columns []Column) map[string]any {
+	elements := make([]any, 0, len(columns))
+	for _, c := range columns {
+		elements = append(elements, st.element(c.Name, c.Type))
+	}
+	return map[string]any{"elements": elements}
+}
+
+// lifecycleReducers are the reducer names the schema reports as lifecycle
+// reducers, as modules conventionally name them.
+var lifecycleReducers = map[string]string{
+	"init":                "Init",
+	"client_connected":    "OnConnect",
+	"client_disconnected": "OnDisconnect",
+}
+
+// handleSchema serves the module definition in the v9 format, with one
+// typespace entry per table.
+func (s *Server) handleSchema(w http.ResponseWriter, r *http.Request) {
+	db, ok := s.database(w, r)
+	if !ok {
+		return
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	// Tables come first in the typespace, followed by the named types,
+	// which everything else refers to by Ref.
+	st := satsTypes{named: db.types, refs: map[string]int{}}
+	for i, name := range db.typeOrder {
+		st.refs[name] = len(db.tableOrder) + i
+	}
+
+	types := []any{}
+	tables := []any{}
+	for _, name := range db.tableOrder {
+		t := db.tables[name]
+		key := t.PrimaryKey
+		if key == nil {
+			key = []int{}
+		}
+		tables = append(tables, map[string]any{
+			"name":             name,
+			"product_type_ref": len(types),
+			"primary_key":      key,
+			"indexes":          []any{},
+			"constraints":      []any{},
+			"sequences":        []any{},
+			"schedule":         map[string]any{"none": []any{}},
+			"table_type":       map[string]any{"User": []any{}},
+			"table_access":     map[string]any{"Public": []any{}},
+		})
+		types = append(types, map[string]any{"Product": st.product(t.Columns)})
+	}
+	for _, name := range db.typeOrder {
+		types = append(types, st.definition(db.types[name]))
+	}
+
+	reducers := []any{}
+	for _, name := range db.reducerList {
+		lifecycle := map[string]any{"none": []any{}}
+		if hook, ok := lifecycleReducers[name]; ok {
+			lifecycle = map[string]any{"some": map[string]any{hook: []any{}}}
+		}
+		reducers = append(reducers, map[string]any{
+			"name":      name,
+			"params":    st.product(db.reducers[name].params),
+			"lifecycle": lifecycle,
+		})
+	}
+
+	writeJSON(w, map[string]any{
+		"typespace":          map[string]any{"types": types},
+		"tables":             tables,
+		"reducers":           reducers,
+		"types":              []any{},
+		"misc_exports":       []any{},
+		"row_level_security": []any{},
+	})
+}
@@ -0,0 +1,214 @@
+// Package spacetimedbtest provides an in-process fake SpacetimeDB server for
+// tests. It implements the REST routes and the subscribe websocket used by
+// the spacetimedb package, backed by tables, reducers and failures scripted
+// from the test.
+//
+//	srv := spacetimedbtest.NewServer()
+//	defer srv.Close()
+//
+//	db := srv.AddDatabase("quickstart-chat")
+//	db.AddTable("person", spacetimedbtest.Column{Name: "name", Type: "String"})
+//	db.Table("person").Insert("alice")
+//
+//	client, err := srv.Connect("quickstart-chat")
+package spacetimedbtest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+
+	"github.com/briheet/spacetime-goclient/spacetimedb"
+)
+
+// PublicKey is the PEM served by /v1/identity/public-key.
+const PublicKey = `-----BEGIN PUBLIC KEY-----
+MFkwEwYHKoZIzj0CAQYIKoZIzj0DAQcDQgAEc2BzcGFjZXRpbWVkYnRlc3QgZmFrZSBr
+ZXkgZm9yIHRlc3RzIG9ubHkgZG8gbm90IHVzZSBmb3IgYW55dGhpbmc=
+-----END PUBLIC KEY-----
+`
+
+// Server is a fake SpacetimeDB host. All methods are safe for concurrent use.
+type Server struct {
+	*httptest.Server
+
+	mu          sync.Mutex
+	nextID      int
+	tokens      map[string]string // token -> identity
+	emails      map[string]string // identity -> email
+	databases   map[string]*Database
+	names       map[string]string // name -> database identity
+	energy      map[string]int64  // identity -> balance
+	failures    []*failure
+	connection  int
+	ignorePings bool
+}
+
+type failure struct {
+	method string
+	path   string
+	status int
+	body   string
+	// remaining is the number of requests left to fail, -1 for all of them.
+	remaining int
+}
+
+// NewServer starts a fake server. Close it when done.
+func NewServer() *Server {
+	s := &Server{
+		tokens:    map[string]string{},
+		emails:    map[string]string{},
+		databases: map[string]*Database{},
+		names:     map[string]string{},
+		energy:    map[string]int64{},
+	}
+	s.Server = httptest.NewServer(s.routes())
+	return s
+}
+
+// Connect returns a client for this server, the same way applications get one.
+func (s *Server) Connect(dbName string, opts ...spacetimedb.Option) (spacetimedb.DBClient, error) {
+	opts = append([]spacetimedb.Option{spacetimedb.WithURL(s.URL), spacetimedb.WithDatabase(dbName)}, opts...)
+	return spacetimedb.Connect(context.Background(), opts...)
+}
+
+// SetEnergyBalance sets the energy balance of identity in quanta.
+func (s *Server) SetEnergyBalance(identity string, quanta int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.energy[identity] = quanta
+}
+
+// EnergyBalance returns the energy balance of identity. Reducer calls are
+// charged to the owner of the database they run in.
+func (s *Server) EnergyBalance(identity string) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.energy[identity]
+}
+
+func (s *Server) charge(identity string, quanta uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.energy[identity] -= int64(quanta)
+}
+
+// NewIdentity registers a fresh identity and returns it with its token.
+func (s *Server) NewIdentity() (identity, token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.newIdentityLocked()
+}
+
+func (s *Server) newIdentityLocked() (string, string) {
+	s.nextID++
+	identity := fmt.Sprintf("%064x", 0xc200_0000+s.nextID)
+	token := fmt.Sprintf("token-%d", s.nextID)
+	s.tokens[token] = identity
+	return identity, token
+}
+
+// Email returns the email registered for identity, if any.
+func (s *Server) Email(identity string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.emails[identity]
+}
+
+// AddDatabase creates an empty database owned by a fresh identity and
+// registers name for it, unless name is empty.
+func (s *Server) AddDatabase(name string) *Database {
+	owner, _ := s.NewIdentity()
+	return s.AddDatabaseOwnedBy(name, owner)
+}
+
+// AddDatabaseOwnedBy is like AddDatabase with an explicit owner identity.
+func (s *Server) AddDatabaseOwnedBy(name, owner string) *Database {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	db := s.newDatabaseLocked(owner, nil)
+	if name != "" {
+		s.names[name] = db.identity
+		db.names = append(db.names, name)
+	}
+	return db
+}
+
+func (s *Server) newDatabaseLocked(owner string, program []byte) *Database {
+	s.nextID++
+	db := newDatabase(s, fmt.Sprintf("%064x", 0xdb00_0000+s.nextID), owner, program)
+	s.databases[db.identity] = db
+	return db
+}
+
+// Database looks up a database by name or identity.
+func (s *Server) Database(nameOrIdentity string) *Database {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lookupLocked(nameOrIdentity)
+}
+
+func (s *Server) lookupLocked(nameOrIdentity string) *Database {
+	if id, ok := s.names[nameOrIdentity]; ok {
+		nameOrIdentity = id
+	}
+	return s.databases[nameOrIdentity]
+}
+
+// Fail makes every request matching method and path fail with status and
+// body until ClearFailures is called. An empty method matches any method.
+func (s *Server) Fail(method, path string, status int, body string) {
+	s.addFailure(method, path, status, body, -1)
+}
+
+// FailNext makes only the next n requests matching method and path fail.
+func (s *Server) FailNext(method, path string, n, status int, body string) {
+	s.addFailure(method, path, status, body, n)
+}
+
+func (s *Server) addFailure(method, path string, status int, body string, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, &failure{method: method, path: path, status: status, body: body, remaining: n})
+}
+
+// ClearFailures removes all scripted failures.
+func (s *Server) ClearFailures() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = nil
+}
+
+// takeFailure returns the scripted failure for r, if any, consuming it.
+func (s *Server) takeFailure(r *http.Request) *failure {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, f := range s.failures {
+		if f.path != r.URL.Path || (f.method != "" && f.method != r.Method) {
+			continue
+		}
+		if f.remaining > 0 {
+			f.remaining--
+			if f.remaining == 0 {
+				s.failures = append(s.failures[:i], s.failures[i+1:]...)
+			}
+		}
+		return f
+	}
+	return nil
+}
+
+// authIdentity returns the identity behind the request's bearer token.
+func (s *Server) authIdentity(r *http.Request) (string, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return "", false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	identity, ok := s.tokens[token]
+	return identity, ok
+}
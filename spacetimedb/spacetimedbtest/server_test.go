@@ -0,0 +1,271 @@
+package spacetimedbtest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/briheet/spacetime-goclient/spacetimedb"
+)
+
+// do sends a request to s and returns the status and body.
+func do(t *testing.T, s *Server, method, path, token, body string) (int, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, s.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(data)
+}
+
+func TestRoutes(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	owner, ownerToken := s.NewIdentity()
+	_, otherToken := s.NewIdentity()
+	db := s.AddDatabaseOwnedBy("chat", owner)
+	db.AddTable("person", Column{Name: "name", Type: "String"}).Insert("alice")
+	db.AddReducer("ok", func(ctx *ReducerContext, args json.RawMessage) error { return nil })
+	db.AddReducer("fail", func(ctx *ReducerContext, args json.RawMessage) error { return errors.New("boom") })
+	db.SetEnergyUsed(7)
+	db.Log(spacetimedb.LogLevelInfo, "hello")
+	s.AddDatabase("taken")
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		body   string
+		status int
+		want   string
+	}{
+		{"ping", "GET", "/v1/ping", "", "", 200, ""},
+		{"public key", "GET", "/v1/identity/public-key", "", "", 200, "BEGIN PUBLIC KEY"},
+		{"info", "GET", "/v1/database/chat", "", "", 200, db.Identity()},
+		{"info by identity", "GET", "/v1/database/" + db.Identity(), "", "", 200, `"owner_identity":{"__identity__":"` + owner + `"}`},
+		{"unknown database", "GET", "/v1/database/nope", "", "", 404, "`nope` not found"},
+		{"database identity", "GET", "/v1/database/chat/identity", "", "", 200, db.Identity()},
+		{"names", "GET", "/v1/database/chat/names", "", "", 200, `{"names":["chat"]}`},
+		{"add name", "POST", "/v1/database/chat/names", ownerToken, `"lobby"`, 200, `"Success"`},
+		{"add name not owner", "POST", "/v1/database/chat/names", otherToken, `"x"`, 401, "does not own"},
+		{"add name no token", "POST", "/v1/database/chat/names", "", `"x"`, 401, "missing or invalid token"},
+		{"add taken name", "POST", "/v1/database/chat/names", ownerToken, `"taken"`, 200, `"PermissionDenied"`},
+		{"add name bad body", "POST", "/v1/database/chat/names", ownerToken, `lobby`, 400, "JSON string"},
+		{"set names", "PUT", "/v1/database/chat/names", ownerToken, `["chat","lobby"]`, 200, `"domains":["chat","lobby"]`},
+		{"set taken name", "PUT", "/v1/database/chat/names", ownerToken, `["taken"]`, 200, `"PermissionDenied"`},
+		{"schema", "GET", "/v1/database/chat/schema", "", "", 200, `"name":"person"`},
+		{"sql", "POST", "/v1/database/chat/sql", otherToken, "SELECT * FROM person", 200, `"rows":[["alice"]]`},
+		{"sql no token", "POST", "/v1/database/chat/sql", "", "SELECT * FROM person", 401, ""},
+		{"sql unsupported", "POST", "/v1/database/chat/sql", otherToken, "DELETE FROM person", 400, "unsupported statement"},
+		{"call", "POST", "/v1/database/chat/call/ok", otherToken, "[]", 200, ""},
+		{"call no token", "POST", "/v1/database/chat/call/ok", "", "[]", 401, ""},
+		{"call failing reducer", "POST", "/v1/database/chat/call/fail", otherToken, "[]", 530, "boom"},
+		{"call unknown reducer", "POST", "/v1/database/chat/call/nope", otherToken, "[]", 404, "no such reducer"},
+		{"logs", "GET", "/v1/database/chat/logs", ownerToken, "", 200, `"message":"hello"`},
+		{"logs not owner", "GET", "/v1/database/chat/logs", otherToken, "", 401, "does not own"},
+		{"databases of identity", "GET", "/v1/identity/" + owner + "/databases", "", "", 200, `{"addresses":["` + db.Identity() + `"]}`},
+		{"energy", "GET", "/v1/energy/" + owner, "", "", 200, `"balance":"-14"`},
+		{"delete not owner", "DELETE", "/v1/database/chat", otherToken, "", 401, ""},
+		{"delete", "DELETE", "/v1/database/chat", ownerToken, "", 200, ""},
+		{"deleted", "GET", "/v1/database/lobby", "", "", 404, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, body := do(t, s, tt.method, tt.path, tt.token, tt.body)
+			if status != tt.status {
+				t.Fatalf("%s %s: status %d, want %d: %s", tt.method, tt.path, status, tt.status, body)
+			}
+			if !strings.Contains(body, tt.want) {
+				t.Errorf("%s %s: body %q, want it to contain %q", tt.method, tt.path, body, tt.want)
+			}
+		})
+	}
+}
+
+func TestPublish(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+	_, token := s.NewIdentity()
+	_, otherToken := s.NewIdentity()
+
+	program := func(name string) string {
+		status, body := do(t, s, "GET", "/v1/database/"+name, "", "")
+		if status != 200 {
+			t.Fatalf("info: status %d: %s", status, body)
+		}
+		var info struct {
+			InitialProgram string `json:"initial_program"`
+		}
+		if err := json.Unmarshal([]byte(body), &info); err != nil {
+			t.Fatal(err)
+		}
+		return info.InitialProgram
+	}
+
+	steps := []struct {
+		name   string
+		token  string
+		wasm   string
+		status int
+		want   string
+	}{
+		{"no token", "", "v1", 401, ""},
+		{"create", token, "v1", 200, `"op":"created"`},
+		{"update", token, "v2", 200, `"op":"updated"`},
+		{"not owner", otherToken, "v3", 401, `"PermissionDenied"`},
+	}
+	for _, step := range steps {
+		status, body := do(t, s, "POST", "/v1/database/app", step.token, step.wasm)
+		if status != step.status || !strings.Contains(body, step.want) {
+			t.Fatalf("%s: status %d, body %s; want %d with %q", step.name, status, body, step.status, step.want)
+		}
+		if status == 200 {
+			if got, want := program("app"), programHash([]byte(step.wasm)); got != want {
+				t.Errorf("%s: initial_program %s, want %s", step.name, got, want)
+			}
+		}
+	}
+
+	db := s.Database("app")
+	db.AddTable("t", Column{Name: "n", Type: "U32"}).Insert(1)
+	if status, body := do(t, s, "POST", "/v1/database/app?clear=true", token, "v2"); status != 200 {
+		t.Fatalf("clear: status %d: %s", status, body)
+	}
+	if rows := db.Table("t").Rows(); len(rows) != 0 {
+		t.Errorf("rows after clear = %v, want none", rows)
+	}
+}
+
+func TestProgramHash(t *testing.T) {
+	tests := []struct {
+		program string
+		want    string
+	}{
+		{"", "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"},
+		{"abc", "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+	}
+	for _, tt := range tests {
+		if got := programHash([]byte(tt.program)); got != tt.want {
+			t.Errorf("programHash(%q) = %s, want %s", tt.program, got, tt.want)
+		}
+	}
+
+	sum := sha256.Sum256([]byte("module"))
+	if got := programHash([]byte("module")); got != hex.EncodeToString(sum[:]) {
+		t.Errorf("programHash is not the hex SHA-256 of the program: %s", got)
+	}
+	if programHash([]byte("a")) == programHash([]byte("b")) {
+		t.Error("different programs hash alike")
+	}
+
+	s := NewServer()
+	defer s.Close()
+	if got := s.AddDatabase("seeded").program; got != programHash(nil) {
+		t.Errorf("seeded database program = %s, want the hash of no program", got)
+	}
+}
+
+func TestIdentities(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	create := func() (string, string) {
+		status, body := do(t, s, "POST", "/v1/identity", "", "")
+		if status != 200 {
+			t.Fatalf("create identity: status %d: %s", status, body)
+		}
+		var out struct{ Identity, Token string }
+		if err := json.Unmarshal([]byte(body), &out); err != nil {
+			t.Fatal(err)
+		}
+		if len(out.Identity) != 64 || out.Token == "" {
+			t.Fatalf("create identity = %+v, want a 64 digit identity and a token", out)
+		}
+		return out.Identity, out.Token
+	}
+	id1, tok1 := create()
+	id2, tok2 := create()
+	if id1 == id2 || tok1 == tok2 {
+		t.Fatalf("identities are not unique: %s/%s and %s/%s", id1, tok1, id2, tok2)
+	}
+
+	status, body := do(t, s, "POST", "/v1/identity/websocket-token", tok1, "")
+	if status != 200 {
+		t.Fatalf("websocket token: status %d: %s", status, body)
+	}
+	var ws struct{ Token string }
+	json.Unmarshal([]byte(body), &ws)
+	if ws.Token == "" || ws.Token == tok1 {
+		t.Fatalf("websocket token = %q, want a new token", ws.Token)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		status int
+	}{
+		{"verify", "GET", "/v1/identity/" + id1 + "/verify", tok1, 204},
+		{"verify websocket token", "GET", "/v1/identity/" + id1 + "/verify", ws.Token, 204},
+		{"verify other identity", "GET", "/v1/identity/" + id2 + "/verify", tok1, 400},
+		{"verify unknown token", "GET", "/v1/identity/" + id1 + "/verify", "forged", 401},
+		{"verify no token", "GET", "/v1/identity/" + id1 + "/verify", "", 401},
+		{"websocket token without token", "POST", "/v1/identity/websocket-token", "", 401},
+		{"set email", "POST", "/v1/identity/" + id1 + "/set-email?email=a@example.com", tok1, 204},
+		{"set email of other identity", "POST", "/v1/identity/" + id2 + "/set-email?email=b@example.com", tok1, 401},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status, body := do(t, s, tt.method, tt.path, tt.token, ""); status != tt.status {
+				t.Errorf("%s %s: status %d, want %d: %s", tt.method, tt.path, status, tt.status, body)
+			}
+		})
+	}
+	if got := s.Email(id1); got != "a@example.com" {
+		t.Errorf("Email(id1) = %q, want a@example.com", got)
+	}
+	if got := s.Email(id2); got != "" {
+		t.Errorf("Email(id2) = %q, want none", got)
+	}
+}
+
+func TestFailures(t *testing.T) {
+	s := NewServer()
+	defer s.Close()
+
+	s.FailNext("GET", "/v1/ping", 2, 503, "busy")
+	s.Fail("POST", "/v1/ping", 500, "down")
+	want := []int{503, 503, 200}
+	for i, status := range want {
+		if got, _ := do(t, s, "GET", "/v1/ping", "", ""); got != status {
+			t.Errorf("GET %d: status %d, want %d", i, got, status)
+		}
+	}
+	if got, body := do(t, s, "POST", "/v1/ping", "", ""); got != 500 || body != "down" {
+		t.Errorf("POST: status %d body %q, want 500 down", got, body)
+	}
+	s.ClearFailures()
+	if got, _ := do(t, s, "POST", "/v1/ping", "", ""); got != 405 {
+		t.Errorf("POST after ClearFailures: status %d, want 405", got)
+	}
+}
@@ -0,0 +1,131 @@
+package spacetimedbtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+type sqlResult struct {
+	Schema sqlSchema `json:"schema"`
+	Rows   [][]any   `json:"rows"`
+}
+
+type sqlSchema struct {
+	Elements []sqlElement `json:"elements"`
+}
+
+type sqlElement struct {
+	Name          map[string]string `json:"name"`
+	AlgebraicType any               `json:"algebraic_type"`
+}
+
+// query runs a single statement of the form
+//
+//	SELECT * | col, ... FROM table [WHERE col = literal]
+//
+// which is all the fake supports.
+func (db *Database) query(stmt string) (sqlResult, error) {
+	fields := strings.Fields(stmt)
+	if len(fields) < 4 || !strings.EqualFold(fields[0], "SELECT") {
+		return sqlResult{}, fmt.Errorf("unsupported statement: %s", strings.TrimSpace(stmt))
+	}
+
+	from := slices.IndexFunc(fields, func(f string) bool { return strings.EqualFold(f, "FROM") })
+	if from < 2 || from+1 >= len(fields) {
+		return sqlResult{}, fmt.Errorf("missing FROM clause: %s", strings.TrimSpace(stmt))
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	t, ok := db.tables[fields[from+1]]
+	if !ok {
+		return sqlResult{}, fmt.Errorf("no such table: `%s`", fields[from+1])
+	}
+
+	// Resolve the projection
+	var cols []int
+	projection := strings.Join(fields[1:from], " ")
+	if projection == "*" {
+		for i := range t.Columns {
+			cols = append(cols, i)
+		}
+	} else {
+		for _, name := range strings.Split(projection, ",") {
+			i := t.column(strings.TrimSpace(name))
+			if i < 0 {
+				return sqlResult{}, fmt.Errorf("no such column: `%s`", strings.TrimSpace(name))
+			}
+			cols = append(cols, i)
+		}
+	}
+
+	// Resolve the optional equality filter
+	match := func([]any) bool { return true }
+	if rest := fields[from+2:]; len(rest) > 0 {
+		cond := strings.Join(rest, " ")
+		if !strings.EqualFold(rest[0], "WHERE") {
+			return sqlResult{}, fmt.Errorf("unsupported clause: %s", cond)
+		}
+		col, lit, ok := strings.Cut(strings.TrimSpace(cond[len("WHERE"):]), "=")
+		if !ok {
+			return sqlResult{}, fmt.Errorf("only equality filters are supported: %s", cond)
+		}
+		i := t.column(strings.TrimSpace(col))
+		if i < 0 {
+			return sqlResult{}, fmt.Errorf("no such column: `%s`", strings.TrimSpace(col))
+		}
+		want := parseLiteral(strings.TrimSpace(lit))
+		match = func(row []any) bool { return sameValue(row[i], want) }
+	}
+
+	res := sqlResult{Rows: [][]any{}}
+	for _, i := range cols {
+		c := t.Columns[i]
+		res.Schema.Elements = append(res.Schema.Elements, sqlElement{
+			Name:          map[string]string{"some": c.Name},
+			AlgebraicType: satsTypes{named: db.types}.typ(c.Type),
+		})
+	}
+	for _, row := range t.rows {
+		if !match(row) {
+			continue
+		}
+		out := make([]any, len(cols))
+		for j, i := range cols {
+			out[j] = row[i]
+		}
+		res.Rows = append(res.Rows, out)
+	}
+	return res, nil
+}
+
+func (t *Table) column(name string) int {
+	return slices.IndexFunc(t.Columns, func(c Column) bool { return c.Name == name })
+}
+
+func parseLiteral(lit string) any {
+	if s, ok := strings.CutPrefix(lit, "'"); ok {
+		return strings.TrimSuffix(s, "'")
+	}
+	if b, err := strconv.ParseBool(lit); err == nil {
+		return b
+	}
+	if f, err := strconv.ParseFloat(lit, 64); err == nil {
+		return f
+	}
+	return lit
+}
+
+// sameValue compares through JSON so seeded ints match parsed floats.
+func sameValue(a, b any) bool {
+	var x, y any
+	ja, _ := json.Marshal(a)
+	jb, _ := json.Marshal(b)
+	json.Unmarshal(ja, &x)
+	json.Unmarshal(jb, &y)
+	return fmt.Sprint(x) == fmt.Sprint(y)
+}
@@ -0,0 +1,307 @@
+package spacetimedbtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Protocol is the websocket subprotocol the fake speaks.
+const Protocol = "v1.json.spacetimedb"
+
+var upgrader = websocket.Upgrader{
+	Subprotocols: []string{Protocol},
+	CheckOrigin:  func(*http.Request) bool { return true },
+}
+
+type subscriber struct {
+	identity     string
+	connectionID string
+
+	mu     sync.Mutex // serialises writes
+	conn   *websocket.Conn
+	tables []string
+}
+
+func (sub *subscriber) send(v any) error {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	return sub.conn.WriteJSON(v)
+}
+
+type tableUpdate struct {
+	TableID   int           `json:"table_id"`
+	TableName string        `json:"table_name"`
+	NumRows   int           `json:"num_rows"`
+	Updates   []queryUpdate `json:"updates"`
+}
+
+type queryUpdate struct {
+	Deletes []string `json:"deletes"`
+	Inserts []string `json:"inserts"`
+}
+
+func encodeRows(rows [][]any) []string {
+	out := make([]string, 0, len(rows))
+	for _, row := range rows {
+		b, _ := json.Marshal(row)
+		out = append(out, string(b))
+	}
+	return out
+}
+
+func durationMicros(d time.Duration) map[string]int64 {
+	return map[string]int64{"__time_duration_micros__": d.Microseconds()}
+}
+
+// IgnorePings stops websocket connections opened afterwards from answering
+// pings, the way a half-open connection behaves.
+func (s *Server) IgnorePings() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ignorePings = true
+}
+
+func (s *Server) pingsIgnored() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.ignorePings
+}
+
+// Disconnect closes every websocket connected to the database with a normal
+// close frame, the way the host does when it shuts the database down.
+func (db *Database) Disconnect() {
+	db.mu.Lock()
+	subs := make([]*subscriber, 0, len(db.subscribers))
+	for sub := range db.subscribers {
+		subs = append(subs, sub)
+	}
+	db.mu.Unlock()
+
+	msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	for _, sub := range subs {
+		sub.mu.Lock()
+		sub.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+		sub.mu.Unlock()
+	}
+}
+
+func (s *Server) handleSubscribe(w http.ResponseWriter, r *http.Request) {
+	db, ok := s.database(w, r)
+	if !ok {
+		return
+	}
+
+	// Anonymous connections get a fresh identity, like the real host.
+	identity, ok := s.authIdentity(r)
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		identity, token = s.NewIdentity()
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	if s.pingsIgnored() {
+		conn.SetPingHandler(func(string) error { return nil })
+	}
+
+	s.mu.Lock()
+	s.connection++
+	sub := &subscriber{identity: identity, connectionID: fmt.Sprintf("%032x", s.connection), conn: conn}
+	s.mu.Unlock()
+
+	db.mu.Lock()
+	db.subscribers[sub] = struct{}{}
+	db.mu.Unlock()
+	defer func() {
+		db.mu.Lock()
+		delete(db.subscribers, sub)
+		db.mu.Unlock()
+	}()
+
+	sub.send(map[string]any{"IdentityToken": map[string]any{
+		"identity":      identityJSON{identity},
+		"token":         token,
+		"connection_id": map[string]string{"__connection_id__": sub.connectionID},
+	}})
+
+	for {
+		var msg struct {
+			Subscribe *struct {
+				QueryStrings []string `json:"query_strings"`
+				RequestID    uint32   `json:"request_id"`
+			} `json:"Subscribe"`
+			CallReducer *struct {
+				Reducer   string `json:"reducer"`
+				Args      string `json:"args"`
+				RequestID uint32 `json:"request_id"`
+			} `json:"CallReducer"`
+			OneOffQuery *struct {
+				MessageID   string `json:"message_id"`
+				QueryString string `json:"query_string"`
+			} `json:"OneOffQuery"`
+		}
+		if err := conn.ReadJSON(&msg); err != nil {
+			return
+		}
+
+		switch {
+		case msg.Subscribe != nil:
+			db.subscribe(sub, msg.Subscribe.QueryStrings, msg.Subscribe.RequestID)
+		case msg.CallReducer != nil:
+			args := json.RawMessage(msg.CallReducer.Args)
+			res := db.call(identity, msg.CallReducer.Reducer, args)
+			if res.err != nil {
+				status := map[string]any{"Failed": res.err.Error()}
+				sub.send(transactionUpdate(sub, sub, status, identity, msg.CallReducer.Reducer, args, msg.CallReducer.RequestID, res))
+				continue
+			}
+			db.broadcast(sub, identity, msg.CallReducer.Reducer, args, msg.CallReducer.RequestID, res)
+		case msg.OneOffQuery != nil:
+			res, err := db.query(msg.OneOffQuery.QueryString)
+			reply := map[string]any{
+				"message_id":                    msg.OneOffQuery.MessageID,
+				"error":                         nil,
+				"tables":                        []any{},
+				"total_host_execution_duration": durationMicros(0),
+			}
+			if err != nil {
+				reply["error"] = err.Error()
+			} else {
+				table := strings.Fields(msg.OneOffQuery.QueryString)
+				name := table[slices.IndexFunc(table, func(f string) bool { return strings.EqualFold(f, "FROM") })+1]
+				reply["tables"] = []any{map[string]any{"table_name": name, "rows": encodeRows(res.Rows)}}
+			}
+			sub.send(map[string]any{"OneOffQueryResponse": reply})
+		}
+	}
+}
+
+// subscribe registers the tables named by queries, which must all have the
+// form "SELECT * FROM table", and sends their current rows.
+func (db *Database) subscribe(sub *subscriber, queries []string, requestID uint32) {
+	var tables []string
+	for _, q := range queries {
+		fields := strings.Fields(strings.TrimSuffix(strings.TrimSpace(q), ";"))
+		if len(fields) != 4 || !strings.EqualFold(fields[0], "SELECT") || fields[1] != "*" || !strings.EqualFold(fields[2], "FROM") {
+			sub.send(map[string]any{"SubscriptionError": map[string]any{
+				"request_id": requestID,
+				"error":      fmt.Sprintf("unsupported subscription query: %s", q),
+			}})
+			return
+		}
+		tables = append(tables, fields[3])
+	}
+
+	db.mu.Lock()
+	var updates []tableUpdate
+	for _, name := range tables {
+		t, ok := db.tables[name]
+		if !ok {
+			db.mu.Unlock()
+			sub.send(map[string]any{"SubscriptionError": map[string]any{
+				"request_id": requestID,
+				"error":      fmt.Sprintf("no such table: `%s`", name),
+			}})
+			return
+		}
+		updates = append(updates, tableUpdate{
+			TableID:   t.id,
+			TableName: name,
+			NumRows:   len(t.rows),
+			Updates:   []queryUpdate{{Deletes: []string{}, Inserts: encodeRows(t.rows)}},
+		})
+	}
+	sub.mu.Lock()
+	sub.tables = tables
+	sub.mu.Unlock()
+	db.mu.Unlock()
+
+	sub.send(map[string]any{"InitialSubscription": map[string]any{
+		"database_update":               map[string]any{"tables": updates},
+		"request_id":                    requestID,
+		"total_host_execution_duration": durationMicros(0),
+	}})
+}
+
+// broadcast sends the outcome of a committed reducer call to the calling
+// connection, if any, and to every subscriber whose tables changed.
+func (db *Database) broadcast(from *subscriber, caller, reducer string, args json.RawMessage, requestID uint32, res callResult) {
+	db.mu.Lock()
+	subs := make([]*subscriber, 0, len(db.subscribers))
+	for sub := range db.subscribers {
+		subs = append(subs, sub)
+	}
+	db.mu.Unlock()
+
+	for _, sub := range subs {
+		updates := db.tableUpdates(sub, res)
+		if sub != from && len(updates) == 0 {
+			continue
+		}
+		status := map[string]any{"Committed": map[string]any{"tables": updates}}
+		sub.send(transactionUpdate(from, sub, status, caller, reducer, args, requestID, res))
+	}
+}
+
+// tableUpdates filters the changes of a call down to the tables sub watches.
+func (db *Database) tableUpdates(sub *subscriber, res callResult) []tableUpdate {
+	sub.mu.Lock()
+	tables := sub.tables
+	sub.mu.Unlock()
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	updates := []tableUpdate{}
+	for _, name := range tables {
+		d, ok := res.diffs[name]
+		if !ok || len(d.inserts)+len(d.deletes) == 0 {
+			continue
+		}
+		updates = append(updates, tableUpdate{
+			TableID:   db.tables[name].id,
+			TableName: name,
+			NumRows:   len(d.inserts) + len(d.deletes),
+			Updates:   []queryUpdate{{Deletes: encodeRows(d.deletes), Inserts: encodeRows(d.inserts)}},
+		})
+	}
+	return updates
+}
+
+// transactionUpdate builds the message sent to sub about a call made over
+// the connection from, which is nil for calls made over HTTP. Only the
+// calling connection gets to see its request id.
+func transactionUpdate(from, sub *subscriber, status map[string]any, caller, reducer string, args json.RawMessage, requestID uint32, res callResult) map[string]any {
+	connectionID := strings.Repeat("0", 32)
+	if from != nil {
+		connectionID = from.connectionID
+	}
+	if sub != from {
+		requestID = 0
+	}
+
+	return map[string]any{"TransactionUpdate": map[string]any{
+		"status":               status,
+		"timestamp":            map[string]int64{"__timestamp_micros_since_unix_epoch__": time.Now().UnixMicro()},
+		"caller_identity":      identityJSON{caller},
+		"caller_connection_id": map[string]string{"__connection_id__": connectionID},
+		"reducer_call": map[string]any{
+			"reducer_name": reducer,
+			"reducer_id":   0,
+			"args":         string(args),
+			"request_id":   requestID,
+		},
+		"energy_quanta_used":            map[string]uint64{"quanta": res.energy},
+		"total_host_execution_duration": durationMicros(res.elapsed),
+	}}
+}
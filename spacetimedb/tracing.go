@@ -0,0 +1,481 @@
+package spacetimedb
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/big"
+	"slices"
+	"sync"
+
+	"github.com/briheet/spacetime-goclient/tracing"
+	websocketsClient "github.com/briheet/spacetime-goclient/transport/websockets"
+	"github.com/gorilla/websocket"
+)
+
+// WithTracing wraps client so every operation runs in a span named after the
+// method, e.g. "spacetimedb.RunSQLQuery". When client is a *Client, its HTTP
+// transport is also instrumented so each request gets a child span with
+// status code and body sizes, and reducer calls made over connections it
+// opens get a span that lasts until their TransactionUpdate arrives. client
+// itself is left untouched.
+func WithTracing(client DBClient, t tracing.Tracer, opts ...TracingOption) DBClient {
+	traced := &tracedClient{next: client, calls: plainClient{client}, tracer: t}
+	if c, ok := client.(*Client); ok && c.HTTPClient != nil {
+		hc := *c.HTTPClient
+		hc.Middleware = append(slices.Clone(hc.Middleware), tracing.HTTPMiddleware(t))
+		cp := *c
+		cp.HTTPClient = &hc
+		traced.next = &cp
+		traced.calls = &cp
+	}
+	for _, opt := range opts {
+		opt(traced)
+	}
+	return traced
+}
+
+// TracingOption configures WithTracing.
+type TracingOption func(*tracedClient)
+
+// TraceQueryText records the text of SQL queries on their spans as
+// db.query.text. Queries may embed user data, so it is off by default.
+func TraceQueryText() TracingOption {
+	return func(t *tracedClient) { t.queryText = true }
+}
+
+var _ DBClient = (*tracedClient)(nil)
+
+type tracedClient struct {
+	next      DBClient
+	calls     contextClient
+	tracer    tracing.Tracer
+	queryText bool
+}
+
+// contextClient is implemented by *Client: the DBClient methods that take
+// no context, taking one, so the HTTP spans of a call become children of
+// the operation's span.
+type contextClient interface {
+	ping(ctx context.Context) error
+	createIdentity(ctx context.Context) (string, string, error)
+	createIdentityWebsocketToken(ctx context.Context) (string, error)
+	getPublicKey(ctx context.Context) (string, error)
+	registerIdentityWithEmail(ctx context.Context, email string) (string, string, error)
+	getDatabasesByIdentity(ctx context.Context, identity string) ([]string, error)
+	verifyIdentityToken(ctx context.Context, identity, token string) error
+	publishDatabase(ctx context.Context, wasmFile string, token string) (string, string, error)
+	publishNamedDatabase(ctx context.Context, nameOrIdentity string, wasmFile string, token string, clear bool) (string, string, *string, error)
+	getDatabaseInfo(ctx context.Context, nameOrIdentity string) (string, string, string, string, error)
+	deleteDatabase(ctx context.Context, nameOrIdentity string, token string) error
+	getDatabaseNames(ctx context.Context, nameOrIdentity string) ([]string, error)
+	addDatabaseName(ctx context.Context, nameOrIdentity, newName, token string) error
+	getDatabaseIdentity(ctx context.Context, nameOrIdentity string) (string, error)
+	setDatabaseNames(ctx context.Context, nameOrIdentity string, names []string, token string) error
+	replaceDatabaseName(ctx context.Context, nameOrIdentity, oldName, newName, token string) error
+	removeDatabaseName(ctx context.Context, nameOrIdentity, name, token string) error
+	lookupDatabaseName(ctx context.Context, name string) (*NameInfo, error)
+	getDatabaseInventory(ctx context.Context, owner string) ([]DatabaseNames, error)
+	getDatabaseSchema(ctx context.Context, nameOrIdentity string) (*ModuleSchema, error)
+	getDatabaseLogs(ctx context.Context, dbNameOrIden string, token string, numLines int, follow bool) (io.ReadCloser, error)
+	sendMessageDatabase(ctx context.Context, reducerName string, dbID string, token string, text string) error
+	getEnergyBalance(ctx context.Context, identity string) (*big.Int, error)
+}
+
+var _ contextClient = (*Client)(nil)
+
+// plainClient runs those methods on any other DBClient, without a context.
+type plainClient struct{ DBClient }
+
+func (p plainClient) ping(_ context.Context) error {
+	return p.Ping()
+}
+
+func (p plainClient) createIdentity(_ context.Context) (string, string, error) {
+	return p.CreateIdentity()
+}
+
+func (p plainClient) createIdentityWebsocketToken(_ context.Context) (string, error) {
+	return p.CreateIdentityWebsocketToken()
+}
+
+func (p plainClient) getPublicKey(_ context.Context) (string, error) {
+	return p.GetPublicKey()
+}
+
+func (p plainClient) registerIdentityWithEmail(_ context.Context, email string) (string, string, error) {
+	return p.RegisterIdentityWithEmail(email)
+}
+
+func (p plainClient) getDatabasesByIdentity(_ context.Context, identity string) ([]string, error) {
+	return p.GetDatabasesByIdentity(identity)
+}
+
+func (p plainClient) verifyIdentityToken(_ context.Context, identity, token string) error {
+	return p.VerifyIdentityToken(identity, token)
+}
+
+func (p plainClient) publishDatabase(_ context.Context, wasmFile string, token string) (string, string, error) {
+	return p.PublishDatabase(wasmFile, token)
+}
+
+func (p plainClient) publishNamedDatabase(_ context.Context, nameOrIdentity string, wasmFile string, token string, clear bool) (string, string, *string, error) {
+	return p.PublishNamedDatabase(nameOrIdentity, wasmFile, token, clear)
+}
+
+func (p plainClient) getDatabaseInfo(_ context.Context, nameOrIdentity string) (string, string, string, string, error) {
+	return p.GetDatabaseInfo(nameOrIdentity)
+}
+
+func (p plainClient) deleteDatabase(_ context.Context, nameOrIdentity string, token string) error {
+	return p.DeleteDatabase(nameOrIdentity, token)
+}
+
+func (p plainClient) getDatabaseNames(_ context.Context, nameOrIdentity string) ([]string, error) {
+	return p.GetDatabaseNames(nameOrIdentity)
+}
+
+func (p plainClient) addDatabaseName(_ context.Context, nameOrIdentity, newName, token string) error {
+	return p.AddDatabaseName(nameOrIdentity, newName, token)
+}
+
+func (p plainClient) getDatabaseIdentity(_ context.Context, nameOrIdentity string) (string, error) {
+	return p.GetDatabaseIdentity(nameOrIdentity)
+}
+
+func (p plainClient) setDatabaseNames(_ context.Context, nameOrIdentity string, names []string, token string) error {
+	return p.SetDatabaseNames(nameOrIdentity, names, token)
+}
+
+func (p plainClient) replaceDatabaseName(_ context.Context, nameOrIdentity, oldName, newName, token string) error {
+	return p.ReplaceDatabaseName(nameOrIdentity, oldName, newName, token)
+}
+
+func (p plainClient) removeDatabaseName(_ context.Context, nameOrIdentity, name, token string) error {
+	return p.RemoveDatabaseName(nameOrIdentity, name, token)
+}
+
+func (p plainClient) lookupDatabaseName(_ context.Context, name string) (*NameInfo, error) {
+	return p.LookupDatabaseName(name)
+}
+
+func (p plainClient) getDatabaseInventory(_ context.Context, owner string) ([]DatabaseNames, error) {
+	return p.GetDatabaseInventory(owner)
+}
+
+func (p plainClient) getDatabaseSchema(_ context.Context, nameOrIdentity string) (*ModuleSchema, error) {
+	return p.GetDatabaseSchema(nameOrIdentity)
+}
+
+func (p plainClient) getDatabaseLogs(_ context.Context, dbNameOrIden string, token string, numLines int, follow bool) (io.ReadCloser, error) {
+	return p.GetDatabaseLogs(dbNameOrIden, token, numLines, follow)
+}
+
+func (p plainClient) sendMessageDatabase(_ context.Context, reducerName string, dbID string, token string, text string) error {
+	return p.SendMessageDatabase(reducerName, dbID, token, text)
+}
+
+func (p plainClient) getEnergyBalance(_ context.Context, identity string) (*big.Int, error) {
+	return p.GetEnergyBalance(identity)
+}
+
+// start opens a span for op and returns a function that ends it with the
+// operation's outcome.
+func (t *tracedClient) start(ctx context.Context, op string, attrs ...tracing.Attribute) (context.Context, func(error)) {
+	attrs = append(attrs, tracing.String(tracing.AttrOperation, op))
+	ctx, span := t.tracer.Start(ctx, "spacetimedb."+op, attrs...)
+	return ctx, func(err error) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetAttributes(tracing.String(tracing.AttrStatus, "error"))
+		} else {
+			span.SetAttributes(tracing.String(tracing.AttrStatus, "ok"))
+		}
+		span.End()
+	}
+}
+
+func (t *tracedClient) Disconnect() error {
+	_, end := t.start(context.Background(), "Disconnect")
+	err := t.next.Disconnect()
+	end(err)
+	return err
+}
+
+func (t *tracedClient) Ping() error {
+	ctx, end := t.start(context.Background(), "Ping")
+	err := t.calls.ping(ctx)
+	end(err)
+	return err
+}
+
+func (t *tracedClient) CreateIdentity() (string, string, error) {
+	ctx, end := t.start(context.Background(), "CreateIdentity")
+	identity, token, err := t.calls.createIdentity(ctx)
+	end(err)
+	return identity, token, err
+}
+
+func (t *tracedClient) CreateIdentityWebsocketToken() (string, error) {
+	ctx, end := t.start(context.Background(), "CreateIdentityWebsocketToken")
+	token, err := t.calls.createIdentityWebsocketToken(ctx)
+	end(err)
+	return token, err
+}
+
+func (t *tracedClient) GetPublicKey() (string, error) {
+	ctx, end := t.start(context.Background(), "GetPublicKey")
+	key, err := t.calls.getPublicKey(ctx)
+	end(err)
+	return key, err
+}
+
+func (t *tracedClient) RegisterIdentityWithEmail(email string) (string, string, error) {
+	ctx, end := t.start(context.Background(), "RegisterIdentityWithEmail")
+	identity, token, err := t.calls.registerIdentityWithEmail(ctx, email)
+	end(err)
+	return identity, token, err
+}
+
+func (t *tracedClient) GetDatabasesByIdentity(identity string) ([]string, error) {
+	ctx, end := t.start(context.Background(), "GetDatabasesByIdentity")
+	dbs, err := t.calls.getDatabasesByIdentity(ctx, identity)
+	end(err)
+	return dbs, err
+}
+
+func (t *tracedClient) VerifyIdentityToken(identity, token string) error {
+	ctx, end := t.start(context.Background(), "VerifyIdentityToken")
+	err := t.calls.verifyIdentityToken(ctx, identity, token)
+	end(err)
+	return err
+}
+
+func (t *tracedClient) PublishDatabase(wasmFile string, token string) (string, string, error) {
+	ctx, end := t.start(context.Background(), "PublishDatabase")
+	identity, op, err := t.calls.publishDatabase(ctx, wasmFile, token)
+	end(err)
+	return identity, op, err
+}
+
+func (t *tracedClient) PublishNamedDatabase(nameOrIdentity string, wasmFile string, token string, clear bool) (string, string, *string, error) {
+	ctx, end := t.start(context.Background(), "PublishNamedDatabase",
+		tracing.String(tracing.AttrDatabase, nameOrIdentity),
+		tracing.Bool("spacetimedb.clear", clear),
+	)
+	identity, op, domain, err := t.calls.publishNamedDatabase(ctx, nameOrIdentity, wasmFile, token, clear)
+	end(err)
+	return identity, op, domain, err
+}
+
+func (t *tracedClient) GetDatabaseInfo(nameOrIdentity string) (string, string, string, string, error) {
+	ctx, end := t.start(context.Background(), "GetDatabaseInfo", tracing.String(tracing.AttrDatabase, nameOrIdentity))
+	identity, owner, hostType, program, err := t.calls.getDatabaseInfo(ctx, nameOrIdentity)
+	end(err)
+	return identity, owner, hostType, program, err
+}
+
+func (t *tracedClient) DeleteDatabase(nameOrIdentity, token string) error {
+	ctx, end := t.start(context.Background(), "DeleteDatabase", tracing.String(tracing.AttrDatabase, nameOrIdentity))
+	err := t.calls.deleteDatabase(ctx, nameOrIdentity, token)
+	end(err)
+	return err
+}
+
+func (t *tracedClient) GetDatabaseNames(nameOrIdentity string) ([]string, error) {
+	ctx, end := t.start(context.Background(), "GetDatabaseNames", tracing.String(tracing.AttrDatabase, nameOrIdentity))
+	names, err := t.calls.getDatabaseNames(ctx, nameOrIdentity)
+	end(err)
+	return names, err
+}
+
+func (t *tracedClient) AddDatabaseName(nameOrIdentity, newName, token string) error {
+	ctx, end := t.start(context.Background(), "AddDatabaseName", tracing.String(tracing.AttrDatabase, nameOrIdentity))
+	err := t.calls.addDatabaseName(ctx, nameOrIdentity, newName, token)
+	end(err)
+	return err
+}
+
+func (t *tracedClient) GetDatabaseIdentity(nameOrIdentity string) (string, error) {
+	ctx, end := t.start(context.Background(), "GetDatabaseIdentity", tracing.String(tracing.AttrDatabase, nameOrIdentity))
+	identity, err := t.calls.getDatabaseIdentity(ctx, nameOrIdentity)
+	end(err)
+	return identity, err
+}
+
+func (t *tracedClient) SetDatabaseNames(nameOrIdentity string, names []string, token string) error {
+	ctx, end := t.start(context.Background(), "SetDatabaseNames", tracing.String(tracing.AttrDatabase, nameOrIdentity))
+	err := t.calls.setDatabaseNames(ctx, nameOrIdentity, names, token)
+	end(err)
+	return err
+}
+
+func (t *tracedClient) ReplaceDatabaseName(nameOrIdentity, oldName, newName, token string) error {
+	ctx, end := t.start(context.Background(), "ReplaceDatabaseName", tracing.String(tracing.AttrDatabase, nameOrIdentity))
+	err := t.calls.replaceDatabaseName(ctx, nameOrIdentity, oldName, newName, token)
+	end(err)
+	return err
+}
+
+func (t *tracedClient) RemoveDatabaseName(nameOrIdentity, name, token string) error {
+	ctx, end := t.start(context.Background(), "RemoveDatabaseName", tracing.String(tracing.AttrDatabase, nameOrIdentity))
+	err := t.calls.removeDatabaseName(ctx, nameOrIdentity, name, token)
+	end(err)
+	return err
+}
+
+func (t *tracedClient) LookupDatabaseName(name string) (*NameInfo, error) {
+	ctx, end := t.start(context.Background(), "LookupDatabaseName", tracing.String(tracing.AttrDatabase, name))
+	info, err := t.calls.lookupDatabaseName(ctx, name)
+	end(err)
+	return info, err
+}
+
+func (t *tracedClient) GetDatabaseInventory(owner string) ([]DatabaseNames, error) {
+	ctx, end := t.start(context.Background(), "GetDatabaseInventory")
+	inventory, err := t.calls.getDatabaseInventory(ctx, owner)
+	end(err)
+	return inventory, err
+}
+
+func (t *tracedClient) GetDatabaseSchema(nameOrIdentity string) (*ModuleSchema, error) {
+	ctx, end := t.start(context.Background(), "GetDatabaseSchema", tracing.String(tracing.AttrDatabase, nameOrIdentity))
+	schema, err := t.calls.getDatabaseSchema(ctx, nameOrIdentity)
+	end(err)
+	return schema, err
+}
+
+func (t *tracedClient) WebsocketSubscribe(dbNameOrIden, token, protocol string) (*websocket.Conn, error) {
+	_, end := t.start(context.Background(), "WebsocketSubscribe",
+		tracing.String(tracing.AttrDatabase, dbNameOrIden),
+		tracing.String("spacetimedb.protocol", protocol),
+	)
+	conn, err := t.next.WebsocketSubscribe(dbNameOrIden, token, protocol)
+	end(err)
+	return conn, err
+}
+
+func (t *tracedClient) OpenConnection(ctx context.Context, dbNameOrIden, token, protocol string) (*Connection, error) {
+	ctx, end := t.start(ctx, "OpenConnection",
+		tracing.String(tracing.AttrDatabase, dbNameOrIden),
+		tracing.String("spacetimedb.protocol", protocol),
+	)
+	conn, err := t.next.OpenConnection(ctx, dbNameOrIden, token, protocol)
+	end(err)
+	if err == nil {
+		conn.trace(t.tracer)
+	}
+	return conn, err
+}
+
+func (t *tracedClient) ReadWebsocketMessage(conn *websocket.Conn) (int, []byte, error) {
+	return t.next.ReadWebsocketMessage(conn)
+}
+
+func (t *tracedClient) WebsocketStats() websocketsClient.Stats {
+	return t.next.WebsocketStats()
+}
+
+func (t *tracedClient) GetDatabaseLogs(dbNameOrIden string, token string, numLines int, follow bool) (io.ReadCloser, error) {
+	ctx, end := t.start(context.Background(), "GetDatabaseLogs", tracing.String(tracing.AttrDatabase, dbNameOrIden))
+	logs, err := t.calls.getDatabaseLogs(ctx, dbNameOrIden, token, numLines, follow)
+	if err != nil {
+		end(err)
+		return nil, err
+	}
+	// The span covers the whole stream, up to Close.
+	return &tracedBody{ReadCloser: logs, end: end}, nil
+}
+
+type tracedBody struct {
+	io.ReadCloser
+	end  func(error)
+	once sync.Once
+}
+
+func (b *tracedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() { b.end(nil) })
+	return err
+}
+
+func (t *tracedClient) RunSQLQuery(query, token, dbName string) ([]SQLResult, error) {
+	return t.RunSQLQueryContext(context.Background(), query, token, dbName)
+}
+
+func (t *tracedClient) RunSQLQueryContext(ctx context.Context, query, token, dbName string) ([]SQLResult, error) {
+	attrs := []tracing.Attribute{tracing.String(tracing.AttrDatabase, dbName)}
+	if t.queryText {
+		attrs = append(attrs, tracing.String("db.query.text", query))
+	}
+	ctx, end := t.start(ctx, "RunSQLQuery", attrs...)
+	results, err := t.next.RunSQLQueryContext(ctx, query, token, dbName)
+	end(err)
+	return results, err
+}
+
+func (t *tracedClient) StreamDatabaseLogs(ctx context.Context, dbNameOrIden, token string, opts LogStreamOptions) (<-chan LogRecord, <-chan error, error) {
+	ctx, end := t.start(ctx, "StreamDatabaseLogs", tracing.String(tracing.AttrDatabase, dbNameOrIden))
+	records, errs, err := t.next.StreamDatabaseLogs(ctx, dbNameOrIden, token, opts)
+	if err != nil {
+		end(err)
+		return nil, nil, err
+	}
+
+	// errs is closed last, once the stream is over, so the span ends then.
+	// A skipped line does not fail the span.
+	out := make(chan error, cap(errs))
+	go func() {
+		defer close(out)
+		var streamErr error
+		for err := range errs {
+			if !errors.Is(err, ErrInvalidLogLine) {
+				streamErr = err
+			}
+			out <- err
+		}
+		end(streamErr)
+	}()
+	return records, out, nil
+}
+
+func (t *tracedClient) SendMessageDatabase(reducerName string, dbID string, token string, text string) error {
+	ctx, end := t.start(context.Background(), "SendMessageDatabase",
+		tracing.String(tracing.AttrDatabase, dbID),
+		tracing.String(tracing.AttrReducer, reducerName),
+	)
+	err := t.calls.sendMessageDatabase(ctx, reducerName, dbID, token, text)
+	end(err)
+	return err
+}
+
+func (t *tracedClient) GetEnergyBalance(identity string) (*big.Int, error) {
+	ctx, end := t.start(context.Background(), "GetEnergyBalance")
+	balance, err := t.calls.getEnergyBalance(ctx, identity)
+	end(err)
+	return balance, err
+}
+
+func (t *tracedClient) EnergySpent() uint64 {
+	return t.next.EnergySpent()
+}
+
+func (t *tracedClient) CallReducerWithResult(ctx context.Context, reducerName, dbID, token string, args any) (ReducerResult, error) {
+	ctx, end := t.start(ctx, "CallReducerWithResult",
+		tracing.String(tracing.AttrDatabase, dbID),
+		tracing.String(tracing.AttrReducer, reducerName),
+	)
+	result, err := t.next.CallReducerWithResult(ctx, reducerName, dbID, token, args)
+	end(err)
+	return result, err
+}
+
+func (t *tracedClient) CallReducer(ctx context.Context, reducerName, dbID, token string, args any) error {
+	ctx, end := t.start(ctx, "CallReducer",
+		tracing.String(tracing.AttrDatabase, dbID),
+		tracing.String(tracing.AttrReducer, reducerName),
+	)
+	err := t.next.CallReducer(ctx, reducerName, dbID, token, args)
+	end(err)
+	return err
+}
@@ -0,0 +1,97 @@
+package spacetimedb_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/briheet/spacetime-goclient/spacetimedb"
+	"github.com/briheet/spacetime-goclient/tracing"
+)
+
+// recordingTracer records each span's name and the name of its parent.
+type recordingTracer struct {
+	mu    sync.Mutex
+	spans []string
+}
+
+type spanKey struct{}
+
+type recordedSpan struct{ name string }
+
+func (s *recordedSpan) SetAttributes(...tracing.Attribute) {}
+func (s *recordedSpan) RecordError(error)                  {}
+func (s *recordedSpan) End()                               {}
+
+func (r *recordingTracer) Start(ctx context.Context, name string, _ ...tracing.Attribute) (context.Context, tracing.Span) {
+	parent := "root"
+	if p, ok := ctx.Value(spanKey{}).(*recordedSpan); ok {
+		parent = p.name
+	}
+	r.mu.Lock()
+	r.spans = append(r.spans, fmt.Sprintf("%s > %s", parent, name))
+	r.mu.Unlock()
+	span := &recordedSpan{name: name}
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+func (r *recordingTracer) take() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	spans := r.spans
+	r.spans = nil
+	return spans
+}
+
+func TestTracingParentsHTTPSpans(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(f *fixture, c spacetimedb.DBClient) error
+		want []string
+	}{
+		{"ping", func(f *fixture, c spacetimedb.DBClient) error {
+			return c.Ping()
+		}, []string{"root > spacetimedb.Ping", "spacetimedb.Ping > HTTP GET"}},
+		{"calls made by a call", func(f *fixture, c spacetimedb.DBClient) error {
+			return c.ReplaceDatabaseName("chat", "chat", "lobby", f.token)
+		}, []string{
+			"root > spacetimedb.ReplaceDatabaseName",
+			"spacetimedb.ReplaceDatabaseName > HTTP GET",
+			"spacetimedb.ReplaceDatabaseName > HTTP PUT",
+		}},
+		{"schema fetched to validate a call", func(f *fixture, c spacetimedb.DBClient) error {
+			return c.CallReducer(context.Background(), "add", "chat", f.token, []any{"bob"})
+		}, []string{
+			"root > spacetimedb.CallReducer",
+			"spacetimedb.CallReducer > HTTP GET",
+			"spacetimedb.CallReducer > HTTP POST",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := newFixture(t, spacetimedb.WithArgValidation())
+			tracer := &recordingTracer{}
+			must(t, tt.run(f, spacetimedb.WithTracing(f.client, tracer)))
+			equal(t, "spans", tracer.take(), tt.want)
+		})
+	}
+}
+
+// A connection opened through a traced client must not hold on to the
+// context it was opened with.
+func TestTracedConnectionOutlivesOpenContext(t *testing.T) {
+	f := newFixture(t, spacetimedb.WithArgValidation())
+	c := spacetimedb.WithTracing(f.client, &recordingTracer{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	conn, err := c.OpenConnection(ctx, "chat", f.token, "")
+	must(t, err)
+	defer conn.Close()
+	cancel()
+
+	await(t, conn, `"IdentityToken"`)
+	// The call is validated first, which fetches the schema over HTTP.
+	_, err = conn.CallReducer(context.Background(), "add", []any{"bob"})
+	must(t, err)
+}
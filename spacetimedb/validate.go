@@ -0,0 +1,60 @@
+package spacetimedb
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// schemaCache keeps the schemas fetched to validate reducer calls, by the
+// database name or identity they were fetched with.
+type schemaCache struct {
+	mu      sync.Mutex
+	schemas map[string]*ModuleSchema
+}
+
+func (s *schemaCache) get(db string) (*ModuleSchema, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	schema, ok := s.schemas[db]
+	return schema, ok
+}
+
+func (s *schemaCache) put(db string, schema *ModuleSchema) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.schemas == nil {
+		s.schemas = map[string]*ModuleSchema{}
+	}
+	s.schemas[db] = schema
+}
+
+// reset drops every schema, as a publish may have changed any of them.
+func (s *schemaCache) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.schemas = nil
+}
+
+// checkArgs validates a reducer call when ValidateArgs is set and returns
+// the arguments to send, normalized to the form the host expects.
+// Otherwise args are sent as they are.
+func (c *Client) checkArgs(ctx context.Context, reducerName, dbID string, args any) (any, error) {
+	if !c.ValidateArgs {
+		return args, nil
+	}
+	schema, ok := c.schemas.get(dbID)
+	if !ok {
+		var err error
+		if schema, err = c.getDatabaseSchema(ctx, dbID); err != nil {
+			return nil, fmt.Errorf("validating %s: %w", reducerName, err)
+		}
+		c.schemas.put(dbID, schema)
+	}
+
+	rd, ok := schema.Reducer(reducerName)
+	if !ok {
+		return nil, fmt.Errorf("%w: database %s has no reducer %s", ErrInvalidArgs, dbID, reducerName)
+	}
+	return rd.normalizeArgs(args)
+}
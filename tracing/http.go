@@ -0,0 +1,73 @@
+package tracing
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+
+	httpClient "github.com/briheet/spacetime-goclient/transport/http"
+)
+
+// HTTPMiddleware starts a span for every request sent through the HTTP
+// transport. The span ends once the response body is fully read or closed,
+// so its response size covers streamed bodies too.
+func HTTPMiddleware(t Tracer) httpClient.Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return httpClient.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			ctx, span := t.Start(req.Context(), "HTTP "+req.Method,
+				String(AttrHTTPMethod, req.Method),
+				String(AttrURLPath, req.URL.Path),
+				Int64(AttrRequestBytes, max(req.ContentLength, 0)),
+			)
+
+			req = req.Clone(ctx)
+			if p, ok := t.(Propagator); ok {
+				p.Inject(ctx, req.Header)
+			}
+
+			resp, err := next.RoundTrip(req)
+			if err != nil {
+				span.RecordError(err)
+				span.End()
+				return nil, err
+			}
+
+			span.SetAttributes(Int(AttrHTTPStatusCode, resp.StatusCode))
+			if resp.StatusCode >= 400 {
+				span.RecordError(fmt.Errorf("status code %d", resp.StatusCode))
+			}
+			resp.Body = &countingBody{ReadCloser: resp.Body, span: span}
+			return resp, nil
+		})
+	}
+}
+
+type countingBody struct {
+	io.ReadCloser
+	span Span
+	n    int64
+	once sync.Once
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	if err == io.EOF {
+		b.end()
+	}
+	return n, err
+}
+
+func (b *countingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.end()
+	return err
+}
+
+func (b *countingBody) end() {
+	b.once.Do(func() {
+		b.span.SetAttributes(Int64(AttrResponseBytes, b.n))
+		b.span.End()
+	})
+}
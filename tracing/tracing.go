@@ -0,0 +1,71 @@
+// Package tracing defines the small tracing surface the client needs, so that
+// OpenTelemetry or any other tracer can be plugged in through an adapter
+// without the client depending on it.
+package tracing
+
+import (
+	"context"
+	"net/http"
+)
+
+// Tracer starts spans. An OpenTelemetry adapter wraps trace.Tracer.
+type Tracer interface {
+	Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span)
+}
+
+// Span is an in-flight operation.
+type Span interface {
+	SetAttributes(attrs ...Attribute)
+	RecordError(err error)
+	End()
+}
+
+// Propagator is optionally implemented by a Tracer to inject trace context
+// into outgoing request headers.
+type Propagator interface {
+	Inject(ctx context.Context, header http.Header)
+}
+
+// Attribute is a key/value pair attached to a span. Value is a string, int64,
+// bool or float64.
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+func String(key, value string) Attribute { return Attribute{Key: key, Value: value} }
+
+func Int(key string, value int) Attribute { return Attribute{Key: key, Value: int64(value)} }
+
+func Int64(key string, value int64) Attribute { return Attribute{Key: key, Value: value} }
+
+func Bool(key string, value bool) Attribute { return Attribute{Key: key, Value: value} }
+
+// Attribute keys used by the client.
+const (
+	AttrDatabase       = "spacetimedb.database"
+	AttrReducer        = "spacetimedb.reducer"
+	AttrOperation      = "spacetimedb.operation"
+	AttrStatus         = "spacetimedb.status"
+	AttrHTTPMethod     = "http.request.method"
+	AttrHTTPStatusCode = "http.response.status_code"
+	AttrURLPath        = "url.path"
+	AttrRequestBytes   = "http.request.body.size"
+	AttrResponseBytes  = "http.response.body.size"
+	AttrMessageBytes   = "messaging.message.body.size"
+)
+
+// Noop is a Tracer that records nothing.
+var Noop Tracer = noopTracer{}
+
+type noopTracer struct{}
+
+func (noopTracer) Start(ctx context.Context, _ string, _ ...Attribute) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(...Attribute) {}
+func (noopSpan) RecordError(error)          {}
+func (noopSpan) End()                       {}
@@ -1,15 +1,25 @@
 package httpClient
 
 import (
+	"bytes"
+	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptrace"
+	"sync/atomic"
 	"time"
 )
 
 type Client struct {
 	BaseURL    string
 	HTTPClient *http.Client
+	// Retry is nil unless WithRetry is used, in which case requests are
+	// retried according to the policy.
+	Retry *RetryPolicy
+	// Middleware wraps the transport of HTTPClient, see WithMiddleware.
+	Middleware []Middleware
 }
 
 type Option func(*Client) error
@@ -31,14 +41,50 @@ func WithTimeout(timeout time.Duration) Option {
 	}
 }
 
-// TODO: Custom checks needs to be done
+// WithRetry enables retries, see RetryPolicy for which requests qualify.
+func WithRetry(policy RetryPolicy) Option {
+	return func(c *Client) error {
+		if policy.MaxAttempts < 1 {
+			return fmt.Errorf("retry policy needs at least one attempt")
+		}
+		c.Retry = &policy
+		return nil
+	}
+}
+
+// WithTLSConfig sets the TLS configuration used for https requests. It
+// fails when the client's transport is not an *http.Transport, since the
+// config could not be applied without replacing that transport.
+func WithTLSConfig(cfg *tls.Config) Option {
+	return func(c *Client) error {
+		if cfg == nil {
+			return fmt.Errorf("TLS config cannot be nil")
+		}
+		var transport *http.Transport
+		switch t := c.HTTPClient.Transport.(type) {
+		case nil:
+			transport = http.DefaultTransport.(*http.Transport).Clone()
+		case *http.Transport:
+			transport = t.Clone()
+		default:
+			return fmt.Errorf("cannot set TLS config on a custom %T transport", t)
+		}
+		transport.TLSClientConfig = cfg
+		c.HTTPClient.Transport = transport
+		return nil
+	}
+}
+
 // WithCustomHTTPClient allows injecting a fully configured *http.Client.
+// The client is copied, so options applied after it, such as WithTimeout or
+// WithTLSConfig, leave hc itself untouched.
 func WithCustomHTTPClient(hc *http.Client) Option {
 	return func(c *Client) error {
 		if hc == nil {
 			return fmt.Errorf("custom http client cannot be nil")
 		}
-		c.HTTPClient = hc
+		cp := *hc
+		c.HTTPClient = &cp
 		return nil
 	}
 }
@@ -65,12 +111,30 @@ func NewClient(opts ...Option) (*Client, error) {
 }
 
 func (c *Client) Do(method, pathURL string, headers map[string]string, body io.Reader) (*http.Response, error) {
+	return c.DoContext(context.Background(), method, pathURL, headers, body)
+}
+
+// DoContext is like Do but binds the request to ctx.
+func (c *Client) DoContext(ctx context.Context, method, pathURL string, headers map[string]string, body io.Reader) (*http.Response, error) {
+	return c.do(ctx, c.HTTPClient, method, pathURL, headers, body)
+}
+
+// DoStream is like DoContext but ignores the client-wide timeout, so it can
+// be used for long-lived response bodies such as followed logs. The request
+// lives until ctx is cancelled or the body is closed.
+func (c *Client) DoStream(ctx context.Context, method, pathURL string, headers map[string]string, body io.Reader) (*http.Response, error) {
+	hc := *c.HTTPClient
+	hc.Timeout = 0
+	return c.do(ctx, &hc, method, pathURL, headers, body)
+}
+
+func (c *Client) do(ctx context.Context, hc *http.Client, method, pathURL string, headers map[string]string, body io.Reader) (*http.Response, error) {
 
 	// Build the url
 	fullURL := c.BaseURL + pathURL
 
 	// Create a request
-	req, err := http.NewRequest(method, fullURL, body)
+	req, err := http.NewRequestWithContext(ctx, method, fullURL, body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -80,11 +144,80 @@ func (c *Client) Do(method, pathURL string, headers map[string]string, body io.R
 		req.Header.Set(k, v)
 	}
 
-	// Do a req
-	resp, err := c.HTTPClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("HTTP request failed: %w", err)
+	if len(c.Middleware) > 0 {
+		wrapped := *hc
+		wrapped.Transport = Chain(hc.Transport, c.Middleware...)
+		hc = &wrapped
+	}
+
+	if c.Retry == nil || c.Retry.MaxAttempts < 2 {
+		// Do a req
+		resp, err := hc.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("HTTP request failed: %w", err)
+		}
+		return resp, nil
+	}
+
+	return c.doWithRetry(hc, req)
+}
+
+func (c *Client) doWithRetry(hc *http.Client, req *http.Request) (*http.Response, error) {
+	policy := c.Retry
+
+	// Every attempt needs a fresh body, so buffer it unless the request
+	// already knows how to replay it.
+	if req.Body != nil && req.GetBody == nil {
+		data, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to buffer request body: %w", err)
+		}
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(data)), nil
+		}
+		req.Body, _ = req.GetBody()
 	}
 
-	return resp, nil
+	// A request that never reached the wire can be retried whatever its
+	// method; one that did only when it is idempotent.
+	idempotent := isIdempotent(req)
+	var written atomic.Bool
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		WroteHeaders: func() { written.Store(true) },
+	}))
+
+	for attempt := 1; ; attempt++ {
+		written.Store(false)
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("failed to replay request body: %w", err)
+			}
+			req.Body = body
+		}
+
+		resp, err := hc.Do(req)
+		last := attempt >= policy.MaxAttempts
+
+		wait := policy.backoff(attempt)
+		switch {
+		case err != nil:
+			if last || !retryableError(err) || (written.Load() && !retryableMethod(req.Method)) {
+				return nil, fmt.Errorf("HTTP request failed after %d attempt(s): %w", attempt, err)
+			}
+		case idempotent && policy.retryableStatus(resp.StatusCode) && !last:
+			if d, ok := retryAfter(resp); ok {
+				wait = policy.capBackoff(d)
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		default:
+			return resp, nil
+		}
+
+		if err := sleep(req.Context(), wait); err != nil {
+			return nil, fmt.Errorf("HTTP request failed: %w", err)
+		}
+	}
 }
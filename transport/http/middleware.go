@@ -0,0 +1,94 @@
+package httpClient
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Middleware wraps the round tripper that sends a request. A middleware may
+// inspect or rewrite the request, observe the response, or answer the
+// request itself without calling next.
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc adapts a function to http.RoundTripper.
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Chain wraps base with mws. The first middleware is the outermost one: it
+// sees the request first and the response last.
+func Chain(base http.RoundTripper, mws ...Middleware) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	for i := len(mws) - 1; i >= 0; i-- {
+		base = mws[i](base)
+	}
+	return base
+}
+
+// WithMiddleware appends mws to the client's chain. Middlewares run in the
+// order they were added, once per attempt when retries are enabled.
+func WithMiddleware(mws ...Middleware) Option {
+	return func(c *Client) error {
+		c.Middleware = append(c.Middleware, mws...)
+		return nil
+	}
+}
+
+// BearerAuth sets the Authorization header on requests that do not carry one.
+func BearerAuth(token string) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if req.Header.Get("Authorization") != "" {
+				return next.RoundTrip(req)
+			}
+			req = req.Clone(req.Context())
+			req.Header.Set("Authorization", "Bearer "+token)
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// SetHeaders sets static headers, e.g. tracing or client identification, on
+// every request.
+func SetHeaders(headers map[string]string) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			req = req.Clone(req.Context())
+			for k, v := range headers {
+				req.Header.Set(k, v)
+			}
+			return next.RoundTrip(req)
+		})
+	}
+}
+
+// LogRequests logs method, path, status and duration of every request.
+func LogRequests(logger *slog.Logger) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next.RoundTrip(req)
+			if err != nil {
+				logger.LogAttrs(req.Context(), slog.LevelWarn, "http request failed",
+					slog.String("method", req.Method),
+					slog.String("path", req.URL.Path),
+					slog.Duration("duration", time.Since(start)),
+					slog.String("error", err.Error()),
+				)
+				return nil, err
+			}
+			logger.LogAttrs(req.Context(), slog.LevelDebug, "http request",
+				slog.String("method", req.Method),
+				slog.String("path", req.URL.Path),
+				slog.Int("status", resp.StatusCode),
+				slog.Duration("duration", time.Since(start)),
+			)
+			return resp, nil
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package httpClient
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// record is a middleware that appends name to *calls on the way in and
+// "/"+name on the way out.
+func record(calls *[]string, name string) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			*calls = append(*calls, name)
+			resp, err := next.RoundTrip(req)
+			*calls = append(*calls, "/"+name)
+			return resp, err
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name    string
+		mws     []Middleware
+		headers map[string]string
+		want    map[string]string
+	}{
+		{
+			name: "bearer auth",
+			mws:  []Middleware{BearerAuth("abc")},
+			want: map[string]string{"Authorization": "Bearer abc"},
+		},
+		{
+			name:    "bearer auth keeps the caller's token",
+			mws:     []Middleware{BearerAuth("abc")},
+			headers: map[string]string{"Authorization": "Bearer mine"},
+			want:    map[string]string{"Authorization": "Bearer mine"},
+		},
+		{
+			name: "set headers",
+			mws:  []Middleware{SetHeaders(map[string]string{"X-Client": "go", "X-Trace": "1"})},
+			want: map[string]string{"X-Client": "go", "X-Trace": "1"},
+		},
+		{
+			name:    "set headers overrides the request",
+			mws:     []Middleware{SetHeaders(map[string]string{"X-Client": "go"})},
+			headers: map[string]string{"X-Client": "curl"},
+			want:    map[string]string{"X-Client": "go"},
+		},
+		{
+			name: "later middleware sees earlier headers",
+			mws:  []Middleware{SetHeaders(map[string]string{"Authorization": "Bearer set"}), BearerAuth("abc")},
+			want: map[string]string{"Authorization": "Bearer set"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewClient(WithBaseURL(srv.URL), WithMiddleware(tt.mws...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := c.Do("GET", "/", tt.headers, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			for k, v := range tt.want {
+				if got.Get(k) != v {
+					t.Errorf("%s = %q, want %q", k, got.Get(k), v)
+				}
+			}
+		})
+	}
+}
+
+func TestChainOrder(t *testing.T) {
+	var calls []string
+	base := RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		calls = append(calls, "base")
+		return &http.Response{StatusCode: 200, Body: http.NoBody}, nil
+	})
+
+	rt := Chain(base, record(&calls, "a"), record(&calls, "b"), record(&calls, "c"))
+	req := httptest.NewRequest("GET", "http://example.com/", nil)
+	if _, err := rt.RoundTrip(req); err != nil {
+		t.Fatal(err)
+	}
+	want := "a b c base /c /b /a"
+	if got := strings.Join(calls, " "); got != want {
+		t.Errorf("calls = %s, want %s", got, want)
+	}
+}
+
+func TestLogRequests(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	defer srv.Close()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	c, err := NewClient(WithBaseURL(srv.URL), WithMiddleware(LogRequests(logger)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := c.Do("GET", "/v1/ping", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	srv.Close()
+	if _, err := c.Do("POST", "/v1/identity", nil, nil); err == nil {
+		t.Fatal("request to a closed server succeeded")
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("logged %d lines, want 2:\n%s", len(lines), buf.String())
+	}
+	for _, want := range []string{"level=DEBUG", "method=GET", "path=/v1/ping", "status=418", "duration="} {
+		if !strings.Contains(lines[0], want) {
+			t.Errorf("request line %q lacks %q", lines[0], want)
+		}
+	}
+	for _, want := range []string{"level=WARN", "method=POST", "path=/v1/identity", "error="} {
+		if !strings.Contains(lines[1], want) {
+			t.Errorf("failure line %q lacks %q", lines[1], want)
+		}
+	}
+}
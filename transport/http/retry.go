@@ -0,0 +1,135 @@
+package httpClient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy decides how failed requests are retried. A request that
+// failed before any of it was sent, e.g. with a refused connection, is
+// retried whatever its method. Otherwise only idempotent methods are
+// retried; a request whose context is marked with WithIdempotent is also
+// retried on a retryable status, but never after a timeout or a dropped
+// connection, since the server may have acted on it.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	MaxAttempts int
+	// InitialBackoff is the upper bound of the first jittered wait.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the exponential backoff.
+	MaxBackoff time.Duration
+	// RetryableStatus lists the status codes that trigger a retry.
+	RetryableStatus []int
+}
+
+// DefaultRetryPolicy retries up to three times on gateway errors and
+// connection failures, waiting at most two seconds between attempts.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     2 * time.Second,
+		RetryableStatus: []int{
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+	}
+}
+
+type idempotentKey struct{}
+
+// WithIdempotent marks requests made with ctx as safe to retry on a
+// retryable status regardless of their method, e.g. reducer calls known to
+// be idempotent.
+func WithIdempotent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, idempotentKey{}, true)
+}
+
+func isIdempotent(req *http.Request) bool {
+	if forced, _ := req.Context().Value(idempotentKey{}).(bool); forced {
+		return true
+	}
+	return retryableMethod(req.Method)
+}
+
+// retryableMethod reports whether method is idempotent by definition.
+func retryableMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+func (p RetryPolicy) retryableStatus(code int) bool {
+	return slices.Contains(p.RetryableStatus, code)
+}
+
+func retryableError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// backoff returns the wait before the given retry (1-based), with full jitter.
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	d := p.InitialBackoff
+	for i := 1; i < retry && d < p.MaxBackoff; i++ {
+		d *= 2
+	}
+	d = p.capBackoff(d)
+	if d <= 0 {
+		return 0
+	}
+	return rand.N(d)
+}
+
+// capBackoff limits d to MaxBackoff, so neither doubling nor a server's
+// Retry-After can stall a request for longer.
+func (p RetryPolicy) capBackoff(d time.Duration) time.Duration {
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+	return d
+}
+
+// retryAfter parses a Retry-After header given in seconds or as an HTTP date.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if at, err := http.ParseTime(v); err == nil {
+		return max(time.Until(at), 0), true
+	}
+	return 0, false
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,250 @@
+package httpClient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	p := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: 500 * time.Millisecond}
+	tests := []struct {
+		retry int
+		limit time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond},
+		{4, 500 * time.Millisecond},
+		{10, 500 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		seen := map[time.Duration]bool{}
+		for range 200 {
+			d := p.backoff(tt.retry)
+			if d < 0 || d >= tt.limit {
+				t.Fatalf("backoff(%d) = %s, want within [0, %s)", tt.retry, d, tt.limit)
+			}
+			seen[d] = true
+		}
+		// Full jitter spreads the waits over the whole range.
+		if len(seen) < 100 {
+			t.Errorf("backoff(%d) gave %d distinct waits in 200 tries, want jitter", tt.retry, len(seen))
+		}
+	}
+
+	if d := (RetryPolicy{}).backoff(3); d != 0 {
+		t.Errorf("backoff without InitialBackoff = %s, want 0", d)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		header string
+		want   time.Duration
+		ok     bool
+	}{
+		{"", 0, false},
+		{"3", 3 * time.Second, true},
+		{"0", 0, true},
+		{"-1", 0, false},
+		{"soon", 0, false},
+		{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0, true},
+	}
+	for _, tt := range tests {
+		resp := &http.Response{Header: http.Header{}}
+		if tt.header != "" {
+			resp.Header.Set("Retry-After", tt.header)
+		}
+		got, ok := retryAfter(resp)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("retryAfter(%q) = %s, %v, want %s, %v", tt.header, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	p := RetryPolicy{MaxBackoff: time.Second}
+	if got := p.capBackoff(time.Hour); got != time.Second {
+		t.Errorf("capBackoff(1h) = %s, want the 1s MaxBackoff", got)
+	}
+}
+
+// flakyServer answers the first len(statuses) requests with those statuses
+// and every later one with 200, recording the bodies it received.
+type flakyServer struct {
+	*httptest.Server
+	retryAfter string
+
+	mu       sync.Mutex
+	statuses []int
+	bodies   []string
+}
+
+func newFlakyServer(t *testing.T, statuses ...int) *flakyServer {
+	s := &flakyServer{statuses: statuses}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.bodies = append(s.bodies, string(body))
+		if len(s.statuses) == 0 {
+			return
+		}
+		if s.retryAfter != "" {
+			w.Header().Set("Retry-After", s.retryAfter)
+		}
+		w.WriteHeader(s.statuses[0])
+		s.statuses = s.statuses[1:]
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *flakyServer) received() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.bodies...)
+}
+
+func testPolicy() RetryPolicy {
+	p := DefaultRetryPolicy()
+	p.InitialBackoff = time.Millisecond
+	p.MaxBackoff = 10 * time.Millisecond
+	return p
+}
+
+func TestRetryStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		idempotent bool
+		statuses   []int
+		wantStatus int
+		attempts   int
+	}{
+		{"get recovers", "GET", false, []int{503, 502}, 200, 3},
+		{"get gives up", "GET", false, []int{503, 503, 503}, 503, 3},
+		{"not retryable", "GET", false, []int{500}, 500, 1},
+		{"post", "POST", false, []int{503}, 503, 1},
+		{"idempotent post", "POST", true, []int{504}, 200, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newFlakyServer(t, tt.statuses...)
+			c, err := NewClient(WithBaseURL(s.URL), WithRetry(testPolicy()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx := context.Background()
+			if tt.idempotent {
+				ctx = WithIdempotent(ctx)
+			}
+			resp, err := c.DoContext(ctx, tt.method, "/", nil, strings.NewReader("payload"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+
+			// Every attempt carries the whole body again.
+			bodies := s.received()
+			if len(bodies) != tt.attempts {
+				t.Fatalf("%d attempts, want %d", len(bodies), tt.attempts)
+			}
+			for i, body := range bodies {
+				if body != "payload" {
+					t.Errorf("attempt %d sent %q, want the body replayed", i+1, body)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryAfterIsCapped(t *testing.T) {
+	s := newFlakyServer(t, 503)
+	s.retryAfter = "3600"
+	c, err := NewClient(WithBaseURL(s.URL), WithRetry(testPolicy()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	resp, err := c.Do("GET", "/", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 200 {
+		t.Errorf("status %d, want 200", resp.StatusCode)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("retry took %s, want Retry-After capped at MaxBackoff", elapsed)
+	}
+}
+
+func TestRetryErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		idempotent bool
+		// sent fails the first attempt after it reached the server, instead
+		// of before.
+		sent     bool
+		err      error
+		attempts int
+	}{
+		{"refused get", "GET", false, false, syscall.ECONNREFUSED, 2},
+		{"refused post", "POST", false, false, syscall.ECONNREFUSED, 2},
+		{"dropped get", "GET", false, true, io.EOF, 2},
+		{"dropped post", "POST", false, true, io.EOF, 1},
+		{"dropped idempotent post", "POST", true, true, io.ErrUnexpectedEOF, 1},
+		{"cancelled", "GET", false, false, context.Canceled, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newFlakyServer(t)
+			attempts := 0
+			fail := func(next http.RoundTripper) http.RoundTripper {
+				return RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+					attempts++
+					if attempts > 1 {
+						return next.RoundTrip(req)
+					}
+					if tt.sent {
+						resp, err := next.RoundTrip(req)
+						if err != nil {
+							return nil, err
+						}
+						resp.Body.Close()
+					}
+					return nil, tt.err
+				})
+			}
+			c, err := NewClient(WithBaseURL(s.URL), WithRetry(testPolicy()), WithMiddleware(fail))
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx := context.Background()
+			if tt.idempotent {
+				ctx = WithIdempotent(ctx)
+			}
+			resp, err := c.DoContext(ctx, tt.method, "/", nil, strings.NewReader("payload"))
+			if err == nil {
+				resp.Body.Close()
+			}
+			if attempts != tt.attempts {
+				t.Errorf("%d attempts, want %d", attempts, tt.attempts)
+			}
+			if retried := tt.attempts > 1; retried != (err == nil) {
+				t.Errorf("err = %v, want success only after a retry", err)
+			}
+		})
+	}
+}
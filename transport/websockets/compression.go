@@ -0,0 +1,189 @@
+package websocketsClient
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/gorilla/websocket"
+)
+
+// Compression is the per-message compression SpacetimeDB applies to binary
+// server messages, requested through the subscribe URL.
+type Compression string
+
+const (
+	CompressionNone   Compression = "None"
+	CompressionBrotli Compression = "Brotli"
+	CompressionGzip   Compression = "Gzip"
+)
+
+// Tags prefixed to every binary server message.
+const (
+	tagNone   byte = 0
+	tagBrotli byte = 1
+	tagGzip   byte = 2
+)
+
+// DefaultMaxMessageSize bounds a received message, before and after
+// decompression, unless WithMaxMessageSize says otherwise.
+const DefaultMaxMessageSize = 32 << 20
+
+// ErrMessageTooLarge is returned when a message, or what it decompresses
+// to, exceeds the client's maximum message size.
+var ErrMessageTooLarge = errors.New("websocket message too large")
+
+// Decompressor wraps a compressed stream in a reader of the plain bytes.
+type Decompressor func(io.Reader) (io.Reader, error)
+
+var (
+	decompressorsMu sync.RWMutex
+	decompressors   = map[byte]Decompressor{
+		tagNone: func(r io.Reader) (io.Reader, error) { return r, nil },
+		tagGzip: func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+	}
+)
+
+// RegisterBrotli installs the Brotli decoder. The standard library has none,
+// so Brotli can only be requested once one is registered, e.g.
+//
+//	websocketsClient.RegisterBrotli(func(r io.Reader) (io.Reader, error) {
+//		return brotli.NewReader(r), nil
+//	})
+func RegisterBrotli(d Decompressor) {
+	decompressorsMu.Lock()
+	defer decompressorsMu.Unlock()
+	decompressors[tagBrotli] = d
+}
+
+func decompressor(tag byte) (Decompressor, bool) {
+	decompressorsMu.RLock()
+	defer decompressorsMu.RUnlock()
+	d, ok := decompressors[tag]
+	return d, ok
+}
+
+// WithMessageCompression asks the server to compress binary messages with c.
+func WithMessageCompression(c Compression) Option {
+	return func(cl *Client) error {
+		switch c {
+		case CompressionNone, CompressionGzip:
+		case CompressionBrotli:
+			if _, ok := decompressor(tagBrotli); !ok {
+				return fmt.Errorf("brotli compression needs a decoder, see RegisterBrotli")
+			}
+		default:
+			return fmt.Errorf("unknown compression %q", c)
+		}
+		cl.Compression = c
+		return nil
+	}
+}
+
+// WithMaxMessageSize bounds the size of a received message to n bytes, both
+// as read from the connection and once decompressed, so a small compressed
+// message cannot expand without limit. It defaults to DefaultMaxMessageSize.
+func WithMaxMessageSize(n int64) Option {
+	return func(c *Client) error {
+		if n <= 0 {
+			return fmt.Errorf("max message size must be positive")
+		}
+		c.MaxMessageSize = n
+		return nil
+	}
+}
+
+func (c *Client) maxMessageSize() int64 {
+	if c.MaxMessageSize > 0 {
+		return c.MaxMessageSize
+	}
+	return DefaultMaxMessageSize
+}
+
+// Stats counts the bytes read from the network and the message payloads
+// they decoded to. WireBytes is measured below the websocket layer, so it
+// includes framing and the upgrade response, and reflects permessage-deflate
+// as well as the compression SpacetimeDB applies itself. Only connections
+// dialed by the client are counted; Wrap cannot see beneath the conn it is
+// given.
+type Stats struct {
+	Messages     uint64
+	WireBytes    uint64
+	DecodedBytes uint64
+}
+
+type stats struct {
+	messages, wire, decoded atomic.Uint64
+}
+
+// Stats returns the totals over every connection opened by the client.
+func (c *Client) Stats() Stats {
+	return Stats{
+		Messages:     c.stats.messages.Load(),
+		WireBytes:    c.stats.wire.Load(),
+		DecodedBytes: c.stats.decoded.Load(),
+	}
+}
+
+// Decode strips the compression envelope of a binary server message. Text
+// messages carry no envelope and are returned as is.
+func (c *Client) Decode(messageType int, data []byte) ([]byte, error) {
+	c.stats.messages.Add(1)
+
+	if messageType != websocket.BinaryMessage {
+		c.stats.decoded.Add(uint64(len(data)))
+		return data, nil
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty binary message")
+	}
+
+	d, ok := decompressor(data[0])
+	if !ok {
+		return nil, fmt.Errorf("unsupported message compression tag %d", data[0])
+	}
+	if data[0] == tagNone {
+		c.stats.decoded.Add(uint64(len(data) - 1))
+		return data[1:], nil
+	}
+
+	r, err := d(bytes.NewReader(data[1:]))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress message: %w", err)
+	}
+	limit := c.maxMessageSize()
+	out, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress message: %w", err)
+	}
+	if int64(len(out)) > limit {
+		return nil, fmt.Errorf("%w: decompresses to more than %d bytes", ErrMessageTooLarge, limit)
+	}
+	c.stats.decoded.Add(uint64(len(out)))
+	return out, nil
+}
+
+// readError explains why reading from a connection failed: a read timeout
+// means the peer is dead, and a read limit a message that is too large.
+func (c *Client) readError(err error) error {
+	if errors.Is(err, websocket.ErrReadLimit) {
+		return fmt.Errorf("%w: more than %d bytes", ErrMessageTooLarge, c.maxMessageSize())
+	}
+	return c.deadError(err)
+}
+
+// ReadMessage reads the next message from conn and decodes it. With an
+// idle timeout set, a silent peer fails the read with ErrDeadConnection.
+func (c *Client) ReadMessage(conn *websocket.Conn) (int, []byte, error) {
+	c.extendDeadline(conn)
+	messageType, data, err := conn.ReadMessage()
+	if err != nil {
+		return messageType, nil, c.readError(err)
+	}
+	data, err = c.Decode(messageType, data)
+	return messageType, data, err
+}
@@ -0,0 +1,150 @@
+package websocketsClient
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+// newServer starts a websocket server that hands each connection to
+// handle, and returns a client for it built with opts.
+func newServer(t *testing.T, upgrader websocket.Upgrader, handle func(*websocket.Conn), opts ...Option) *Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer ws.Close()
+		handle(ws)
+	}))
+	t.Cleanup(srv.Close)
+
+	c, err := NewClient(append([]Option{WithBaseURL("ws" + strings.TrimPrefix(srv.URL, "http"))}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func gzipped(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write(data)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecode(t *testing.T) {
+	// Stand in for Brotli with base64, which the standard library has.
+	RegisterBrotli(func(r io.Reader) (io.Reader, error) {
+		return base64.NewDecoder(base64.StdEncoding, r), nil
+	})
+
+	tests := []struct {
+		name        string
+		messageType int
+		data        []byte
+		want        string
+		err         string
+	}{
+		{"text", websocket.TextMessage, []byte(`{"a":1}`), `{"a":1}`, ""},
+		{"none", websocket.BinaryMessage, append([]byte{tagNone}, "plain"...), "plain", ""},
+		{"brotli", websocket.BinaryMessage, append([]byte{tagBrotli}, base64.StdEncoding.EncodeToString([]byte("squeezed"))...), "squeezed", ""},
+		{"gzip", websocket.BinaryMessage, append([]byte{tagGzip}, gzipped(t, []byte("zipped"))...), "zipped", ""},
+		{"corrupt gzip", websocket.BinaryMessage, []byte{tagGzip, 1, 2, 3}, "", "failed to decompress message"},
+		{"unknown tag", websocket.BinaryMessage, []byte{7, 1}, "", "unsupported message compression tag 7"},
+		{"empty", websocket.BinaryMessage, nil, "", "empty binary message"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Client{}
+			got, err := c.Decode(tt.messageType, tt.data)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("Decode = %q, want %q", got, tt.want)
+			}
+			if s := c.Stats(); s.Messages != 1 || s.DecodedBytes != uint64(len(tt.want)) {
+				t.Errorf("stats = %+v, want 1 message of %d bytes", s, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestDecodeLimit(t *testing.T) {
+	c := &Client{MaxMessageSize: 4 << 10}
+	msg := append([]byte{tagGzip}, gzipped(t, make([]byte, 4<<10))...)
+	if _, err := c.Decode(websocket.BinaryMessage, msg); err != nil {
+		t.Fatalf("message at the limit: %v", err)
+	}
+
+	bomb := append([]byte{tagGzip}, gzipped(t, make([]byte, 1<<20))...)
+	if len(bomb) > 4<<10 {
+		t.Fatalf("compressed message is %d bytes, want it under the limit", len(bomb))
+	}
+	if _, err := c.Decode(websocket.BinaryMessage, bomb); !errors.Is(err, ErrMessageTooLarge) {
+		t.Errorf("err = %v, want ErrMessageTooLarge", err)
+	}
+}
+
+func TestReadLimit(t *testing.T) {
+	c := newServer(t, websocket.Upgrader{}, func(ws *websocket.Conn) {
+		ws.WriteMessage(websocket.BinaryMessage, make([]byte, 2<<10))
+		ws.ReadMessage()
+	}, WithMaxMessageSize(1<<10))
+
+	conn, _, err := c.Dial(t.Context(), "/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-conn.Done()
+	if !errors.Is(conn.Err(), ErrMessageTooLarge) {
+		t.Errorf("Err = %v, want ErrMessageTooLarge", conn.Err())
+	}
+}
+
+// WireBytes is counted on the network, so permessage-deflate shows in it.
+func TestWireBytesAfterDeflate(t *testing.T) {
+	payload := bytes.Repeat([]byte("spacetime "), 10_000)
+	c := newServer(t, websocket.Upgrader{EnableCompression: true}, func(ws *websocket.Conn) {
+		ws.WriteMessage(websocket.TextMessage, payload)
+		ws.ReadMessage()
+	})
+
+	conn, _, err := c.Dial(t.Context(), "/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	msg := <-conn.Messages()
+	if !bytes.Equal(msg.Data, payload) {
+		t.Fatalf("received %d bytes, want the %d byte payload", len(msg.Data), len(payload))
+	}
+
+	s := c.Stats()
+	if s.DecodedBytes != uint64(len(payload)) {
+		t.Errorf("DecodedBytes = %d, want %d", s.DecodedBytes, len(payload))
+	}
+	if s.WireBytes == 0 || s.WireBytes >= s.DecodedBytes/10 {
+		t.Errorf("WireBytes = %d, want the deflated size, far below %d", s.WireBytes, s.DecodedBytes)
+	}
+}
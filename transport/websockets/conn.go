@@ -0,0 +1,313 @@
+package websocketsClient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Default queue sizes for connections opened with Dial.
+const (
+	DefaultSendQueue    = 64
+	DefaultReceiveQueue = 256
+)
+
+var (
+	// ErrClosed is returned by Send once the connection has been closed.
+	ErrClosed = errors.New("websocket connection closed")
+	// ErrSendQueueFull is returned by TrySend when the write queue is full.
+	ErrSendQueueFull = errors.New("websocket send queue full")
+)
+
+// MessageHook observes every message sent ("out") or received ("in") on a
+// Conn. size is the payload as seen on the wire.
+type MessageHook func(direction string, messageType int, size int)
+
+// WithQueueSizes bounds the number of messages waiting to be written and
+// received messages waiting to be consumed. A full send queue blocks Send;
+// a full receive queue stops reading, which pushes back on the server.
+func WithQueueSizes(send, receive int) Option {
+	return func(c *Client) error {
+		if send < 1 || receive < 1 {
+			return fmt.Errorf("queue sizes must be positive")
+		}
+		c.SendQueue = send
+		c.ReceiveQueue = receive
+		return nil
+	}
+}
+
+// WithMessageHook calls hook for every message on connections opened with Dial.
+func WithMessageHook(hook MessageHook) Option {
+	return func(c *Client) error {
+		c.Hook = hook
+		return nil
+	}
+}
+
+// ConnOption configures a single Conn opened with Dial or Wrap.
+type ConnOption func(*Conn)
+
+// Observe calls fn with every message the connection receives, after it is
+// decoded and before it is delivered on Messages. fn runs on the read
+// goroutine, so it must not block. Being set before reading starts, it also
+// sees the first message.
+func Observe(fn func(Message)) ConnOption {
+	return func(conn *Conn) { conn.observe = fn }
+}
+
+// Message is a decoded message received on a Conn.
+type Message struct {
+	Type int
+	Data []byte
+}
+
+type outgoing struct {
+	messageType int
+	data        []byte
+	result      chan error
+}
+
+// Conn wraps a websocket connection so it can be used from any goroutine.
+// A single goroutine reads and decodes messages into Messages, and another
+// drains a bounded queue of writes, so callers never touch the underlying
+// *websocket.Conn concurrently.
+type Conn struct {
+	ws     *websocket.Conn
+	client *Client
+
+	in   chan Message
+	out  chan outgoing
+	done chan struct{}
+
+	observe func(Message)
+
+	lastPong atomic.Int64 // unix nanos of the last pong
+	// blockedSince holds the unix nanos at which the read loop started
+	// waiting for room in the receive queue, or 0 while it is reading, and
+	// resumed the unix nanos at which it last stopped waiting. Pongs are
+	// only processed while reading.
+	blockedSince atomic.Int64
+	resumed      atomic.Int64
+
+	closeOnce sync.Once
+	mu        sync.Mutex
+	err       error
+}
+
+// Dial opens a connection to path like Connect and wraps it in a Conn.
+func (c *Client) Dial(ctx context.Context, path string, headers http.Header, opts ...ConnOption) (*Conn, *http.Response, error) {
+	u, err := c.url(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ws, resp, err := c.Dialer.DialContext(ctx, u, headers)
+	if err != nil {
+		return nil, resp, fmt.Errorf("failed to connect: %w", err)
+	}
+	return c.Wrap(ws, opts...), resp, nil
+}
+
+// Wrap takes ownership of ws and starts its read and write loops. ws must
+// not be used directly afterwards.
+func (c *Client) Wrap(ws *websocket.Conn, opts ...ConnOption) *Conn {
+	sendQueue, receiveQueue := c.SendQueue, c.ReceiveQueue
+	if sendQueue < 1 {
+		sendQueue = DefaultSendQueue
+	}
+	if receiveQueue < 1 {
+		receiveQueue = DefaultReceiveQueue
+	}
+
+	conn := &Conn{
+		ws:     ws,
+		client: c,
+		in:     make(chan Message, receiveQueue),
+		out:    make(chan outgoing, sendQueue),
+		done:   make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(conn)
+	}
+	c.track(conn)
+	ws.SetReadLimit(c.maxMessageSize())
+	c.watchControl(ws, func() { conn.lastPong.Store(time.Now().UnixNano()) })
+
+	go conn.readLoop()
+	go conn.writeLoop()
+	if c.PingInterval > 0 {
+		go conn.pingLoop()
+	}
+	return conn
+}
+
+// Messages delivers received messages in order. It is closed when the
+// connection ends, after which Err reports why.
+func (conn *Conn) Messages() <-chan Message { return conn.in }
+
+// Done is closed when the connection ends.
+func (conn *Conn) Done() <-chan struct{} { return conn.done }
+
+// Err returns the error that ended the connection, ErrClosed after Close,
+// or nil while it is open.
+func (conn *Conn) Err() error {
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	return conn.err
+}
+
+// Subprotocol returns the subprotocol negotiated with the server.
+func (conn *Conn) Subprotocol() string { return conn.ws.Subprotocol() }
+
+// Send queues a message and waits until it has been written. It blocks
+// while the send queue is full, until ctx is done.
+func (conn *Conn) Send(ctx context.Context, messageType int, data []byte) error {
+	msg := outgoing{messageType: messageType, data: data, result: make(chan error, 1)}
+	select {
+	case conn.out <- msg:
+	case <-conn.done:
+		return conn.closedErr()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-msg.result:
+		return err
+	case <-conn.done:
+		return conn.closedErr()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// TrySend queues a message without waiting, failing with ErrSendQueueFull
+// if there is no room. Write errors end the connection and show up in Err.
+func (conn *Conn) TrySend(messageType int, data []byte) error {
+	select {
+	case <-conn.done:
+		return conn.closedErr()
+	default:
+	}
+	select {
+	case conn.out <- outgoing{messageType: messageType, data: data}:
+		return nil
+	default:
+		return ErrSendQueueFull
+	}
+}
+
+// SendJSON encodes v and sends it as a text message.
+func (conn *Conn) SendJSON(ctx context.Context, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+	return conn.Send(ctx, websocket.TextMessage, data)
+}
+
+// Close sends a close frame and shuts the connection down. It is safe to
+// call more than once and from any goroutine.
+func (conn *Conn) Close() error {
+	// WriteControl may be called concurrently with the write loop.
+	msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	conn.ws.WriteControl(websocket.CloseMessage, msg, time.Now().Add(time.Second))
+	conn.shutdown(ErrClosed)
+	return nil
+}
+
+// shutdown records err as the reason the connection ended, unless one is
+// already recorded, and releases every goroutine waiting on it.
+func (conn *Conn) shutdown(err error) {
+	conn.closeOnce.Do(func() {
+		conn.mu.Lock()
+		conn.err = err
+		conn.mu.Unlock()
+		close(conn.done)
+		conn.ws.Close()
+		conn.client.untrack(conn)
+	})
+}
+
+func (conn *Conn) closedErr() error {
+	if err := conn.Err(); err != nil {
+		return err
+	}
+	return ErrClosed
+}
+
+func (conn *Conn) hook(direction string, messageType, size int) {
+	if conn.client.Hook != nil {
+		conn.client.Hook(direction, messageType, size)
+	}
+}
+
+func (conn *Conn) readLoop() {
+	defer close(conn.in)
+
+	for {
+		// The idle timeout only runs while reading, so time spent waiting
+		// for the consumer below does not count as silence from the peer.
+		conn.client.extendDeadline(conn.ws)
+		messageType, data, err := conn.ws.ReadMessage()
+		if err != nil {
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				err = ErrClosed
+			}
+			conn.shutdown(conn.client.readError(err))
+			return
+		}
+		conn.hook("in", messageType, len(data))
+
+		data, err = conn.client.Decode(messageType, data)
+		if err != nil {
+			conn.shutdown(err)
+			return
+		}
+
+		msg := Message{Type: messageType, Data: data}
+		if conn.observe != nil {
+			conn.observe(msg)
+		}
+
+		select {
+		case conn.in <- msg:
+		default:
+			conn.blockedSince.Store(time.Now().UnixNano())
+			select {
+			case conn.in <- msg:
+			case <-conn.done:
+				return
+			}
+			conn.resumed.Store(time.Now().UnixNano())
+			conn.blockedSince.Store(0)
+		}
+	}
+}
+
+func (conn *Conn) writeLoop() {
+	for {
+		select {
+		case msg := <-conn.out:
+			err := conn.ws.WriteMessage(msg.messageType, msg.data)
+			if msg.result != nil {
+				msg.result <- err
+			}
+			if err != nil {
+				conn.shutdown(fmt.Errorf("failed to write message: %w", err))
+				return
+			}
+			conn.hook("out", msg.messageType, len(msg.data))
+		case <-conn.done:
+			return
+		}
+	}
+}
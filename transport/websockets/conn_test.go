@@ -0,0 +1,148 @@
+package websocketsClient_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/briheet/spacetime-goclient/spacetimedb/spacetimedbtest"
+	websocketsClient "github.com/briheet/spacetime-goclient/transport/websockets"
+	"github.com/gorilla/websocket"
+)
+
+// newFake starts a fake server with an empty database "chat".
+func newFake(t *testing.T) *spacetimedbtest.Server {
+	t.Helper()
+	srv := spacetimedbtest.NewServer()
+	t.Cleanup(srv.Close)
+	srv.AddDatabase("chat")
+	return srv
+}
+
+// dial connects to the subscribe endpoint of "chat" on srv and waits for
+// its IdentityToken.
+func dial(t *testing.T, srv *spacetimedbtest.Server, opts ...websocketsClient.Option) (*websocketsClient.Client, *websocketsClient.Conn) {
+	t.Helper()
+	c, err := websocketsClient.NewClient(append([]websocketsClient.Option{
+		websocketsClient.WithBaseURL("ws" + strings.TrimPrefix(srv.URL, "http")),
+	}, opts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, _, err := c.Dial(context.Background(), "/v1/database/chat/subscribe", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	select {
+	case msg := <-conn.Messages():
+		if !strings.Contains(string(msg.Data), "IdentityToken") {
+			t.Fatalf("first message %s, want the IdentityToken", msg.Data)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no IdentityToken")
+	}
+	return c, conn
+}
+
+// ended waits for conn to end and checks the reason.
+func ended(t *testing.T, conn *websocketsClient.Conn, want error) {
+	t.Helper()
+	select {
+	case <-conn.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection did not end")
+	}
+	if !errors.Is(conn.Err(), want) {
+		t.Errorf("Err = %v, want %v", conn.Err(), want)
+	}
+	// Messages is drained and closed once the read loop is gone.
+	for range conn.Messages() {
+	}
+}
+
+func TestCloseByClient(t *testing.T) {
+	_, conn := dial(t, newFake(t))
+
+	if err := conn.Close(); err != nil {
+		t.Fatal(err)
+	}
+	ended(t, conn, websocketsClient.ErrClosed)
+	if err := conn.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+	if err := conn.Send(context.Background(), websocket.TextMessage, []byte("{}")); !errors.Is(err, websocketsClient.ErrClosed) {
+		t.Errorf("Send after Close = %v, want ErrClosed", err)
+	}
+	if err := conn.TrySend(websocket.TextMessage, []byte("{}")); !errors.Is(err, websocketsClient.ErrClosed) {
+		t.Errorf("TrySend after Close = %v, want ErrClosed", err)
+	}
+}
+
+func TestCloseByServer(t *testing.T) {
+	srv := newFake(t)
+	_, conn := dial(t, srv)
+
+	srv.Database("chat").Disconnect()
+	ended(t, conn, websocketsClient.ErrClosed)
+}
+
+func TestCloseClientClosesConns(t *testing.T) {
+	c, conn := dial(t, newFake(t))
+
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	ended(t, conn, websocketsClient.ErrClosed)
+}
+
+func TestKeepalive(t *testing.T) {
+	_, conn := dial(t, newFake(t), websocketsClient.WithKeepalive(10*time.Millisecond, 50*time.Millisecond))
+
+	select {
+	case <-conn.Done():
+		t.Fatalf("connection ended: %v", conn.Err())
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+func TestPingTimeout(t *testing.T) {
+	srv := newFake(t)
+	srv.IgnorePings()
+	_, conn := dial(t, srv, websocketsClient.WithKeepalive(10*time.Millisecond, 20*time.Millisecond))
+
+	ended(t, conn, websocketsClient.ErrDeadConnection)
+	if !strings.Contains(conn.Err().Error(), "no pong") {
+		t.Errorf("Err = %v, want a missing pong", conn.Err())
+	}
+}
+
+// A reader blocked on a full receive queue cannot see pongs, so keepalive
+// gives up on it after a whole ping cycle even though the peer answers.
+func TestStalledReaderIsDead(t *testing.T) {
+	_, conn := dial(t, newFake(t),
+		websocketsClient.WithQueueSizes(8, 1),
+		websocketsClient.WithKeepalive(10*time.Millisecond, 20*time.Millisecond),
+	)
+
+	// The first reply fills the queue and the second blocks the reader.
+	for i := range 2 {
+		query := map[string]any{"OneOffQuery": map[string]any{"message_id": fmt.Sprint(i), "query_string": "SELECT * FROM person"}}
+		if err := conn.SendJSON(context.Background(), query); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	select {
+	case <-conn.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection did not end")
+	}
+	if err := conn.Err(); !errors.Is(err, websocketsClient.ErrDeadConnection) || !strings.Contains(err.Error(), "receive queue full") {
+		t.Errorf("Err = %v, want a dead connection with a full receive queue", err)
+	}
+}
@@ -0,0 +1,126 @@
+package websocketsClient
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// ErrDeadConnection means the peer stopped answering pings or sending
+// anything at all, so the connection is considered half-open. Callers can
+// test for it with errors.Is and reconnect.
+var ErrDeadConnection = errors.New("websocket peer is not responding")
+
+// WithKeepalive pings the server every interval on connections opened with
+// Dial, and ends the connection with ErrDeadConnection if a pong does not
+// arrive within pongTimeout. Pongs are read by the read loop, so a consumer
+// that leaves the receive queue full for longer than interval plus
+// pongTimeout ends the connection the same way.
+func WithKeepalive(interval, pongTimeout time.Duration) Option {
+	return func(c *Client) error {
+		if interval <= 0 || pongTimeout <= 0 {
+			return fmt.Errorf("keepalive interval and pong timeout must be positive")
+		}
+		c.PingInterval = interval
+		c.PongTimeout = pongTimeout
+		return nil
+	}
+}
+
+// WithIdleTimeout fails reads with ErrDeadConnection when nothing, not even
+// a ping or pong, has been received for d.
+func WithIdleTimeout(d time.Duration) Option {
+	return func(c *Client) error {
+		if d <= 0 {
+			return fmt.Errorf("idle timeout must be positive")
+		}
+		c.IdleTimeout = d
+		return nil
+	}
+}
+
+// extendDeadline pushes the read deadline of ws out by the idle timeout.
+func (c *Client) extendDeadline(ws *websocket.Conn) {
+	if c.IdleTimeout > 0 {
+		ws.SetReadDeadline(time.Now().Add(c.IdleTimeout))
+	}
+}
+
+// deadError turns a read timeout into ErrDeadConnection.
+func (c *Client) deadError(err error) error {
+	var ne net.Error
+	if c.IdleTimeout > 0 && errors.As(err, &ne) && ne.Timeout() {
+		return fmt.Errorf("%w: nothing received for %s", ErrDeadConnection, c.IdleTimeout)
+	}
+	return err
+}
+
+// watchControl refreshes the read deadline on every ping and pong, and
+// reports pongs to onPong.
+func (c *Client) watchControl(ws *websocket.Conn, onPong func()) {
+	reply := ws.PingHandler()
+	ws.SetPingHandler(func(data string) error {
+		c.extendDeadline(ws)
+		return reply(data)
+	})
+	ws.SetPongHandler(func(string) error {
+		c.extendDeadline(ws)
+		if onPong != nil {
+			onPong()
+		}
+		return nil
+	})
+}
+
+// pingLoop sends pings until the connection ends, and shuts it down once a
+// ping goes unanswered for PongTimeout. A pong can only be seen while the
+// read loop is reading, so a ping is not held against the peer if the read
+// loop spent some of the wait blocked on a full receive queue. A read loop
+// that stays blocked for a whole ping cycle cannot vouch for the peer at
+// all, and ends the connection as dead too.
+func (conn *Conn) pingLoop() {
+	interval, timeout := conn.client.PingInterval, conn.client.PongTimeout
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-conn.done:
+			return
+		}
+
+		sent := time.Now()
+		if err := conn.ws.WriteControl(websocket.PingMessage, nil, sent.Add(timeout)); err != nil {
+			conn.shutdown(fmt.Errorf("failed to send ping: %w", err))
+			return
+		}
+
+		select {
+		case <-time.After(timeout):
+		case <-conn.done:
+			return
+		}
+		if conn.lastPong.Load() >= sent.UnixNano() {
+			continue
+		}
+		if since := conn.blockedSince.Load(); since != 0 && since <= sent.Add(-interval).UnixNano() {
+			conn.shutdown(fmt.Errorf("%w: receive queue full for %s, pongs went unread",
+				ErrDeadConnection, time.Since(time.Unix(0, since)).Round(time.Millisecond)))
+			return
+		}
+		if !conn.readerStalled(sent) {
+			conn.shutdown(fmt.Errorf("%w: no pong within %s", ErrDeadConnection, timeout))
+			return
+		}
+	}
+}
+
+// readerStalled reports whether the read loop has been blocked on the
+// receive queue at any point since t.
+func (conn *Conn) readerStalled(t time.Time) bool {
+	return conn.blockedSince.Load() != 0 || conn.resumed.Load() >= t.UnixNano()
+}
@@ -1,9 +1,14 @@
 package websocketsClient
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
+	"net"
 	"net/http"
 	"net/url"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -12,6 +17,28 @@ import (
 type Client struct {
 	BaseURL string
 	Dialer  *websocket.Dialer
+	// Compression is requested from the server when set, see
+	// WithMessageCompression.
+	Compression Compression
+	// SendQueue and ReceiveQueue bound the queues of connections opened
+	// with Dial, see WithQueueSizes.
+	SendQueue    int
+	ReceiveQueue int
+	// Hook observes messages on connections opened with Dial.
+	Hook MessageHook
+	// PingInterval and PongTimeout enable keepalive pings on connections
+	// opened with Dial, see WithKeepalive. IdleTimeout bounds the silence
+	// tolerated on any connection, see WithIdleTimeout.
+	PingInterval time.Duration
+	PongTimeout  time.Duration
+	IdleTimeout  time.Duration
+	// MaxMessageSize bounds received messages, see WithMaxMessageSize.
+	MaxMessageSize int64
+
+	stats stats
+
+	mu   sync.Mutex
+	open map[*Conn]struct{}
 }
 
 type Option func(*Client) error
@@ -33,12 +60,26 @@ func WithDialTimeout(timeout time.Duration) Option {
 	}
 }
 
+// WithTLSConfig sets the TLS configuration used for wss connections.
+func WithTLSConfig(cfg *tls.Config) Option {
+	return func(c *Client) error {
+		if cfg == nil {
+			return fmt.Errorf("TLS config cannot be nil")
+		}
+		c.Dialer.TLSClientConfig = cfg
+		return nil
+	}
+}
+
+// WithCustomDialer dials through a copy of d, so options applied after it,
+// such as WithTLSConfig or WithDialTimeout, leave d itself untouched.
 func WithCustomDialer(d *websocket.Dialer) Option {
 	return func(c *Client) error {
 		if d == nil {
 			return fmt.Errorf("custom dialer cannot be nil")
 		}
-		c.Dialer = d
+		cp := *d
+		c.Dialer = &cp
 		return nil
 	}
 }
@@ -61,21 +102,116 @@ func NewClient(opts ...Option) (*Client, error) {
 	if client.BaseURL == "" {
 		return nil, fmt.Errorf("BaseURL is required (use WithBaseURL)")
 	}
+	client.countWireBytes()
 
 	return client, nil
 }
 
-// Connect opens a websocket connection to the path with *dynamic headers*
+// countWireBytes makes the dialer count every byte read from the network
+// into the client's stats. The dialer is the client's own copy, so a
+// custom dialer passed in is left as it was.
+func (c *Client) countWireBytes() {
+	d := c.Dialer
+	dial := d.NetDialContext
+	if dial == nil && d.NetDial != nil {
+		netDial := d.NetDial
+		dial = func(_ context.Context, network, addr string) (net.Conn, error) {
+			return netDial(network, addr)
+		}
+	}
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	d.NetDialContext = c.counting(dial)
+	if d.NetDialTLSContext != nil {
+		d.NetDialTLSContext = c.counting(d.NetDialTLSContext)
+	}
+}
+
+type dialFunc = func(ctx context.Context, network, addr string) (net.Conn, error)
+
+func (c *Client) counting(dial dialFunc) dialFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return &countingConn{Conn: conn, n: &c.stats.wire}, nil
+	}
+}
+
+// countingConn adds the bytes read through it to n.
+type countingConn struct {
+	net.Conn
+	n *atomic.Uint64
+}
+
+func (c *countingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.n.Add(uint64(n))
+	return n, err
+}
+
+// Close closes every Conn opened with Dial or Wrap that is still open.
+// Connections returned by Connect belong to the caller and are not
+// affected. The client stays usable afterwards.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	conns := make([]*Conn, 0, len(c.open))
+	for conn := range c.open {
+		conns = append(conns, conn)
+	}
+	c.mu.Unlock()
+
+	for _, conn := range conns {
+		conn.Close()
+	}
+	return nil
+}
+
+func (c *Client) track(conn *Conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.open == nil {
+		c.open = make(map[*Conn]struct{})
+	}
+	c.open[conn] = struct{}{}
+}
+
+func (c *Client) untrack(conn *Conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.open, conn)
+}
+
+// Connect opens a websocket connection to the path with *dynamic headers*.
+// path is appended to BaseURL as is, so it must already be escaped.
 func (c *Client) Connect(path string, headers http.Header) (*websocket.Conn, *http.Response, error) {
-	wsURL, err := url.JoinPath(c.BaseURL, path)
+	u, err := c.url(path)
 	if err != nil {
-		return nil, nil, fmt.Errorf("invalid URL: %w", err)
+		return nil, nil, err
 	}
 
-	conn, resp, err := c.Dialer.Dial(wsURL, headers)
+	conn, resp, err := c.Dialer.Dial(u, headers)
 	if err != nil {
 		return nil, resp, fmt.Errorf("failed to connect: %w", err)
 	}
+	conn.SetReadLimit(c.maxMessageSize())
+	c.watchControl(conn, nil)
 
 	return conn, resp, nil
 }
+
+// url resolves path against BaseURL and adds the compression parameter.
+func (c *Client) url(path string) (string, error) {
+	u, err := url.Parse(c.BaseURL + path)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL: %w", err)
+	}
+	if c.Compression != "" {
+		q := u.Query()
+		q.Set("compression", string(c.Compression))
+		u.RawQuery = q.Encode()
+	}
+	return u.String(), nil
+}